@@ -0,0 +1,178 @@
+package cache_persist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BenchOptions 缓存压测参数
+type BenchOptions struct {
+	Ops         int           // 总操作数，读写各占一半
+	Concurrency int           // 并发数
+	Keys        int           // 键的数量，越小命中率越高
+	ValueSize   int           // 值的字节数
+	TTL         time.Duration // 写入的过期时间
+}
+
+// BenchResult 压测结果，延迟为单次操作的分位数
+type BenchResult struct {
+	Ops      int
+	Errors   int
+	Hits     int
+	Misses   int
+	Duration time.Duration
+	SetP50   time.Duration
+	SetP99   time.Duration
+	GetP50   time.Duration
+	GetP99   time.Duration
+}
+
+// OpsPerSecond 每秒操作数
+func (r BenchResult) OpsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// String 输出压测报告
+func (r BenchResult) String() string {
+	return fmt.Sprintf("操作数: %d (错误 %d)\n耗时: %v\n吞吐量: %.0f ops/s\n命中: %d 未命中: %d\nSET p50=%v p99=%v\nGET p50=%v p99=%v\n",
+		r.Ops, r.Errors, r.Duration, r.OpsPerSecond(), r.Hits, r.Misses,
+		r.SetP50, r.SetP99, r.GetP50, r.GetP99)
+}
+
+// benchStore 统一内存缓存和Redis缓存的接口
+type benchStore interface {
+	set(key, value string, ttl time.Duration) error
+	get(key string) (bool, error)
+}
+
+type memoryBenchStore struct{ cache *MemoryCache }
+
+func (s memoryBenchStore) set(key, value string, ttl time.Duration) error {
+	s.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (s memoryBenchStore) get(key string) (bool, error) {
+	_, found := s.cache.Get(key)
+	return found, nil
+}
+
+type redisBenchStore struct{ cache *RedisCache }
+
+func (s redisBenchStore) set(key, value string, ttl time.Duration) error {
+	return s.cache.Set(key, value, ttl)
+}
+
+func (s redisBenchStore) get(key string) (bool, error) {
+	_, err := s.cache.Get(key)
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// BenchMemoryCache 压测内存缓存
+func BenchMemoryCache(ctx context.Context, cache *MemoryCache, opts BenchOptions) BenchResult {
+	return runBench(ctx, memoryBenchStore{cache}, opts)
+}
+
+// BenchRedisCache 压测Redis缓存，会写入 bench: 前缀的键
+func BenchRedisCache(ctx context.Context, cache *RedisCache, opts BenchOptions) BenchResult {
+	return runBench(ctx, redisBenchStore{cache}, opts)
+}
+
+// runBench 由多个协程交替执行 SET 和 GET，ctx 取消时提前结束
+func runBench(ctx context.Context, store benchStore, opts BenchOptions) BenchResult {
+	if opts.Ops <= 0 {
+		opts.Ops = 100000
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Keys <= 0 {
+		opts.Keys = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	value := strings.Repeat("x", opts.ValueSize)
+
+	type workerResult struct {
+		errors, hits, misses int
+		sets, gets           []time.Duration
+	}
+	results := make([]workerResult, opts.Concurrency)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			result := &results[w]
+			// 操作数平均分配，余数由前几个协程承担
+			ops := opts.Ops / opts.Concurrency
+			if w < opts.Ops%opts.Concurrency {
+				ops++
+			}
+			for i := 0; i < ops && ctx.Err() == nil; i++ {
+				key := fmt.Sprintf("bench:%d", rng.Intn(opts.Keys))
+				begin := time.Now()
+				if i%2 == 0 {
+					err := store.set(key, value, opts.TTL)
+					result.sets = append(result.sets, time.Since(begin))
+					if err != nil {
+						result.errors++
+					}
+					continue
+				}
+				found, err := store.get(key)
+				result.gets = append(result.gets, time.Since(begin))
+				switch {
+				case err != nil:
+					result.errors++
+				case found:
+					result.hits++
+				default:
+					result.misses++
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := BenchResult{Duration: time.Since(start)}
+	var sets, gets []time.Duration
+	for _, r := range results {
+		total.Errors += r.errors
+		total.Hits += r.hits
+		total.Misses += r.misses
+		sets = append(sets, r.sets...)
+		gets = append(gets, r.gets...)
+	}
+	total.Ops = len(sets) + len(gets)
+	total.SetP50, total.SetP99 = percentile(sets, 50), percentile(sets, 99)
+	total.GetP50, total.GetP99 = percentile(gets, 50), percentile(gets, 99)
+	return total
+}
+
+// percentile 返回第p百分位的值，会对 values 排序
+func percentile(values []time.Duration, p int) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	return values[(len(values)-1)*p/100]
+}
@@ -62,6 +62,21 @@ func (c *MemoryCache) Set(key string, value string, ttl time.Duration) {
 	}
 }
 
+// SetNX 键不存在或已过期时设置缓存，返回是否设置成功
+func (c *MemoryCache) SetNX(key string, value string, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if item, found := c.cache[key]; found && !time.Now().After(item.expiration) {
+		return false
+	}
+	c.cache[key] = &cacheItem{
+		value:      value,
+		expiration: time.Now().Add(ttl),
+	}
+	return true
+}
+
 // Get 获取缓存
 func (c *MemoryCache) Get(key string) (string, bool) {
 	c.mutex.RLock()
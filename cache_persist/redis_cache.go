@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"go-basics/config"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -33,6 +35,11 @@ func (c *RedisCache) Set(key string, value string, ttl time.Duration) error {
 	return c.client.Set(c.ctx, key, value, ttl).Err()
 }
 
+// SetNX 键不存在时设置缓存，返回是否设置成功
+func (c *RedisCache) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(c.ctx, key, value, ttl).Result()
+}
+
 // Get 获取缓存
 func (c *RedisCache) Get(key string) (string, error) {
 	return c.client.Get(c.ctx, key).Result()
@@ -48,18 +55,28 @@ func (c *RedisCache) Clear() error {
 	return c.client.FlushDB(c.ctx).Err()
 }
 
+// Ping 检查Redis连接
+func (c *RedisCache) Ping() error {
+	return c.client.Ping(c.ctx).Err()
+}
+
 // Close 关闭Redis连接
 func (c *RedisCache) Close() error {
 	return c.client.Close()
 }
 
 func main() {
-	// 创建Redis缓存实例
-	cache := NewRedisCache("localhost:6379", "", 0)
+	// 创建Redis缓存实例，连接参数来自配置
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		return
+	}
+	cache := NewRedisCache(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
 	defer cache.Close()
 
 	// 设置缓存
-	err := cache.Set("test_key", "test_value", 5*time.Second)
+	err = cache.Set("test_key", "test_value", 5*time.Second)
 	if err != nil {
 		fmt.Printf("设置缓存失败: %v\n", err)
 		return
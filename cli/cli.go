@@ -0,0 +1,295 @@
+// Package cli 将各个示例模块组织为子命令，替代在 main.go 中注释/取消注释调用的方式
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"go-basics/config"
+)
+
+// 退出码
+const (
+	ExitOK          = 0   // 成功
+	ExitFailure     = 1   // 命令执行失败
+	ExitUsage       = 2   // 命令或参数错误
+	ExitConfig      = 3   // 配置加载或校验失败
+	ExitInterrupted = 130 // 被 Ctrl+C 或 SIGTERM 中断
+)
+
+// Command 一个命令或命令组，命令组只包含子命令，没有 Run
+type Command struct {
+	Name     string
+	Args     string // 参数说明，用于帮助信息，如 "<文件>..."
+	Short    string // 一行说明
+	Hidden   bool   // 不出现在帮助和补全中
+	Flags    func(fs *flag.FlagSet, cf *configFlags)
+	Run      func(ctx context.Context, env *Env, args []string) error
+	Commands []*Command
+}
+
+// find 按名称查找子命令
+func (c *Command) find(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Env 命令运行环境
+type Env struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	global *globalFlags
+	config *configFlags
+}
+
+// Config 按 --config、--env-file、--set 和命令参数加载配置
+func (e *Env) Config() (*config.Config, error) {
+	opts := config.DefaultOptions()
+	if e.global.configFile != "" {
+		opts.Files = []string{e.global.configFile}
+		opts.RequireFiles = true
+	}
+	if e.global.envFile != "" {
+		opts.EnvFile = e.global.envFile
+	}
+	opts.Overrides = make(map[string]string)
+	for key, value := range e.global.overrides {
+		opts.Overrides[key] = value
+	}
+	// 命令参数比 --set 更具体，优先级更高
+	for key, value := range e.config.overrides {
+		opts.Overrides[key] = value
+	}
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return nil, &configError{err}
+	}
+	return cfg, nil
+}
+
+// globalFlags 所有命令都支持的参数
+type globalFlags struct {
+	configFile string
+	envFile    string
+	overrides  map[string]string
+}
+
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.configFile, "config", g.configFile, "配置文件(YAML或TOML)，默认读取 config.yaml/config.toml 或 CONFIG_FILE")
+	fs.StringVar(&g.envFile, "env-file", g.envFile, ".env 文件路径")
+	fs.Func("set", "覆盖配置项，格式为 键=值，如 server.addr=:9000，可重复", func(kv string) error {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("格式应为 键=值")
+		}
+		g.overrides[key] = value
+		return nil
+	})
+}
+
+// configFlags 映射到配置项的命令参数，只有显式设置的参数才覆盖配置
+type configFlags struct {
+	overrides map[string]string
+}
+
+// String 注册映射到配置项 key 的字符串参数，帮助信息中以配置项作为参数名
+func (cf *configFlags) String(fs *flag.FlagSet, name, key, usage string) {
+	fs.Var(&overrideValue{cf: cf, key: key}, name, fmt.Sprintf("%s (`%s`)", usage, key))
+}
+
+// Bool 注册映射到配置项 key 的布尔参数
+func (cf *configFlags) Bool(fs *flag.FlagSet, name, key, usage string) {
+	fs.Var(&overrideValue{cf: cf, key: key, isBool: true}, name, fmt.Sprintf("%s (%s)", usage, key))
+}
+
+// overrideValue 实现 flag.Value，设置时写入覆盖项
+type overrideValue struct {
+	cf     *configFlags
+	key    string
+	isBool bool
+}
+
+func (v *overrideValue) String() string {
+	if v == nil || v.cf == nil {
+		return ""
+	}
+	return v.cf.overrides[v.key]
+}
+
+func (v *overrideValue) Set(value string) error {
+	v.cf.overrides[v.key] = value
+	return nil
+}
+
+func (v *overrideValue) IsBoolFlag() bool { return v.isBool }
+
+// errMissingCommand 命令组后没有指定子命令
+var errMissingCommand = errors.New("缺少子命令")
+
+// usageError 命令或参数错误，退出码为 ExitUsage
+type usageError struct{ message string }
+
+func (e *usageError) Error() string { return e.message }
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// configError 配置错误，退出码为 ExitConfig
+type configError struct{ err error }
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// programName 命令名，用于帮助信息和补全脚本
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// Run 执行命令行，返回退出码
+func Run(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Main(ctx, args, os.Stdout, os.Stderr)
+}
+
+// Main 在指定的上下文和输出中执行命令行，返回退出码
+func Main(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	root := newRootCommand()
+	env := &Env{
+		Stdout: stdout,
+		Stderr: stderr,
+		global: &globalFlags{overrides: make(map[string]string)},
+		config: &configFlags{overrides: make(map[string]string)},
+	}
+
+	cmd, path, rest, err := resolve(root, env, args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		printHelp(stdout, cmd, path)
+		return ExitOK
+	case errors.Is(err, errMissingCommand):
+		printHelp(stderr, cmd, path)
+		return ExitUsage
+	}
+	if err == nil {
+		err = cmd.Run(ctx, env, rest)
+	}
+	if err == nil {
+		return ExitOK
+	}
+
+	fmt.Fprintf(stderr, "错误: %v\n", err)
+	var usageErr *usageError
+	var configErr *configError
+	switch {
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "运行 '%s -h' 查看用法\n", strings.Join(path, " "))
+		return ExitUsage
+	case errors.As(err, &configErr):
+		return ExitConfig
+	case ctx.Err() != nil:
+		return ExitInterrupted
+	default:
+		return ExitFailure
+	}
+}
+
+// resolve 逐级解析参数和子命令，返回要执行的命令、命令路径和剩余参数
+// 每一级都可以出现全局参数，如 go-basics --config a.yaml serve rest --addr :9000
+func resolve(root *Command, env *Env, args []string) (*Command, []string, []string, error) {
+	cmd := root
+	path := []string{programName()}
+	for {
+		fs := newFlagSet(cmd, env)
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return cmd, path, nil, err
+			}
+			return cmd, path, nil, &usageError{err.Error()}
+		}
+		args = fs.Args()
+
+		if cmd.Run != nil {
+			return cmd, path, args, nil
+		}
+		if len(args) == 0 {
+			return cmd, path, nil, errMissingCommand
+		}
+		sub := cmd.find(args[0])
+		if sub == nil {
+			return cmd, path, nil, usageErrorf("未知命令 %q", args[0])
+		}
+		cmd, path, args = sub, append(path, sub.Name), args[1:]
+	}
+}
+
+// newFlagSet 创建命令的参数集合，包含全局参数
+func newFlagSet(cmd *Command, env *Env) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	env.global.register(fs)
+	if cmd.Flags != nil {
+		cmd.Flags(fs, env.config)
+	}
+	return fs
+}
+
+// printHelp 输出命令的用法、子命令和参数
+func printHelp(w io.Writer, cmd *Command, path []string) {
+	usage := strings.Join(path, " ")
+	switch {
+	case len(cmd.Commands) > 0:
+		usage += " <命令>"
+	case cmd.Args != "":
+		usage += " " + cmd.Args
+	}
+	fmt.Fprintf(w, "用法: %s [参数]\n", usage)
+	if cmd.Short != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.Short)
+	}
+
+	if visible := visibleCommands(cmd); len(visible) > 0 {
+		fmt.Fprintln(w, "\n命令:")
+		width := 0
+		for _, sub := range visible {
+			width = max(width, len(sub.Name))
+		}
+		for _, sub := range visible {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, sub.Name, sub.Short)
+		}
+	}
+
+	fs := newFlagSet(cmd, &Env{
+		global: &globalFlags{overrides: make(map[string]string)},
+		config: &configFlags{overrides: make(map[string]string)},
+	})
+	fmt.Fprintln(w, "\n参数:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// visibleCommands 返回未隐藏的子命令，按名称排序
+func visibleCommands(cmd *Command) []*Command {
+	var visible []*Command
+	for _, sub := range cmd.Commands {
+		if !sub.Hidden {
+			visible = append(visible, sub)
+		}
+	}
+	slices.SortFunc(visible, func(a, b *Command) int { return strings.Compare(a.Name, b.Name) })
+	return visible
+}
@@ -0,0 +1,434 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-basics/cache_persist"
+	"go-basics/config"
+	"go-basics/database"
+	"go-basics/filestorage"
+	"go-basics/httpclient"
+	"go-basics/server"
+)
+
+// newRootCommand 创建完整的命令树
+func newRootCommand() *Command {
+	return &Command{
+		Name:  programName(),
+		Short: "Go语言基础语法学习：以子命令运行各个示例模块",
+		Commands: []*Command{
+			newServeCommand(),
+			newCacheCommand(),
+			newDBCommand(),
+			newFilesCommand(),
+			newHTTPCommand(),
+			newConfigCommand(),
+			newDemoCommand(),
+			newCompletionCommand(),
+			newCompleteCommand(),
+		},
+	}
+}
+
+// newServeCommand serve gin|rest|mux
+func newServeCommand() *Command {
+	serverFlags := func(fs *flag.FlagSet, cf *configFlags) {
+		cf.String(fs, "addr", "server.addr", "监听地址")
+		cf.String(fs, "tls-cert", "server.tls_cert", "TLS证书文件")
+		cf.String(fs, "tls-key", "server.tls_key", "TLS私钥文件")
+		cf.Bool(fs, "self-signed", "server.tls_self_signed", "未指定证书时使用自签名证书")
+		cf.String(fs, "client-ca", "server.client_ca", "客户端证书的CA，设置后启用mTLS")
+		cf.Bool(fs, "h2c", "server.h2c", "明文时允许 HTTP/2")
+	}
+	serve := func(run func(context.Context, *config.Config) error) func(context.Context, *Env, []string) error {
+		return func(ctx context.Context, env *Env, args []string) error {
+			if len(args) > 0 {
+				return usageErrorf("多余的参数 %q", args)
+			}
+			cfg, err := env.Config()
+			if err != nil {
+				return err
+			}
+			return run(ctx, cfg)
+		}
+	}
+
+	return &Command{
+		Name:  "serve",
+		Short: "启动HTTP服务器，Ctrl+C 优雅退出",
+		Commands: []*Command{
+			{Name: "gin", Short: "Gin中间件示例", Flags: serverFlags, Run: serve(server.RunGin)},
+			{Name: "rest", Short: "RESTful API示例(产品、多租户、GraphQL)", Flags: func(fs *flag.FlagSet, cf *configFlags) {
+				serverFlags(fs, cf)
+				cf.String(fs, "compression-min-size", "server.compression_min_size", "响应压缩阈值(字节)")
+			}, Run: serve(server.RunRESTful)},
+			{Name: "mux", Short: "标准库 ServeMux 和中间件链示例", Flags: serverFlags, Run: serve(server.RunMux)},
+		},
+	}
+}
+
+// newCacheCommand cache bench
+func newCacheCommand() *Command {
+	var backend string
+	opts := cache_persist.BenchOptions{}
+
+	bench := &Command{
+		Name:  "bench",
+		Short: "压测内存缓存或Redis",
+		Flags: func(fs *flag.FlagSet, cf *configFlags) {
+			fs.StringVar(&backend, "backend", "memory", "缓存类型: memory 或 redis")
+			fs.IntVar(&opts.Ops, "n", 100000, "总操作数，读写各占一半")
+			fs.IntVar(&opts.Concurrency, "c", runtime.NumCPU(), "并发数")
+			fs.IntVar(&opts.Keys, "keys", 1000, "键的数量")
+			fs.IntVar(&opts.ValueSize, "value-size", 100, "值的字节数")
+			cf.String(fs, "redis-addr", "redis.addr", "Redis地址")
+			cf.String(fs, "redis-password", "redis.password", "Redis密码")
+			cf.String(fs, "redis-db", "redis.db", "Redis数据库编号")
+		},
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if len(args) > 0 {
+				return usageErrorf("多余的参数 %q", args)
+			}
+			if opts.Ops <= 0 || opts.Concurrency <= 0 || opts.Keys <= 0 || opts.ValueSize < 0 {
+				return usageErrorf("-n、-c、-keys 必须大于0，-value-size 不能为负数")
+			}
+
+			var result cache_persist.BenchResult
+			switch backend {
+			case "memory":
+				result = cache_persist.BenchMemoryCache(ctx, cache_persist.NewMemoryCache(), opts)
+			case "redis":
+				cfg, err := env.Config()
+				if err != nil {
+					return err
+				}
+				cache := cache_persist.NewRedisCache(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+				defer cache.Close()
+				if err := cache.Ping(); err != nil {
+					return fmt.Errorf("无法连接到Redis %s: %w", cfg.Redis.Addr, err)
+				}
+				result = cache_persist.BenchRedisCache(ctx, cache, opts)
+			default:
+				return usageErrorf("未知的缓存类型 %q", backend)
+			}
+
+			fmt.Fprintf(env.Stdout, "缓存: %s，并发: %d，键: %d，值: %d 字节\n", backend, opts.Concurrency, opts.Keys, opts.ValueSize)
+			fmt.Fprint(env.Stdout, result)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if result.Errors > 0 {
+				return fmt.Errorf("%d 次操作失败", result.Errors)
+			}
+			return nil
+		},
+	}
+
+	return &Command{Name: "cache", Short: "缓存工具", Commands: []*Command{bench}}
+}
+
+// newDBCommand db migrate up|down|status|redo|unlock
+func newDBCommand() *Command {
+	dbFlags := func(fs *flag.FlagSet, cf *configFlags) {
+		cf.String(fs, "driver", "database.driver", "数据库驱动: sqlite 或 mysql")
+		cf.String(fs, "dsn", "database.dsn", "数据源名称")
+	}
+	// migrateCommand 打开数据库并创建迁移器后执行 fn
+	migrateCommand := func(name, short string, flags func(fs *flag.FlagSet), fn func(ctx context.Context, env *Env, m *database.Migrator) error) *Command {
+		return &Command{
+			Name:  name,
+			Short: short,
+			Flags: func(fs *flag.FlagSet, cf *configFlags) {
+				dbFlags(fs, cf)
+				if flags != nil {
+					flags(fs)
+				}
+			},
+			Run: func(ctx context.Context, env *Env, args []string) error {
+				if len(args) > 0 {
+					return usageErrorf("多余的参数 %q", args)
+				}
+				cfg, err := env.Config()
+				if err != nil {
+					return err
+				}
+				db, err := database.Open(ctx, cfg.Database)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+				migrator, err := database.NewMigrator(db, cfg.Database.Driver)
+				if err != nil {
+					return err
+				}
+				return fn(ctx, env, migrator)
+			},
+		}
+	}
+	printMigrations := func(env *Env, action string, migrations []database.Migration) {
+		for _, m := range migrations {
+			fmt.Fprintf(env.Stdout, "%s %s\n", action, m)
+		}
+	}
+
+	var to int64
+	var steps int
+	migrate := &Command{
+		Name:  "migrate",
+		Short: "版本化的数据库迁移",
+		Commands: []*Command{
+			migrateCommand("up", "执行未执行的迁移", func(fs *flag.FlagSet) {
+				fs.Int64Var(&to, "to", 0, "只执行到该版本，0表示全部")
+			}, func(ctx context.Context, env *Env, m *database.Migrator) error {
+				done, err := m.Up(ctx, to)
+				printMigrations(env, "已执行", done)
+				if err == nil && len(done) == 0 {
+					fmt.Fprintln(env.Stdout, "没有需要执行的迁移")
+				}
+				return err
+			}),
+			migrateCommand("down", "回滚最近的迁移", func(fs *flag.FlagSet) {
+				fs.IntVar(&steps, "steps", 1, "回滚的迁移数")
+			}, func(ctx context.Context, env *Env, m *database.Migrator) error {
+				if steps <= 0 {
+					return usageErrorf("-steps 必须大于0")
+				}
+				done, err := m.Down(ctx, steps)
+				printMigrations(env, "已回滚", done)
+				return err
+			}),
+			migrateCommand("redo", "回滚并重新执行最近一次迁移", nil, func(ctx context.Context, env *Env, m *database.Migrator) error {
+				migration, err := m.Redo(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(env.Stdout, "已重做 %s\n", migration)
+				return nil
+			}),
+			migrateCommand("status", "列出迁移及执行状态", nil, func(ctx context.Context, env *Env, m *database.Migrator) error {
+				statuses, err := m.Status(ctx)
+				if err != nil {
+					return err
+				}
+				for _, s := range statuses {
+					state := "未执行"
+					switch {
+					case s.Missing:
+						state = "已执行，但找不到迁移文件"
+					case s.Applied:
+						state = "已执行于 " + s.AppliedAt.Local().Format(time.DateTime)
+					}
+					fmt.Fprintf(env.Stdout, "%s  %s\n", s.Migration, state)
+				}
+				return nil
+			}),
+			migrateCommand("unlock", "清除异常退出后残留的迁移锁(SQLite)", nil, func(ctx context.Context, env *Env, m *database.Migrator) error {
+				return m.ForceUnlock(ctx)
+			}),
+		},
+	}
+
+	return &Command{Name: "db", Short: "数据库工具", Commands: []*Command{migrate}}
+}
+
+// newFilesCommand files split|merge|hash
+func newFilesCommand() *Command {
+	var chunkSize, outputDir string
+	split := &Command{
+		Name:  "split",
+		Args:  "<文件>",
+		Short: "按大小切分文件",
+		Flags: func(fs *flag.FlagSet, cf *configFlags) {
+			fs.StringVar(&chunkSize, "size", "2M", "分片大小，支持 K、M、G 后缀")
+			fs.StringVar(&outputDir, "out", ".", "分片输出目录")
+		},
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if len(args) != 1 {
+				return usageErrorf("需要且只需要一个文件")
+			}
+			size, err := parseSize(chunkSize)
+			if err != nil {
+				return usageErrorf("无效的分片大小 %q: %v", chunkSize, err)
+			}
+			parts, err := filestorage.SplitFile(args[0], outputDir, size)
+			if err != nil {
+				return err
+			}
+			for _, part := range parts {
+				fmt.Fprintln(env.Stdout, part)
+			}
+			return nil
+		},
+	}
+
+	var output string
+	merge := &Command{
+		Name:  "merge",
+		Args:  "<分片>...",
+		Short: "按顺序合并分片",
+		Flags: func(fs *flag.FlagSet, cf *configFlags) {
+			fs.StringVar(&output, "o", "", "合并后的文件(必填)")
+		},
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if output == "" || len(args) == 0 {
+				return usageErrorf("需要 -o 和至少一个分片")
+			}
+			n, err := filestorage.MergeFiles(output, args)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(env.Stdout, "已合并 %d 个分片到 %s (%d 字节)\n", len(args), output, n)
+			return nil
+		},
+	}
+
+	var algorithm string
+	hash := &Command{
+		Name:  "hash",
+		Args:  "<文件>...",
+		Short: "计算文件哈希，输出格式与 sha256sum 相同",
+		Flags: func(fs *flag.FlagSet, cf *configFlags) {
+			fs.StringVar(&algorithm, "algo", "sha256", "哈希算法: "+strings.Join(filestorage.HashAlgorithms, "、"))
+		},
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if len(args) == 0 {
+				return usageErrorf("需要至少一个文件")
+			}
+			failed := 0
+			for _, file := range args {
+				sum, err := filestorage.HashFile(file, algorithm)
+				if err != nil {
+					fmt.Fprintf(env.Stderr, "%s: %v\n", file, err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(env.Stdout, "%s  %s\n", sum, file)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 个文件计算失败", failed)
+			}
+			return nil
+		},
+	}
+
+	return &Command{Name: "files", Short: "文件工具", Commands: []*Command{split, merge, hash}}
+}
+
+// parseSize 解析带 K/M/G 后缀的字节数
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	switch suffix := strings.ToUpper(s[len(s)-min(len(s), 1):]); suffix {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("必须大于0")
+	}
+	return n * multiplier, nil
+}
+
+// newHTTPCommand http get
+func newHTTPCommand() *Command {
+	var (
+		headers       []string
+		opts          httpclient.GetOptions
+		includeHeader bool
+		output        string
+	)
+	get := &Command{
+		Name:  "get",
+		Args:  "<URL>",
+		Short: "发送GET请求，状态码不是2xx时以失败退出",
+		Flags: func(fs *flag.FlagSet, cf *configFlags) {
+			fs.Func("H", "请求头，格式为 \"名称: 值\"，可重复", func(h string) error {
+				if !strings.Contains(h, ":") {
+					return fmt.Errorf("格式应为 \"名称: 值\"")
+				}
+				headers = append(headers, h)
+				return nil
+			})
+			fs.DurationVar(&opts.Timeout, "timeout", httpclient.DefaultTimeout, "单次请求超时")
+			fs.IntVar(&opts.Retries, "retries", 0, "网络错误、429和5xx时的重试次数")
+			fs.BoolVar(&includeHeader, "i", false, "输出响应头")
+			fs.StringVar(&output, "o", "", "将响应体写入文件")
+		},
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if len(args) != 1 {
+				return usageErrorf("需要且只需要一个URL")
+			}
+			opts.Header = make(http.Header)
+			for _, h := range headers {
+				name, value, _ := strings.Cut(h, ":")
+				opts.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+			}
+
+			resp, err := httpclient.Get(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if includeHeader {
+				fmt.Fprintf(env.Stdout, "%s %s\n", resp.Proto, resp.Status)
+				resp.Header.Write(env.Stdout)
+				fmt.Fprintln(env.Stdout)
+			}
+			var body io.Writer = env.Stdout
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				body = file
+			}
+			if _, err := io.Copy(body, resp.Body); err != nil {
+				return fmt.Errorf("读取响应失败: %w", err)
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("服务器返回 %s", resp.Status)
+			}
+			return nil
+		},
+	}
+
+	return &Command{Name: "http", Short: "HTTP客户端工具", Commands: []*Command{get}}
+}
+
+// newConfigCommand config show
+func newConfigCommand() *Command {
+	show := &Command{
+		Name:  "show",
+		Short: "输出合并后的配置，密钥已隐藏",
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			cfg, err := env.Config()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(env.Stdout, cfg)
+			return nil
+		},
+	}
+	return &Command{Name: "config", Short: "配置工具", Commands: []*Command{show}}
+}
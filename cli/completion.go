@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 补全脚本都调用隐藏的 __complete 命令获取候选词，命令树变化后无需重新生成脚本
+const bashCompletion = `# %[1]s bash 补全，加载方式: source <(%[1]s completion bash)
+_%[2]s_complete() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	local candidates
+	candidates="$("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)"
+	COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+}
+complete -o default -F _%[2]s_complete %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+# %[1]s zsh 补全，加载方式: source <(%[1]s completion zsh)
+_%[2]s_complete() {
+	local -a candidates
+	candidates=(${(f)"$(${words[1]} __complete ${words[2,CURRENT-1]} 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+compdef _%[2]s_complete %[1]s
+`
+
+const fishCompletion = `# %[1]s fish 补全，加载方式: %[1]s completion fish | source
+complete -c %[1]s -a '(%[1]s __complete (commandline -opc)[2..-1] 2>/dev/null)'
+`
+
+// newCompletionCommand completion bash|zsh|fish
+func newCompletionCommand() *Command {
+	script := func(template string) func(context.Context, *Env, []string) error {
+		return func(ctx context.Context, env *Env, args []string) error {
+			name := programName()
+			fmt.Fprintf(env.Stdout, template, name, strings.NewReplacer("-", "_", ".", "_").Replace(name))
+			return nil
+		}
+	}
+	return &Command{
+		Name:  "completion",
+		Short: "输出 shell 补全脚本",
+		Commands: []*Command{
+			{Name: "bash", Short: "bash 补全脚本", Run: script(bashCompletion)},
+			{Name: "zsh", Short: "zsh 补全脚本", Run: script(zshCompletion)},
+			{Name: "fish", Short: "fish 补全脚本", Run: script(fishCompletion)},
+		},
+	}
+}
+
+// newCompleteCommand __complete <已输入的词>...，每行输出一个候选词
+func newCompleteCommand() *Command {
+	return &Command{
+		Name:   "__complete",
+		Hidden: true,
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			for _, candidate := range completions(newRootCommand(), args) {
+				fmt.Fprintln(env.Stdout, candidate)
+			}
+			return nil
+		},
+	}
+}
+
+// completions 根据已输入的词返回当前位置的候选词：子命令、参数名或示例名
+func completions(root *Command, words []string) []string {
+	cmd := root
+	for _, word := range words {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if sub := cmd.find(word); sub != nil && !sub.Hidden {
+			cmd = sub
+		}
+	}
+
+	var candidates []string
+	for _, sub := range visibleCommands(cmd) {
+		candidates = append(candidates, sub.Name)
+	}
+	if cmd.Name == "demo" {
+		candidates = append(candidates, demoNames()...)
+	}
+
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	(&globalFlags{overrides: make(map[string]string)}).register(fs)
+	if cmd.Flags != nil {
+		cmd.Flags(fs, &configFlags{overrides: make(map[string]string)})
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		candidates = append(candidates, "--"+f.Name)
+	})
+	return candidates
+}
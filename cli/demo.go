@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-basics/cache_persist"
+	"go-basics/concurrency"
+	"go-basics/config"
+	"go-basics/constants"
+	"go-basics/controlflow"
+	"go-basics/database"
+	"go-basics/datatypes"
+	"go-basics/filestorage"
+	"go-basics/functions"
+	"go-basics/httpclient"
+	"go-basics/interfaces"
+	"go-basics/server"
+	"go-basics/stdlib"
+	"go-basics/structs"
+	"go-basics/variables"
+)
+
+// demo 一个示例，按学习顺序排列
+type demo struct {
+	name  string
+	title string
+	run   func()
+}
+
+var demos = []demo{
+	{"variables", "变量", variables.DemonstrateVariables},
+	{"constants", "常量", constants.DemonstrateConstants},
+	{"datatypes", "数据类型", datatypes.DemonstrateDataTypes},
+	{"controlflow", "控制结构", controlflow.DemonstrateControlFlow},
+	{"functions", "函数", functions.DemonstrateFunctions},
+	{"structs", "结构体和方法", structs.DemonstrateStructs},
+	{"interfaces", "接口", interfaces.DemonstrateInterfaces},
+	{"goroutines", "并发编程: Goroutines", concurrency.DemonstrateGoroutines},
+	{"channels", "并发编程: Channels", concurrency.DemonstrateChannels},
+	{"concurrency", "并发编程: 实际应用", concurrency.DemonstratePracticalConcurrency},
+	{"context", "并发编程: Context包", concurrency.DemonstrateContext},
+	{"stdlib", "标准库", stdlib.DemonstrateStdLib},
+	{"server", "Go Http服务器", server.DemonstrateServer},
+	{"database", "数据库操作", database.DemonstrateDatabase},
+	{"migrations", "数据库迁移", database.DemonstrateMigrations},
+	{"tx-retry", "事务重试", database.DemonstrateTransactionRetry},
+	{"rw-split", "读写分离", database.DemonstrateReadWriteSplit},
+	{"audit", "审计日志", database.DemonstrateAudit},
+	{"bulk-insert", "批量插入", database.DemonstrateBulkInsert},
+	{"filestorage", "文件存储", filestorage.DemonstrateFileStorage},
+	{"httpclient", "HTTP客户端", httpclient.DemonstrateHTTPClient},
+	{"cache", "内存缓存", cache_persist.DemonstrateMemoryCache},
+	{"config", "配置加载", config.DemonstrateConfig},
+}
+
+// newDemoCommand demo [名称]...
+func newDemoCommand() *Command {
+	return &Command{
+		Name:  "demo",
+		Args:  "[名称]...",
+		Short: "运行学习示例，不带参数时列出所有示例",
+		Run: func(ctx context.Context, env *Env, args []string) error {
+			if len(args) == 0 {
+				fmt.Fprintln(env.Stdout, "可用的示例:")
+				for _, d := range demos {
+					fmt.Fprintf(env.Stdout, "  %-12s %s\n", d.name, d.title)
+				}
+				return nil
+			}
+
+			selected := make([]demo, 0, len(args))
+			for _, name := range args {
+				d, ok := findDemo(name)
+				if !ok {
+					return usageErrorf("未知示例 %q，可选: %s", name, strings.Join(demoNames(), ", "))
+				}
+				selected = append(selected, d)
+			}
+			fmt.Fprintln(env.Stdout, "=== Go语言基础语法学习 ===")
+			for _, d := range selected {
+				fmt.Fprintf(env.Stdout, "\n=== %s ===\n", d.title)
+				d.run()
+			}
+			return nil
+		},
+	}
+}
+
+func findDemo(name string) (demo, bool) {
+	for _, d := range demos {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return demo{}, false
+}
+
+func demoNames() []string {
+	names := make([]string, len(demos))
+	for i, d := range demos {
+		names[i] = d.name
+	}
+	return names
+}
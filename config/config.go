@@ -0,0 +1,132 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config 应用配置
+//
+// 每个字段的标签含义：
+//
+//	key      YAML/TOML 中的键名
+//	env      环境变量名，.env 文件使用相同的变量名
+//	default  默认值
+//	validate 校验规则，与 gin 的 binding 标签相同(go-playground/validator)
+//	secret   打印时隐藏
+//	reload   允许热加载，其余字段修改后需要重启才生效
+type Config struct {
+	Server   ServerConfig   `key:"server"`
+	Redis    RedisConfig    `key:"redis"`
+	Database DatabaseConfig `key:"database"`
+	COS      COSConfig      `key:"cos"`
+	APIs     APIConfig      `key:"apis"`
+}
+
+// ServerConfig HTTP服务器配置
+type ServerConfig struct {
+	Addr          string `key:"addr" env:"SERVER_ADDR" default:":8080" validate:"required"`
+	TLSCert       string `key:"tls_cert" env:"SERVER_TLS_CERT" validate:"required_with=TLSKey"`
+	TLSKey        string `key:"tls_key" env:"SERVER_TLS_KEY" validate:"required_with=TLSCert"`
+	TLSSelfSigned bool   `key:"tls_self_signed" env:"SERVER_TLS_SELF_SIGNED"`
+	ClientCA      string `key:"client_ca" env:"SERVER_CLIENT_CA"`
+	H2C           bool   `key:"h2c" env:"SERVER_H2C"`
+	// 响应压缩阈值(字节)
+	CompressionMinSize int `key:"compression_min_size" env:"SERVER_COMPRESSION_MIN_SIZE" default:"1024" validate:"gte=0"`
+	// v1 接口的计划下线日期(YYYY-MM-DD)，设置后 v1 标记为弃用并输出 Deprecation/Sunset 头
+	V1Sunset string `key:"v1_sunset" env:"SERVER_V1_SUNSET" validate:"omitempty,datetime=2006-01-02"`
+	// 租户管理接口的令牌和租户JWT的签名密钥，没有默认值，启动RESTful服务时必须设置(见 RequireSecrets)
+	AdminToken string `key:"admin_token" env:"SERVER_ADMIN_TOKEN" secret:"true"`
+	JWTSecret  string `key:"jwt_secret" env:"SERVER_JWT_SECRET" secret:"true"`
+}
+
+// RequireSecrets 检查管理令牌和JWT密钥是否已设置。
+// 只有用到它们的服务才需要，所以不放在 validate 标签中，以免其他命令也必须配置
+func (c ServerConfig) RequireSecrets() error {
+	var missing []string
+	if c.AdminToken == "" {
+		missing = append(missing, "server.admin_token")
+	}
+	if c.JWTSecret == "" {
+		missing = append(missing, "server.jwt_secret")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("配置校验失败: %s 未设置", strings.Join(missing, "、"))
+	}
+	return nil
+}
+
+// RedisConfig Redis配置
+type RedisConfig struct {
+	Addr     string `key:"addr" env:"REDIS_ADDR" default:"localhost:6379" validate:"required"`
+	Password string `key:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `key:"db" env:"REDIS_DB" default:"0" validate:"gte=0"`
+}
+
+// DatabaseConfig 数据库及连接池配置，连接池参数可以热加载
+type DatabaseConfig struct {
+	Driver          string        `key:"driver" env:"DB_DRIVER" default:"sqlite" validate:"oneof=sqlite mysql"`
+	DSN             string        `key:"dsn" env:"DB_DSN" default:":memory:" validate:"required" secret:"true"`
+	MaxOpenConns    int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25" validate:"gte=0" reload:"true"`
+	MaxIdleConns    int           `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10" validate:"gte=0" reload:"true"`
+	ConnMaxLifetime time.Duration `key:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"5m" reload:"true"`
+	ConnMaxIdleTime time.Duration `key:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"10m" reload:"true"`
+}
+
+// ApplyPool 将连接池参数应用到 db，sql.DB 的这些设置可以在运行时修改
+func (c DatabaseConfig) ApplyPool(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// COSConfig 腾讯云对象存储配置
+type COSConfig struct {
+	BucketURL string `key:"bucket_url" env:"COS_BUCKET_URL" validate:"omitempty,url"`
+	SecretID  string `key:"secret_id" env:"COS_SECRET_ID" secret:"true"`
+	SecretKey string `key:"secret_key" env:"COS_SECRET_KEY" secret:"true"`
+}
+
+// APIConfig 第三方API密钥
+type APIConfig struct {
+	OpenWeatherKey  string `key:"openweather_key" env:"OPENWEATHER_API_KEY" secret:"true"`
+	ExchangeRateKey string `key:"exchangerate_key" env:"EXCHANGERATE_API_KEY" secret:"true"`
+	NewsKey         string `key:"news_key" env:"NEWS_API_KEY" secret:"true"`
+}
+
+// Options 配置来源
+type Options struct {
+	Files        []string // YAML(.yaml/.yml)或TOML(.toml)文件，按顺序加载，后加载的覆盖先加载的
+	RequireFiles bool     // 文件不存在时是否报错，否则跳过
+	EnvFile      string   // .env 文件，不存在时跳过
+	Environ      []string // 环境变量，为nil时使用 os.Environ()
+	// 按键路径(如 server.addr)覆盖配置，优先级最高，用于命令行参数
+	Overrides map[string]string
+}
+
+// DefaultOptions 默认从当前目录的 config.yaml、config.toml 和 .env 加载
+// 设置了 CONFIG_FILE 环境变量时只加载该文件，且文件必须存在
+func DefaultOptions() Options {
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		return Options{Files: []string{file}, RequireFiles: true, EnvFile: ".env"}
+	}
+	return Options{Files: []string{"config.yaml", "config.toml"}, EnvFile: ".env"}
+}
+
+// Load 按 默认值、配置文件、.env、环境变量、Overrides 的顺序加载配置并校验
+func Load(opts Options) (*Config, error) {
+	cfg := &Config{}
+	if err := load(cfg, opts); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadDefault 使用 DefaultOptions 加载配置
+func LoadDefault() (*Config, error) {
+	return Load(DefaultOptions())
+}
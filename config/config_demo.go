@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DemonstrateConfig 展示配置的加载顺序、脱敏输出和热加载
+func DemonstrateConfig() {
+	fmt.Println("=== 配置加载示例 ===")
+
+	dir, err := os.MkdirTemp("", "go-basics-config")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlFile := filepath.Join(dir, "config.yaml")
+	tomlFile := filepath.Join(dir, "config.toml")
+	envFile := filepath.Join(dir, ".env")
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			log.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+	writeFile(yamlFile, "server:\n  addr: \":9000\"\ndatabase:\n  max_open_conns: 50\n")
+	writeFile(tomlFile, "[redis]\naddr = \"redis.internal:6379\"\n")
+	writeFile(envFile, "REDIS_PASSWORD=from-dotenv\nDB_MAX_IDLE_CONNS=20\n")
+
+	opts := Options{
+		Files:   []string{yamlFile, tomlFile},
+		EnvFile: envFile,
+		// 环境变量优先级最高
+		Environ: []string{"DB_MAX_IDLE_CONNS=30", "COS_SECRET_KEY=super-secret"},
+	}
+
+	fmt.Println("\n1. 默认值 < YAML/TOML < .env < 环境变量")
+	manager, err := NewManager(opts)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	cfg := manager.Current()
+	fmt.Printf("server.addr=%s (YAML)\n", cfg.Server.Addr)
+	fmt.Printf("redis.addr=%s (TOML)\n", cfg.Redis.Addr)
+	fmt.Printf("database.max_idle_conns=%d (环境变量覆盖 .env)\n", cfg.Database.MaxIdleConns)
+	fmt.Printf("database.conn_max_lifetime=%s (默认值)\n", cfg.Database.ConnMaxLifetime)
+
+	fmt.Println("\n2. 打印配置时隐藏密钥")
+	fmt.Print(cfg)
+
+	fmt.Println("\n3. 校验失败")
+	_, err = Load(Options{Environ: []string{"SERVER_ADDR=", "DB_DRIVER=oracle"}})
+	fmt.Printf("错误: %v\n", err)
+
+	fmt.Println("\n4. 热加载")
+	manager.OnChange(func(cfg *Config) {
+		fmt.Printf("回调: 连接池调整为 max_open_conns=%d\n", cfg.Database.MaxOpenConns)
+	})
+	writeFile(yamlFile, "server:\n  addr: \":9001\"\ndatabase:\n  max_open_conns: 80\n")
+	applied, ignored, err := manager.Reload()
+	if err != nil {
+		log.Fatalf("热加载失败: %v", err)
+	}
+	fmt.Printf("已应用: %v\n", applied)
+	fmt.Printf("需重启: %v (当前仍为 %s)\n", ignored, manager.Current().Server.Addr)
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// load 依次应用各个配置来源，后面的覆盖前面的
+func load(cfg *Config, opts Options) error {
+	root := reflect.ValueOf(cfg).Elem()
+
+	// 1. 默认值
+	if err := walkFields(root, "", func(path string, field reflect.Value, sf reflect.StructField) error {
+		if value, ok := sf.Tag.Lookup("default"); ok {
+			return setField(field, path, value)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// 2. 配置文件
+	for _, file := range opts.Files {
+		if err := loadFile(root, file); err != nil {
+			if errors.Is(err, os.ErrNotExist) && !opts.RequireFiles {
+				continue
+			}
+			return err
+		}
+	}
+
+	// 3. .env 文件，只作为环境变量的补充，不覆盖已设置的环境变量
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := make(map[string]string)
+	if opts.EnvFile != "" {
+		dotenv, err := godotenv.Read(opts.EnvFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("读取 %s 失败: %w", opts.EnvFile, err)
+		}
+		for name, value := range dotenv {
+			env[name] = value
+		}
+	}
+
+	// 4. 环境变量
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+	if err := walkFields(root, "", func(path string, field reflect.Value, sf reflect.StructField) error {
+		if name := sf.Tag.Get("env"); name != "" {
+			if value, ok := env[name]; ok {
+				return setField(field, name, value)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// 5. 覆盖项
+	if len(opts.Overrides) > 0 {
+		unknown := make(map[string]bool, len(opts.Overrides))
+		for key := range opts.Overrides {
+			unknown[key] = true
+		}
+		if err := walkFields(root, "", func(path string, field reflect.Value, sf reflect.StructField) error {
+			value, ok := opts.Overrides[path]
+			if !ok {
+				return nil
+			}
+			delete(unknown, path)
+			return setField(field, path, value)
+		}); err != nil {
+			return err
+		}
+		for key := range unknown {
+			return fmt.Errorf("未知配置项 %s", key)
+		}
+	}
+
+	return validate(cfg)
+}
+
+// loadFile 按扩展名解析YAML或TOML文件
+func loadFile(root reflect.Value, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("不支持的配置文件格式 %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", file, err)
+	}
+	if err := applyValues(root, "", values); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// applyValues 将文件中的键值写入结构体，未知的键视为错误，避免拼写错误被忽略
+func applyValues(v reflect.Value, prefix string, values map[string]interface{}) error {
+	fields := make(map[string]int)
+	for i := 0; i < v.NumField(); i++ {
+		fields[v.Type().Field(i).Tag.Get("key")] = i
+	}
+
+	for key, value := range values {
+		path := joinPath(prefix, key)
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("未知配置项 %s", path)
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			section, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("配置项 %s 应为一个表", path)
+			}
+			if err := applyValues(field, path, section); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setField(field, path, fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkFields 遍历所有叶子字段，path 为以点分隔的键路径
+func walkFields(v reflect.Value, prefix string, fn func(path string, field reflect.Value, sf reflect.StructField) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		path := joinPath(prefix, sf.Tag.Get("key"))
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walkFields(field, path, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(path, field, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField 将字符串解析为字段的类型，name 用于错误信息
+func setField(field reflect.Value, name, raw string) error {
+	raw = strings.TrimSpace(raw)
+	var err error
+	switch {
+	case field.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(raw); err == nil {
+			field.SetInt(int64(d))
+		}
+	case field.Kind() == reflect.String:
+		field.SetString(raw)
+	case field.Kind() == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			field.SetBool(b)
+		}
+	case field.CanInt():
+		var n int64
+		if n, err = strconv.ParseInt(raw, 10, field.Type().Bits()); err == nil {
+			field.SetInt(n)
+		}
+	case field.CanUint():
+		var n uint64
+		if n, err = strconv.ParseUint(raw, 10, field.Type().Bits()); err == nil {
+			field.SetUint(n)
+		}
+	case field.CanFloat():
+		var f float64
+		if f, err = strconv.ParseFloat(raw, field.Type().Bits()); err == nil {
+			field.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("配置项 %s 的类型 %s 不受支持", name, field.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("配置项 %s 的值 %q 无效: %w", name, raw, err)
+	}
+	return nil
+}
+
+// validator 错误中使用配置的键名
+var configValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		return sf.Tag.Get("key")
+	})
+	return v
+}()
+
+// validate 按 validate 标签校验配置，汇总所有错误
+func validate(cfg *Config) error {
+	err := configValidator.Struct(cfg)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	messages := make([]string, len(errs))
+	for i, fe := range errs {
+		// Namespace 形如 Config.server.addr
+		_, path, _ := strings.Cut(fe.Namespace(), ".")
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+		messages[i] = fmt.Sprintf("%s 不满足 %s", path, rule)
+	}
+	return fmt.Errorf("配置校验失败: %s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "******"
+
+// String 按 键=值 逐行输出配置，secret 字段被隐藏
+func (c Config) String() string {
+	var b strings.Builder
+	walkFields(reflect.ValueOf(&c).Elem(), "", func(path string, field reflect.Value, sf reflect.StructField) error {
+		value := fmt.Sprint(field.Interface())
+		if sf.Tag.Get("secret") == "true" && value != "" {
+			value = redacted
+		}
+		fmt.Fprintf(&b, "%s=%s\n", path, value)
+		return nil
+	})
+	return b.String()
+}
+
+// GoString 让 %#v 同样隐藏 secret 字段
+func (c Config) GoString() string {
+	return c.String()
+}
+
+// Manager 持有当前配置，并在配置来源变化时热加载 reload 字段
+type Manager struct {
+	opts        Options
+	mutex       sync.RWMutex
+	current     *Config
+	modTimes    map[string]time.Time
+	subscribers []func(*Config)
+}
+
+// NewManager 加载配置并创建管理器
+func NewManager(opts Options) (*Manager, error) {
+	cfg, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{opts: opts, current: cfg, modTimes: sourceModTimes(opts)}, nil
+}
+
+// Current 返回当前配置，调用方不应修改返回值
+func (m *Manager) Current() *Config {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.current
+}
+
+// OnChange 注册热加载回调，参数为新的配置
+func (m *Manager) OnChange(fn func(*Config)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Reload 重新加载配置，只应用带 reload 标签的字段
+// 返回已应用的键，以及变化了但需要重启才能生效的键
+func (m *Manager) Reload() (applied, ignored []string, err error) {
+	next, err := Load(m.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mutex.Lock()
+	merged := *m.current
+	nextValue := reflect.ValueOf(next).Elem()
+	walkFields(reflect.ValueOf(&merged).Elem(), "", func(path string, field reflect.Value, sf reflect.StructField) error {
+		newField := fieldByPath(nextValue, path)
+		if reflect.DeepEqual(field.Interface(), newField.Interface()) {
+			return nil
+		}
+		if sf.Tag.Get("reload") == "true" {
+			field.Set(newField)
+			applied = append(applied, path)
+		} else {
+			ignored = append(ignored, path)
+		}
+		return nil
+	})
+	if len(applied) > 0 {
+		m.current = &merged
+	}
+	m.modTimes = sourceModTimes(m.opts)
+	subscribers := m.subscribers
+	m.mutex.Unlock()
+
+	if len(applied) > 0 {
+		for _, fn := range subscribers {
+			fn(&merged)
+		}
+	}
+	return applied, ignored, nil
+}
+
+// Watch 定期检查配置文件和 .env 的修改时间，变化时热加载
+// 环境变量在进程内不会变化，不需要监视
+func (m *Manager) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.mutex.RLock()
+				changed := !reflect.DeepEqual(m.modTimes, sourceModTimes(m.opts))
+				m.mutex.RUnlock()
+				if !changed {
+					continue
+				}
+				applied, ignored, err := m.Reload()
+				if err != nil {
+					log.Printf("[配置] 热加载失败，继续使用当前配置: %v", err)
+					continue
+				}
+				if len(applied) > 0 {
+					log.Printf("[配置] 已热加载: %s", strings.Join(applied, ", "))
+				}
+				if len(ignored) > 0 {
+					log.Printf("[配置] 以下配置需要重启才能生效: %s", strings.Join(ignored, ", "))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// sourceModTimes 返回各配置文件的修改时间，不存在的文件记为零值
+func sourceModTimes(opts Options) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, file := range append(opts.Files, opts.EnvFile) {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		} else {
+			times[file] = time.Time{}
+		}
+	}
+	return times
+}
+
+// fieldByPath 按 walkFields 生成的键路径查找字段
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, key := range strings.Split(path, ".") {
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("key") == key {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
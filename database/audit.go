@@ -0,0 +1,374 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 审计记录的操作类型
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// Auditable 由需要审计的模型实现，返回审计记录中的实体名
+type Auditable interface {
+	AuditEntity() string
+}
+
+// AuditEntity 实现 Auditable
+func (GormUser) AuditEntity() string { return "user" }
+
+// AuditEntity 实现 Auditable
+func (Post) AuditEntity() string { return "post" }
+
+// AuditRecord 一次行变更的审计记录，Before/After 是变更前后的列值(JSON)：
+// 创建只有 After，彻底删除只有 Before，更新和软删除只包含变化的列
+type AuditRecord struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// TableName 审计表只能追加，由迁移 0006 的触发器保证
+func (AuditRecord) TableName() string {
+	return "audit_log"
+}
+
+// AuditInfo 写入审计记录的操作者和请求ID
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo 返回携带操作者和请求ID的 ctx，通过 db.WithContext(ctx) 执行的变更都记录它们
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// AuditInfoFrom 返回 ctx 中的操作者和请求ID，没有时为零值
+func AuditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+// AuditMiddleware 从 X-Request-ID 请求头读取请求ID(没有时生成并写回响应头)，
+// 用 actor 从请求中取得操作者，一起放入请求的 ctx
+func AuditMiddleware(next http.Handler, actor func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			requestID = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		info := AuditInfo{RequestID: requestID}
+		if actor != nil {
+			info.Actor = actor(r)
+		}
+		next.ServeHTTP(w, r.WithContext(WithAuditInfo(r.Context(), info)))
+	})
+}
+
+// Auditor 是 GORM 插件，在实现了 Auditable 的模型创建、更新和删除时写入审计记录。
+// 记录与变更在同一个事务中写入，变更回滚时记录也回滚。
+//
+// 更新和删除前按语句的条件读出受影响的行，之后再读一次比较差异，
+// 所以只能审计通过 GORM 模型执行的变更，db.Exec 执行的原始SQL不会被记录。
+type Auditor struct{}
+
+// NewAuditor 创建审计插件，通过 db.Use 注册
+func NewAuditor() *Auditor {
+	return &Auditor{}
+}
+
+// 变更前的行，在同一语句的 before 和 after 回调之间传递
+const auditBeforeKey = "audit:before"
+
+// Name 实现 gorm.Plugin
+func (a *Auditor) Name() string {
+	return "audit"
+}
+
+// Initialize 实现 gorm.Plugin，回调在事务开始之后、提交之前执行
+func (a *Auditor) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").
+			Register("audit:after_create", a.afterCreate),
+		cb.Update().After("gorm:begin_transaction").Before("gorm:update").
+			Register("audit:before_update", a.before),
+		cb.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").
+			Register("audit:after_update", a.after(AuditUpdate)),
+		cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").
+			Register("audit:before_delete", a.before),
+		cb.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").
+			Register("audit:after_delete", a.after(AuditDelete)),
+	)
+}
+
+// auditEntity 返回语句模型的实体名，模型不需要审计时返回 false
+func auditEntity(db *gorm.DB) (string, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return "", false
+	}
+	auditable, ok := reflect.New(db.Statement.Schema.ModelType).Interface().(Auditable)
+	if !ok {
+		return "", false
+	}
+	return auditable.AuditEntity(), true
+}
+
+func (a *Auditor) afterCreate(db *gorm.DB) {
+	entity, ok := auditEntity(db)
+	if !ok {
+		return
+	}
+	var records []AuditRecord
+	eachModel(db.Statement.ReflectValue, func(v reflect.Value) {
+		row := modelColumns(db.Statement.Context, db.Statement.Schema, v)
+		records = append(records, newAuditRecord(db, entity, AuditCreate, row[db.Statement.Schema.PrioritizedPrimaryField.DBName], nil, row))
+	})
+	a.write(db, records)
+}
+
+// before 读出语句将要修改的行
+func (a *Auditor) before(db *gorm.DB) {
+	if _, ok := auditEntity(db); !ok {
+		return
+	}
+	rows, err := a.load(db, a.conditions(db), db.Statement.Unscoped)
+	if err != nil {
+		db.AddError(fmt.Errorf("读取审计前的数据失败: %w", err))
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// after 按主键重新读取修改前的行，记录差异；读不到的行已被彻底删除
+func (a *Auditor) after(action string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		entity, ok := auditEntity(db)
+		if !ok {
+			return
+		}
+		value, ok := db.InstanceGet(auditBeforeKey)
+		if !ok {
+			return
+		}
+		before := value.([]map[string]interface{})
+		if len(before) == 0 {
+			return
+		}
+
+		pk := db.Statement.Schema.PrioritizedPrimaryField.DBName
+		ids := make([]interface{}, len(before))
+		for i, row := range before {
+			ids[i] = row[pk]
+		}
+		// 软删除后的行仍然存在，读取时不能排除已删除的行
+		rows, err := a.load(db, []clause.Expression{clause.IN{Column: clause.Column{Name: pk}, Values: ids}}, true)
+		if err != nil {
+			db.AddError(fmt.Errorf("读取审计后的数据失败: %w", err))
+			return
+		}
+		after := make(map[string]map[string]interface{}, len(rows))
+		for _, row := range rows {
+			after[fmt.Sprint(row[pk])] = row
+		}
+
+		var records []AuditRecord
+		for _, old := range before {
+			id := old[pk]
+			current, exists := after[fmt.Sprint(id)]
+			if !exists {
+				records = append(records, newAuditRecord(db, entity, action, id, old, nil))
+				continue
+			}
+			if oldDiff, newDiff := diffColumns(db.Statement.Schema, old, current); len(newDiff) > 0 {
+				records = append(records, newAuditRecord(db, entity, softDeleteAction(db.Statement.Schema, action, oldDiff, newDiff), id, oldDiff, newDiff))
+			}
+		}
+		a.write(db, records)
+	}
+}
+
+// conditions 返回语句的 WHERE 条件，模型带主键时加上主键条件
+func (a *Auditor) conditions(db *gorm.DB) []clause.Expression {
+	var exprs []clause.Expression
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, where.Exprs...)
+		}
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	var ids []interface{}
+	eachModel(db.Statement.ReflectValue, func(v reflect.Value) {
+		if id, zero := field.ValueOf(db.Statement.Context, v); !zero {
+			ids = append(ids, id)
+		}
+	})
+	if len(ids) > 0 {
+		exprs = append(exprs, clause.IN{Column: clause.Column{Name: field.DBName}, Values: ids})
+	}
+	return exprs
+}
+
+// load 在语句的事务中读取满足条件的行，没有条件时不读取
+func (a *Auditor) load(db *gorm.DB, exprs []clause.Expression, unscoped bool) ([]map[string]interface{}, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	query := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	if unscoped {
+		query = query.Unscoped()
+	}
+	var rows []map[string]interface{}
+	err := query.Clauses(clause.Where{Exprs: exprs}).Find(&rows).Error
+	return rows, err
+}
+
+// write 在语句的事务中写入审计记录
+func (a *Auditor) write(db *gorm.DB, records []AuditRecord) {
+	if len(records) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&records).Error; err != nil {
+		db.AddError(fmt.Errorf("写入审计记录失败: %w", err))
+	}
+}
+
+func newAuditRecord(db *gorm.DB, entity, action string, id interface{}, before, after map[string]interface{}) AuditRecord {
+	info := AuditInfoFrom(db.Statement.Context)
+	record := AuditRecord{
+		Entity:    entity,
+		EntityID:  fmt.Sprint(id),
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		CreatedAt: time.Now(),
+	}
+	// 列值都来自数据库或模型字段，可以编码为JSON
+	if before != nil {
+		record.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		record.After, _ = json.Marshal(after)
+	}
+	return record
+}
+
+// eachModel 对单个模型或模型切片中的每个元素调用 fn
+func eachModel(value reflect.Value, fn func(v reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fn(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+// modelColumns 返回模型的列值
+func modelColumns(ctx context.Context, s *schema.Schema, v reflect.Value) map[string]interface{} {
+	row := make(map[string]interface{}, len(s.DBNames))
+	for _, name := range s.DBNames {
+		value, _ := s.FieldsByDBName[name].ValueOf(ctx, v)
+		row[name] = value
+	}
+	return row
+}
+
+// diffColumns 返回值有变化的列，自动更新的时间列不算变化
+func diffColumns(s *schema.Schema, before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	oldDiff, newDiff := map[string]interface{}{}, map[string]interface{}{}
+	for _, name := range s.DBNames {
+		if s.FieldsByDBName[name].AutoUpdateTime > 0 {
+			continue
+		}
+		if !reflect.DeepEqual(before[name], after[name]) {
+			oldDiff[name], newDiff[name] = before[name], after[name]
+		}
+	}
+	return oldDiff, newDiff
+}
+
+// softDeleteAction 通过更新设置或清空软删除列时，记为删除或恢复
+func softDeleteAction(s *schema.Schema, action string, before, after map[string]interface{}) string {
+	for _, field := range s.Fields {
+		if field.FieldType != reflect.TypeOf(gorm.DeletedAt{}) {
+			continue
+		}
+		if _, changed := after[field.DBName]; !changed {
+			continue
+		}
+		switch {
+		case before[field.DBName] == nil:
+			return AuditDelete
+		case after[field.DBName] == nil:
+			return AuditRestore
+		}
+	}
+	return action
+}
+
+// AuditQuery 审计记录的查询条件，零值字段不过滤
+type AuditQuery struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	Since    time.Time // 包含
+	Until    time.Time // 不包含
+	Limit    int       // 默认 DefaultPageSize，不超过 MaxPageSize
+}
+
+// FindAuditRecords 按实体和时间范围查询审计记录，按时间倒序
+func FindAuditRecords(ctx context.Context, db *gorm.DB, q AuditQuery) ([]AuditRecord, error) {
+	query := db.WithContext(ctx).Model(&AuditRecord{})
+	if q.Entity != "" {
+		query = query.Where("entity = ?", q.Entity)
+	}
+	if q.EntityID != "" {
+		query = query.Where("entity_id = ?", q.EntityID)
+	}
+	if q.Actor != "" {
+		query = query.Where("actor = ?", q.Actor)
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		query = query.Where("created_at < ?", q.Until)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	var records []AuditRecord
+	err := query.Order("created_at DESC, id DESC").Limit(min(limit, MaxPageSize)).Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DemonstrateAudit 展示审计日志：用户和文章的每次变更都记录操作者、请求ID和前后差异
+func DemonstrateAudit() {
+	fmt.Println("=== 审计日志示例 ===")
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatalf("无法连接到数据库: %v", err)
+	}
+	// 内存数据库每个连接都是独立的库，限制为单连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("获取连接池失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.Use(NewAuditor()); err != nil {
+		log.Fatalf("注册插件失败: %v", err)
+	}
+	migrator, err := NewGormMigrator(db)
+	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		log.Fatalf("迁移失败: %v", err)
+	}
+
+	fmt.Println("\n1. 请求中创建用户和文章，操作者和请求ID来自中间件")
+	handler := AuditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GormUser{Username: "auditor", Email: "auditor@example.com", Age: 30, Posts: []Post{
+			{Title: "审计入门", Content: "谁在什么时候改了什么"},
+			{Title: "只能追加的表", Content: "审计记录不能修改和删除"},
+		}}
+		if err := db.WithContext(r.Context()).Create(&user).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "用户 %d 已创建", user.ID)
+	}), func(r *http.Request) string { return r.Header.Get("X-User") })
+	request := httptest.NewRequest(http.MethodPost, "/users", nil)
+	request.Header.Set("X-User", "alice")
+	request.Header.Set("X-Request-ID", "req-001")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	fmt.Printf("响应: %s (X-Request-ID: %s)\n", recorder.Body, recorder.Header().Get("X-Request-ID"))
+
+	fmt.Println("\n2. 后台任务修改、软删除、恢复用户")
+	start := time.Now()
+	ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "admin", RequestID: "job-42"})
+	if err := db.WithContext(ctx).Model(&GormUser{ID: 1}).Updates(map[string]interface{}{"email": "new@example.com", "age": 31}).Error; err != nil {
+		log.Fatalf("更新用户失败: %v", err)
+	}
+	users := NewGormUserRepository(db)
+	if err := users.SoftDelete(ctx, 1); err != nil {
+		log.Fatalf("删除用户失败: %v", err)
+	}
+	if _, err := users.Restore(ctx, 1); err != nil {
+		log.Fatalf("恢复用户失败: %v", err)
+	}
+	// 回滚的事务不会留下审计记录
+	db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx.Model(&GormUser{ID: 1}).Update("age", 99)
+		return errors.New("回滚")
+	})
+
+	fmt.Println("\n3. 用户1的变更历史")
+	history, err := FindAuditRecords(context.Background(), db, AuditQuery{Entity: "user", EntityID: "1"})
+	if err != nil {
+		log.Fatalf("查询审计记录失败: %v", err)
+	}
+	printAuditRecords(history)
+
+	fmt.Println("\n4. 后台任务开始以来 admin 的所有变更")
+	recent, err := FindAuditRecords(context.Background(), db, AuditQuery{Actor: "admin", Since: start})
+	if err != nil {
+		log.Fatalf("查询审计记录失败: %v", err)
+	}
+	printAuditRecords(recent)
+
+	posts, err := FindAuditRecords(context.Background(), db, AuditQuery{Entity: "post", Until: start})
+	if err != nil {
+		log.Fatalf("查询审计记录失败: %v", err)
+	}
+	fmt.Printf("后台任务开始前有 %d 条文章审计记录\n", len(posts))
+
+	fmt.Println("\n5. 审计表只能追加")
+	if err := db.Exec("UPDATE audit_log SET actor = ?", "mallory").Error; err != nil {
+		fmt.Printf("修改审计记录失败: %v\n", err)
+	}
+	if err := db.Exec("DELETE FROM audit_log").Error; err != nil {
+		fmt.Printf("删除审计记录失败: %v\n", err)
+	}
+}
+
+// printAuditRecords 按时间倒序打印审计记录
+func printAuditRecords(records []AuditRecord) {
+	for _, r := range records {
+		fmt.Printf("- %s %s %s#%s 操作者=%s 请求=%s\n",
+			r.CreatedAt.Format("15:04:05.000"), r.Action, r.Entity, r.EntityID, r.Actor, r.RequestID)
+		if r.Before != nil {
+			fmt.Printf("    前: %s\n", r.Before)
+		}
+		if r.After != nil {
+			fmt.Printf("    后: %s\n", r.After)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 单条语句允许的参数个数上限：SQLite 3.32 起为 32766，MySQL 预处理语句为 65535
+const (
+	sqliteMaxParams = 32766
+	mysqlMaxParams  = 65535
+)
+
+// DefaultBulkChunkSize 批量插入时每条语句默认的行数
+const DefaultBulkChunkSize = 500
+
+// Upsert 批量插入遇到唯一键冲突时的处理：更新 Update 中的列，Update 为空时跳过冲突的行
+type Upsert struct {
+	Conflict []string // 冲突的唯一键列，SQLite 必填，MySQL 由表上的唯一索引决定，忽略此项
+	Update   []string // 冲突时用新值覆盖的列
+}
+
+// BulkOptions 批量插入选项
+type BulkOptions struct {
+	Driver    string // sqlite(默认) 或 mysql，决定参数上限和 upsert 语法
+	ChunkSize int    // 每条语句的行数，默认 DefaultBulkChunkSize，不超过驱动参数上限允许的行数
+	Upsert    *Upsert
+}
+
+// BulkResult 批量插入的结果
+type BulkResult struct {
+	IDs        []int64 // 按 items 顺序的自增主键，upsert 时无法确定，为 nil
+	Rows       int64   // 影响的行数，MySQL 中被更新的行计为2
+	Statements int     // 执行的语句数
+}
+
+// CreateMany 用多行 INSERT ... VALUES 批量插入 items，按参数上限分块，所有块在同一个事务中执行；
+// db 已经是事务时在保存点中执行。Upsert 为 nil 时冲突返回 ErrDuplicate，整批回滚
+func (r *Repository[T]) CreateMany(ctx context.Context, items []T, opts BulkOptions) (BulkResult, error) {
+	var result BulkResult
+	if len(items) == 0 {
+		return result, nil
+	}
+	driver := opts.Driver
+	if driver == "" {
+		driver = "sqlite"
+	}
+	maxParams := sqliteMaxParams
+	if driver == "mysql" {
+		maxParams = mysqlMaxParams
+	}
+	columns := r.table.Writable
+	if len(columns) == 0 {
+		return result, fmt.Errorf("表 %s 没有可写列，无法批量插入", r.table.Name)
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkChunkSize
+	}
+	chunkSize = min(chunkSize, maxParams/len(columns))
+
+	suffix, err := r.upsertClause(driver, opts.Upsert)
+	if err != nil {
+		return result, err
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", r.table.Name, strings.Join(columns, ", "))
+
+	err = WithTx(ctx, r.db, nil, func(tx *Tx) error {
+		result = BulkResult{}
+		// MySQL 的多行 INSERT 按 auto_increment_increment 的步长分配主键，LAST_INSERT_ID() 为第一行的ID
+		increment := int64(1)
+		if opts.Upsert == nil {
+			result.IDs = make([]int64, 0, len(items))
+			if driver == "mysql" {
+				if err := tx.QueryRowContext(ctx, "SELECT @@SESSION.auto_increment_increment").Scan(&increment); err != nil {
+					return err
+				}
+			}
+		}
+		for chunk := range slices.Chunk(items, chunkSize) {
+			args := make([]interface{}, 0, len(chunk)*len(columns))
+			for i := range chunk {
+				args = append(args, r.table.Values(&chunk[i])...)
+			}
+			query := prefix + strings.TrimSuffix(strings.Repeat(row+", ", len(chunk)), ", ") + suffix
+			if result.IDs != nil && driver == "sqlite" {
+				// SQLite 用 RETURNING 取回主键。返回的顺序没有保证，但同一条语句分配的 rowid 递增，排序后与 items 一致
+				ids, err := queryIDs(ctx, tx, query+" RETURNING "+r.table.Key, args...)
+				if err != nil {
+					return MapError(err)
+				}
+				slices.Sort(ids)
+				result.Statements++
+				result.Rows += int64(len(ids))
+				result.IDs = append(result.IDs, ids...)
+				continue
+			}
+			res, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return MapError(err)
+			}
+			result.Statements++
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			result.Rows += n
+			if result.IDs == nil {
+				continue
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for i := range chunk {
+				result.IDs = append(result.IDs, id+int64(i)*increment)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return BulkResult{}, err
+	}
+	return result, nil
+}
+
+// upsertClause 生成冲突处理子句，列名必须属于表的可写列
+func (r *Repository[T]) upsertClause(driver string, upsert *Upsert) (string, error) {
+	if upsert == nil {
+		return "", nil
+	}
+	for _, column := range append(slices.Clone(upsert.Conflict), upsert.Update...) {
+		if !slices.Contains(r.table.Writable, column) {
+			return "", fmt.Errorf("upsert 不能使用列 %s", column)
+		}
+	}
+
+	sets := make([]string, len(upsert.Update))
+	switch driver {
+	case "sqlite":
+		if len(upsert.Conflict) == 0 {
+			return "", fmt.Errorf("SQLite 的 upsert 必须指定冲突列")
+		}
+		if len(sets) == 0 {
+			return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(upsert.Conflict, ", ")), nil
+		}
+		for i, column := range upsert.Update {
+			sets[i] = column + " = excluded." + column
+		}
+		return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(upsert.Conflict, ", "), strings.Join(sets, ", ")), nil
+	case "mysql":
+		// 没有要更新的列时把主键赋给自己，只跳过重复的行；INSERT IGNORE 还会忽略其他错误
+		if len(sets) == 0 {
+			return fmt.Sprintf(" ON DUPLICATE KEY UPDATE %[1]s = %[1]s", r.table.Key), nil
+		}
+		for i, column := range upsert.Update {
+			sets[i] = fmt.Sprintf("%[1]s = VALUES(%[1]s)", column)
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+	default:
+		return "", fmt.Errorf("不支持的驱动 %q", driver)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DemonstrateBulkInsert 展示批量插入和 upsert，与逐行插入的吞吐量比较见 BenchmarkBulkInsert
+func DemonstrateBulkInsert() {
+	fmt.Println("=== 批量插入示例 ===")
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "go-basics-bulk")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "bulk.db"))
+	if err != nil {
+		log.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+	createTable(db)
+	users := NewUserRepository(db)
+
+	fmt.Println("\n1. 返回自增主键")
+	result, err := users.CreateMany(ctx, []User{
+		{Username: "bulk_a", Email: "a@example.com"},
+		{Username: "bulk_b", Email: "b@example.com"},
+		{Username: "bulk_c", Email: "c@example.com"},
+	}, BulkOptions{ChunkSize: 2})
+	if err != nil {
+		log.Fatalf("批量插入失败: %v", err)
+	}
+	fmt.Printf("%d 条语句插入 %d 行，ID: %v\n", result.Statements, result.Rows, result.IDs)
+	for _, id := range result.IDs {
+		u, err := users.Get(ctx, id)
+		if err != nil {
+			log.Fatalf("查询用户失败: %v", err)
+		}
+		fmt.Printf("- ID=%d, 用户名=%s\n", u.ID, u.Username)
+	}
+
+	fmt.Println("\n2. 唯一键冲突")
+	incoming := []User{
+		{Username: "bulk_a", Email: "a+new@example.com"},
+		{Username: "bulk_d", Email: "d@example.com"},
+	}
+	if _, err := users.CreateMany(ctx, incoming, BulkOptions{}); err != nil {
+		fmt.Printf("不处理冲突时整批回滚: %v\n", err)
+	}
+	result, err = users.CreateMany(ctx, incoming, BulkOptions{Upsert: &Upsert{Conflict: []string{"username"}}})
+	if err != nil {
+		log.Fatalf("批量插入失败: %v", err)
+	}
+	fmt.Printf("跳过冲突的行: 影响 %d 行\n", result.Rows)
+	incoming[1].Email = "d+new@example.com"
+	result, err = users.CreateMany(ctx, incoming, BulkOptions{Upsert: &Upsert{Conflict: []string{"username"}, Update: []string{"email"}}})
+	if err != nil {
+		log.Fatalf("批量插入失败: %v", err)
+	}
+	fmt.Printf("冲突时更新邮箱: 影响 %d 行\n", result.Rows)
+	for _, name := range []string{"bulk_a", "bulk_d"} {
+		u, err := users.FindByUsername(ctx, name)
+		if err != nil {
+			log.Fatalf("查询用户失败: %v", err)
+		}
+		fmt.Printf("- ID=%d, 用户名=%s, 邮箱=%s\n", u.ID, u.Username, u.Email)
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// openBulkTestDB 打开临时的文件数据库并建表，文件数据库才能体现每次提交的代价
+func openBulkTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(tb.TempDir(), "bulk.db"))
+	if err != nil {
+		tb.Fatalf("无法打开数据库: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	migrator, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		tb.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		tb.Fatalf("创建表失败: %v", err)
+	}
+	return db
+}
+
+func newBulkUsers(prefix string, n int) []User {
+	users := make([]User, n)
+	for i := range users {
+		name := fmt.Sprintf("%s%05d", prefix, i+1)
+		users[i] = User{Username: name, Email: name + "@example.com"}
+	}
+	return users
+}
+
+func TestCreateManyIDs(t *testing.T) {
+	ctx := context.Background()
+	db := openBulkTestDB(t)
+	users := NewUserRepository(db)
+
+	// 先删掉一行，让ID不从1开始连续
+	if _, err := users.CreateMany(ctx, newBulkUsers("old", 3), BulkOptions{}); err != nil {
+		t.Fatalf("批量插入失败: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE username = 'old00002'"); err != nil {
+		t.Fatalf("删除用户失败: %v", err)
+	}
+
+	batch := newBulkUsers("new", 7)
+	result, err := users.CreateMany(ctx, batch, BulkOptions{ChunkSize: 3})
+	if err != nil {
+		t.Fatalf("批量插入失败: %v", err)
+	}
+	if result.Statements != 3 || result.Rows != 7 || len(result.IDs) != 7 {
+		t.Fatalf("期望3条语句插入7行并返回7个ID，实际 %+v", result)
+	}
+	for i, id := range result.IDs {
+		u, err := users.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("查询用户 %d 失败: %v", id, err)
+		}
+		if u.Username != batch[i].Username {
+			t.Errorf("第%d个ID %d 对应用户 %s，期望 %s", i, id, u.Username, batch[i].Username)
+		}
+	}
+
+	// 冲突时整批回滚
+	_, err = users.CreateMany(ctx, append(newBulkUsers("next", 2), batch[0]), BulkOptions{})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("期望 ErrDuplicate，实际 %v", err)
+	}
+	if _, err := users.FindByUsername(ctx, "next00001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("冲突后应整批回滚，实际 %v", err)
+	}
+}
+
+func TestCreateManyWithoutWritableColumns(t *testing.T) {
+	table := userTable
+	table.Writable = nil
+	users := NewRepository(openBulkTestDB(t), table)
+	if _, err := users.CreateMany(context.Background(), newBulkUsers("user", 1), BulkOptions{}); err == nil {
+		t.Fatal("没有可写列时应返回错误")
+	}
+}
+
+// BenchmarkBulkInsert 比较插入2000个用户的几种方式：逐行 Exec、预处理语句、预处理语句加单个事务、
+// CreateMany(每条语句500行)。每次迭代前清空表
+func BenchmarkBulkInsert(b *testing.B) {
+	const n = 2000
+	ctx := context.Background()
+	const insert = "INSERT INTO users (username, email) VALUES (?, ?)"
+
+	approaches := []struct {
+		name   string
+		insert func(db *sql.DB, batch []User) error
+	}{
+		{"Exec", func(db *sql.DB, batch []User) error {
+			for _, u := range batch {
+				if _, err := db.ExecContext(ctx, insert, u.Username, u.Email); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"Prepared", func(db *sql.DB, batch []User) error {
+			stmt, err := db.PrepareContext(ctx, insert)
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			for _, u := range batch {
+				if _, err := stmt.ExecContext(ctx, u.Username, u.Email); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"PreparedTx", func(db *sql.DB, batch []User) error {
+			return WithTx(ctx, db, nil, func(tx *Tx) error {
+				stmt, err := tx.PrepareContext(ctx, insert)
+				if err != nil {
+					return err
+				}
+				defer stmt.Close()
+				for _, u := range batch {
+					if _, err := stmt.ExecContext(ctx, u.Username, u.Email); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}},
+		{"CreateMany", func(db *sql.DB, batch []User) error {
+			_, err := NewUserRepository(db).CreateMany(ctx, batch, BulkOptions{})
+			return err
+		}},
+	}
+
+	batch := newBulkUsers("bench", n)
+	for _, a := range approaches {
+		b.Run(a.name, func(b *testing.B) {
+			db := openBulkTestDB(b)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				if _, err := db.ExecContext(ctx, "DELETE FROM users"); err != nil {
+					b.Fatalf("清空用户失败: %v", err)
+				}
+				b.StartTimer()
+				if err := a.insert(db, batch); err != nil {
+					b.Fatalf("%s 失败: %v", a.name, err)
+				}
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
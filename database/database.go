@@ -15,6 +15,21 @@ func DemonstrateDatabase() {
 	fmt.Println("\n3. 连接池和事务处理")
 	DemonstratePoolAndTransaction()
 
+	// fmt.Println("\n4. 数据库迁移")
+	// DemonstrateMigrations()
+
+	// fmt.Println("\n5. 事务重试")
+	// DemonstrateTransactionRetry()
+
+	// fmt.Println("\n6. 读写分离")
+	// DemonstrateReadWriteSplit()
+
+	// fmt.Println("\n7. 审计日志")
+	// DemonstrateAudit()
+
+	// fmt.Println("\n8. 批量插入")
+	// DemonstrateBulkInsert()
+
 	// 未来可以添加其他数据库类型
 	// fmt.Println("\n4. NoSQL数据库操作")
 	// DemonstrateNoSQL()
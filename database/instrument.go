@@ -0,0 +1,556 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// QuerySpan 一条语句的执行记录，SQL 已规范化，参数只保留类型
+type QuerySpan struct {
+	Source   string // sql 或 gorm
+	SQL      string
+	Args     []string
+	Start    time.Time
+	Duration time.Duration
+	Rows     int64 // 影响的行数，查询语句为 -1
+	Err      error
+}
+
+// String 格式化为一行日志
+func (s QuerySpan) String() string {
+	line := fmt.Sprintf("[%s %v] %s args=%v", s.Source, s.Duration.Round(time.Microsecond), s.SQL, s.Args)
+	if s.Rows >= 0 {
+		line += fmt.Sprintf(" rows=%d", s.Rows)
+	}
+	if s.Err != nil {
+		line += fmt.Sprintf(" err=%v", s.Err)
+	}
+	return line
+}
+
+// QueryTrace 收集一个请求中执行的所有语句
+type QueryTrace struct {
+	mu    sync.Mutex
+	spans []QuerySpan
+}
+
+type queryTraceKey struct{}
+
+// WithQueryTrace 返回带有 QueryTrace 的 ctx，使用该 ctx 执行的语句都会记录到 trace
+func WithQueryTrace(ctx context.Context) (context.Context, *QueryTrace) {
+	trace := &QueryTrace{}
+	return context.WithValue(ctx, queryTraceKey{}, trace), trace
+}
+
+// QueryTraceFrom 返回 ctx 中的 QueryTrace，没有时返回 nil
+func QueryTraceFrom(ctx context.Context) *QueryTrace {
+	trace, _ := ctx.Value(queryTraceKey{}).(*QueryTrace)
+	return trace
+}
+
+func (t *QueryTrace) add(span QuerySpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+}
+
+// Spans 返回已记录的语句，按完成顺序
+func (t *QueryTrace) Spans() []QuerySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.spans)
+}
+
+// Total 所有语句的耗时之和
+func (t *QueryTrace) Total() time.Duration {
+	var total time.Duration
+	for _, s := range t.Spans() {
+		total += s.Duration
+	}
+	return total
+}
+
+// QueryStat 一类语句(规范化后相同的SQL)的统计
+type QueryStat struct {
+	Fingerprint string
+	Count       int64
+	Errors      int64
+	Total       time.Duration
+	Max         time.Duration
+}
+
+// Avg 平均耗时
+func (s QueryStat) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// QueryStats 按语句指纹累计耗时
+type QueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+func (s *QueryStats) add(span QuerySpan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]*QueryStat)
+	}
+	stat, ok := s.stats[span.SQL]
+	if !ok {
+		stat = &QueryStat{Fingerprint: span.SQL}
+		s.stats[span.SQL] = stat
+	}
+	stat.Count++
+	stat.Total += span.Duration
+	stat.Max = max(stat.Max, span.Duration)
+	if span.Err != nil {
+		stat.Errors++
+	}
+}
+
+// Top 返回总耗时最多的 n 类语句
+func (s *QueryStats) Top(n int) []QueryStat {
+	s.mu.Lock()
+	top := make([]QueryStat, 0, len(s.stats))
+	for _, stat := range s.stats {
+		top = append(top, *stat)
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(top, func(a, b QueryStat) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	return top[:min(n, len(top))]
+}
+
+// Reset 清空统计
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = nil
+}
+
+// Instrumentation 语句计时：超过阈值时记录慢查询日志，记录到 ctx 中的 QueryTrace，并累计到 Stats
+//
+// 通过 OpenInstrumented 用于 database/sql，通过 gorm.DB.Use 用于 GORM；
+// GORM 使用的连接已经被包装时不要再注册插件，否则每条语句会记录两次。
+type Instrumentation struct {
+	SlowThreshold time.Duration // 0 不记录慢查询
+	Logger        *log.Logger
+	Stats         QueryStats
+
+	// Dialect 归一化语句时使用的方言，为 "mysql" 时双引号括起的也是字符串；
+	// 为空时由 OpenInstrumented 的驱动名或 GORM 的方言名填充
+	Dialect string
+}
+
+// NewInstrumentation 创建语句计时，慢查询写入标准日志
+func NewInstrumentation(slowThreshold time.Duration) *Instrumentation {
+	return &Instrumentation{SlowThreshold: slowThreshold, Logger: log.Default()}
+}
+
+func (in *Instrumentation) observe(ctx context.Context, source, query string, args []string, start time.Time, rows int64, err error) {
+	span := QuerySpan{
+		Source:   source,
+		SQL:      NormalizeSQL(query, in.Dialect),
+		Args:     args,
+		Start:    start,
+		Duration: time.Since(start),
+		Rows:     rows,
+		Err:      err,
+	}
+	in.Stats.add(span)
+	if trace := QueryTraceFrom(ctx); trace != nil {
+		trace.add(span)
+	}
+	if in.SlowThreshold > 0 && span.Duration >= in.SlowThreshold {
+		in.Logger.Printf("慢查询 %s", span)
+	}
+}
+
+var (
+	placeholderList = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	repeatedList    = regexp.MustCompile(`\(\.\.\.\)(?:\s*,\s*\(\.\.\.\))+`)
+)
+
+// NormalizeSQL 将字符串和数字字面量替换为 ?，去掉注释并合并空白，把 IN (?, ?) 和多行 VALUES 折叠，
+// 结果作为语句指纹，不包含任何参数值
+//
+// dialect 为 "mysql" 时双引号括起的是字符串，字符串中可以用反斜杠转义，# 开始单行注释；
+// 其他方言中双引号括起的是标识符，原样保留。
+func NormalizeSQL(query, dialect string) string {
+	mysql := dialect == "mysql"
+	var b strings.Builder
+	runes := []rune(query)
+	space := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-' &&
+			(!mysql || i+2 == len(runes) || unicode.IsSpace(runes[i+2])),
+			r == '#' && mysql:
+			// 单行注释，MySQL 要求 -- 后面跟空白
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			space = b.Len() > 0
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// 块注释，未闭合时忽略到末尾
+			i += 2
+			for i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/') {
+				i++
+			}
+			i++ // 停在 */ 的 / 上
+			space = b.Len() > 0
+			continue
+		case r == '\'' || r == '"' && mysql:
+			// 字符串字面量，两个连续的引号是转义的引号
+			i = skipQuoted(runes, i, mysql)
+			r = '?'
+		case r == '"' || r == '`':
+			// 标识符原样保留，其中的数字不是字面量
+			end := skipQuoted(runes, i, false)
+			if space {
+				b.WriteRune(' ')
+				space = false
+			}
+			b.WriteString(string(runes[i:min(end+1, len(runes))]))
+			i = end
+			continue
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(runes[i-1])):
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			r = '?'
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	normalized := placeholderList.ReplaceAllString(b.String(), "(...)")
+	return repeatedList.ReplaceAllString(normalized, "(...), ...")
+}
+
+// skipQuoted 返回从 start 开始的引号字面量的结束引号位置，未闭合时返回末尾
+// backslash 为 true 时反斜杠转义下一个字符，如 MySQL 默认的 sql_mode
+func skipQuoted(runes []rune, start int, backslash bool) int {
+	quote := runes[start]
+	i := start + 1
+	for ; i < len(runes); i++ {
+		switch {
+		case backslash && runes[i] == '\\':
+			i++
+		case runes[i] == quote:
+			if i+1 < len(runes) && runes[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return i
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// redactArgs 只保留参数的类型，字符串和字节切片附带长度
+func redactArgs[T any](args []T, value func(T) interface{}) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch v := value(arg).(type) {
+		case nil:
+			redacted[i] = "nil"
+		case string:
+			redacted[i] = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			redacted[i] = fmt.Sprintf("[]byte(%d)", len(v))
+		default:
+			redacted[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return redacted
+}
+
+func namedValue(v driver.NamedValue) interface{} { return v.Value }
+
+// OpenInstrumented 与 sql.Open 相同，但每条语句都经过 in 计时
+func OpenInstrumented(driverName, dsn string, in *Instrumentation) (*sql.DB, error) {
+	if in.Dialect == "" {
+		in.Dialect = driverName
+	}
+	base, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := base.Driver()
+	base.Close()
+
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(instrumentedConnector{Connector: connector, in: in}), nil
+}
+
+// dsnConnector 用于没有实现 driver.DriverContext 的驱动
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+type instrumentedConnector struct {
+	driver.Connector
+	in *Instrumentation
+}
+
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, in: c.in}, nil
+}
+
+// instrumentedConn 包装驱动连接，底层没有实现的可选接口返回 driver.ErrSkip，由 database/sql 回退
+type instrumentedConn struct {
+	driver.Conn
+	in *Instrumentation
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.in.observe(ctx, "sql", query, redactArgs(args, namedValue), start, rowsAffected(result, err), err)
+	}
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.in.observe(ctx, "sql", query, redactArgs(args, namedValue), start, -1, err)
+	}
+	return rows, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, in: c.in}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+// instrumentedStmt 包装预处理语句，每次执行单独计时
+type instrumentedStmt struct {
+	driver.Stmt
+	query string
+	in    *Instrumentation
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	s.in.observe(ctx, "sql", s.query, redactArgs(args, namedValue), start, rowsAffected(result, err), err)
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	s.in.observe(ctx, "sql", s.query, redactArgs(args, namedValue), start, -1, err)
+	return rows, err
+}
+
+func (s *instrumentedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("驱动不支持命名参数 %s", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func rowsAffected(result driver.Result, err error) int64 {
+	if err != nil || result == nil {
+		return 0
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// gorm 插件在语句开始时保存的时间
+const gormStartKey = "instrumentation:start"
+
+// Name 实现 gorm.Plugin
+func (in *Instrumentation) Name() string {
+	return "instrumentation"
+}
+
+// Initialize 实现 gorm.Plugin，在所有语句的前后注册回调
+func (in *Instrumentation) Initialize(db *gorm.DB) error {
+	if in.Dialect == "" {
+		in.Dialect = db.Dialector.Name()
+	}
+	before := func(db *gorm.DB) {
+		db.InstanceSet(gormStartKey, time.Now())
+	}
+	after := func(query bool) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			start, ok := db.InstanceGet(gormStartKey)
+			if !ok {
+				return
+			}
+			rows := db.Statement.RowsAffected
+			if query {
+				rows = -1
+			}
+			in.observe(db.Statement.Context, "gorm", db.Statement.SQL.String(),
+				redactArgs(db.Statement.Vars, func(v interface{}) interface{} { return v }),
+				start.(time.Time), rows, db.Error)
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("instrumentation:before_create", before),
+		cb.Create().After("gorm:create").Register("instrumentation:after_create", after(false)),
+		cb.Query().Before("gorm:query").Register("instrumentation:before_query", before),
+		cb.Query().After("gorm:query").Register("instrumentation:after_query", after(true)),
+		cb.Update().Before("gorm:update").Register("instrumentation:before_update", before),
+		cb.Update().After("gorm:update").Register("instrumentation:after_update", after(false)),
+		cb.Delete().Before("gorm:delete").Register("instrumentation:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("instrumentation:after_delete", after(false)),
+		cb.Row().Before("gorm:row").Register("instrumentation:before_row", before),
+		cb.Row().After("gorm:row").Register("instrumentation:after_row", after(true)),
+		cb.Raw().Before("gorm:raw").Register("instrumentation:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("instrumentation:after_raw", after(false)),
+	)
+}
+
+// QueryTraceMiddleware 为每个请求附加 QueryTrace，处理函数使用 r.Context() 执行的语句都会被记录，
+// 请求结束后输出语句数量和总耗时
+func QueryTraceMiddleware(next http.Handler, logger *log.Logger) http.Handler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, trace := WithQueryTrace(r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+		if spans := trace.Spans(); len(spans) > 0 {
+			logger.Printf("%s %s: %d 条语句，耗时 %v", r.Method, r.URL.Path, len(spans), trace.Total().Round(time.Microsecond))
+		}
+	})
+}
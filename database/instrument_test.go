@@ -0,0 +1,30 @@
+package database
+
+import "testing"
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		dialect string
+		query   string
+		want    string
+	}{
+		{"sqlite", "SELECT * FROM users WHERE username = 'o''brien' AND age > 30", "SELECT * FROM users WHERE username = ? AND age > ?"},
+		{"sqlite", "SELECT id FROM users WHERE id IN (1, 2, 3)", "SELECT id FROM users WHERE id IN (...)"},
+		{"sqlite", "INSERT INTO users (username) VALUES ('a'), ('b'), ('c')", "INSERT INTO users (username) VALUES (...), ..."},
+		{"sqlite", `SELECT "col1" FROM "table 2" WHERE x = 'secret'`, `SELECT "col1" FROM "table 2" WHERE x = ?`},
+		{"sqlite", "SELECT * FROM users -- 查询 token='abc'\nWHERE id = 1", "SELECT * FROM users WHERE id = ?"},
+		{"sqlite", "/* user=alice password=123 */ SELECT 1", "SELECT ?"},
+		{"sqlite", "SELECT 1 /* 未闭合的注释 'x'", "SELECT ?"},
+
+		{"mysql", `SELECT * FROM users WHERE email = "alice@example.com"`, "SELECT * FROM users WHERE email = ?"},
+		{"mysql", `SELECT * FROM users WHERE username = 'it\'s' AND note = "say \"hi\""`, "SELECT * FROM users WHERE username = ? AND note = ?"},
+		{"mysql", "SELECT `col1` FROM users # password='x'\nWHERE id = 7", "SELECT `col1` FROM users WHERE id = ?"},
+		{"mysql", "SELECT a--1 FROM t", "SELECT a--? FROM t"},
+		{"mysql", "SELECT a FROM t -- 注释", "SELECT a FROM t"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSQL(tt.query, tt.dialect); got != tt.want {
+			t.Errorf("NormalizeSQL(%q, %q) = %q，期望 %q", tt.query, tt.dialect, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 记账相关的错误
+var (
+	ErrUnbalanced        = errors.New("分录借贷不平衡")
+	ErrInsufficientFunds = errors.New("余额不足")
+)
+
+// Amount 金额，以最小货币单位(分)计，避免浮点误差
+type Amount int64
+
+// Yuan 将整数元转换为金额
+func Yuan(yuan int64) Amount {
+	return Amount(yuan * 100)
+}
+
+// String 按两位小数格式化，如 -12.34
+func (a Amount) String() string {
+	sign, v := "", int64(a)
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// LedgerAccount 记账账户，AllowNegative 的账户(如外部资金来源)余额可以为负
+type LedgerAccount struct {
+	ID            int64
+	Name          string
+	Currency      string
+	AllowNegative bool
+	CreatedAt     time.Time
+}
+
+// AccountBalance 账户及其余额
+type AccountBalance struct {
+	LedgerAccount
+	Balance Amount
+}
+
+// Posting 分录明细，正数记入账户，负数从账户记出
+type Posting struct {
+	AccountID int64
+	Amount    Amount
+}
+
+// Entry 分录，所有明细之和必须为0，写入后不能修改
+type Entry struct {
+	ID             int64
+	IdempotencyKey string // 非空时相同的键只记账一次
+	Description    string
+	CreatedAt      time.Time
+	Postings       []Posting
+}
+
+// ledgerLockClause 锁定账户行的后缀，SQLite 的写事务本身是串行的
+var ledgerLockClause = map[string]string{
+	"sqlite": "",
+	"mysql":  " FOR UPDATE",
+}
+
+// Ledger 复式记账账本，余额由分录明细推导，快照用来减少累加的明细数量
+type Ledger struct {
+	db     DBTX
+	driver string
+
+	// Retry 记账事务遇到锁冲突时的重试选项，在外层事务中使用时不重试
+	Retry RetryOptions
+}
+
+// NewLedger 创建账本，db 可以是 *sql.DB、*sql.Conn 或事务，表结构见 migrations 的 0004_create_ledger
+func NewLedger(db DBTX, driver string) (*Ledger, error) {
+	if _, ok := ledgerLockClause[driver]; !ok {
+		return nil, fmt.Errorf("账本不支持数据库驱动 %q", driver)
+	}
+	return &Ledger{db: db, driver: driver}, nil
+}
+
+// NewGormLedger 使用 GORM 的连接创建账本，在 db.Transaction 回调中传入 tx 时记账属于该事务
+func NewGormLedger(db *gorm.DB) (*Ledger, error) {
+	return NewLedger(db.Statement.ConnPool, db.Dialector.Name())
+}
+
+// With 返回使用 db 的账本副本，通常传入事务
+func (l *Ledger) With(db DBTX) *Ledger {
+	return &Ledger{db: db, driver: l.driver, Retry: l.Retry}
+}
+
+// withTx 在事务中执行 fn，已在事务中时使用保存点
+func (l *Ledger) withTx(ctx context.Context, readOnly bool, fn func(tx *Tx) error) error {
+	switch l.db.(type) {
+	case *Tx, *sql.Tx:
+		return WithTx(ctx, l.db, nil, fn)
+	}
+	var opts *sql.TxOptions
+	if readOnly {
+		opts = &sql.TxOptions{ReadOnly: true}
+	}
+	return WithTxRetry(ctx, l.db, opts, l.Retry, fn)
+}
+
+// CreateAccount 创建账户，名称重复时返回 ErrDuplicate
+func (l *Ledger) CreateAccount(ctx context.Context, name, currency string, allowNegative bool) (LedgerAccount, error) {
+	result, err := l.db.ExecContext(ctx,
+		"INSERT INTO ledger_accounts (name, currency, allow_negative) VALUES (?, ?, ?)",
+		name, currency, allowNegative)
+	if err != nil {
+		return LedgerAccount{}, MapError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return LedgerAccount{}, err
+	}
+	return l.Account(ctx, id)
+}
+
+// Account 按ID查询账户，不存在时返回 ErrNotFound
+func (l *Ledger) Account(ctx context.Context, id int64) (LedgerAccount, error) {
+	return scanLedgerAccount(l.db.QueryRowContext(ctx,
+		"SELECT id, name, currency, allow_negative, created_at FROM ledger_accounts WHERE id = ?", id).Scan)
+}
+
+func scanLedgerAccount(scan func(dest ...interface{}) error) (LedgerAccount, error) {
+	var a LedgerAccount
+	var createdAt scanTime
+	if err := scan(&a.ID, &a.Name, &a.Currency, &a.AllowNegative, &createdAt); err != nil {
+		return a, MapError(err)
+	}
+	a.CreatedAt = createdAt.Time
+	return a, nil
+}
+
+// Transfer 从 from 转账 amount 到 to，key 非空时重复请求只记账一次
+func (l *Ledger) Transfer(ctx context.Context, key string, from, to int64, amount Amount, description string) (Entry, error) {
+	if amount <= 0 {
+		return Entry{}, fmt.Errorf("转账金额必须为正数: %s", amount)
+	}
+	return l.Post(ctx, Entry{
+		IdempotencyKey: key,
+		Description:    description,
+		Postings:       []Posting{{AccountID: from, Amount: -amount}, {AccountID: to, Amount: amount}},
+	})
+}
+
+// Post 记账并返回写入的分录
+//
+// 明细少于两条、含0金额、合计不为0或币种不一致时返回 ErrUnbalanced，
+// 不允许负余额的账户余额不足时返回 ErrInsufficientFunds。
+// 幂等键已存在时不再记账，明细相同则返回已有的分录，否则返回 ErrConflict。
+func (l *Ledger) Post(ctx context.Context, entry Entry) (Entry, error) {
+	if err := checkBalanced(entry.Postings); err != nil {
+		return Entry{}, err
+	}
+
+	var posted Entry
+	err := l.withTx(ctx, false, func(tx *Tx) error {
+		var err error
+		posted, err = l.post(ctx, tx, entry)
+		return err
+	})
+	// 并发的相同请求在插入时才发现幂等键冲突，此时另一个请求已经提交
+	if errors.Is(err, ErrDuplicate) && entry.IdempotencyKey != "" {
+		return l.replay(ctx, l.db, entry)
+	}
+	return posted, err
+}
+
+func (l *Ledger) post(ctx context.Context, tx *Tx, entry Entry) (Entry, error) {
+	if entry.IdempotencyKey != "" {
+		existing, err := l.replay(ctx, tx, entry)
+		if !errors.Is(err, ErrNotFound) {
+			return existing, err
+		}
+	}
+
+	// 锁定涉及的账户，检查存在和币种
+	ids := make([]int64, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		if !slices.Contains(ids, p.AccountID) {
+			ids = append(ids, p.AccountID)
+		}
+	}
+	slices.Sort(ids) // 固定加锁顺序，减少死锁
+	accounts := make(map[int64]LedgerAccount, len(ids))
+	for _, id := range ids {
+		account, err := scanLedgerAccount(tx.QueryRowContext(ctx,
+			"SELECT id, name, currency, allow_negative, created_at FROM ledger_accounts WHERE id = ?"+ledgerLockClause[l.driver], id).Scan)
+		if err != nil {
+			return Entry{}, fmt.Errorf("账户 %d: %w", id, err)
+		}
+		if len(accounts) > 0 && account.Currency != accounts[ids[0]].Currency {
+			return Entry{}, fmt.Errorf("%w: 分录包含 %s 和 %s 两种币种", ErrUnbalanced, accounts[ids[0]].Currency, account.Currency)
+		}
+		accounts[id] = account
+	}
+
+	var key interface{}
+	if entry.IdempotencyKey != "" {
+		key = entry.IdempotencyKey
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO ledger_entries (idempotency_key, description) VALUES (?, ?)", key, entry.Description)
+	if err != nil {
+		return Entry{}, MapError(err)
+	}
+	if entry.ID, err = result.LastInsertId(); err != nil {
+		return Entry{}, err
+	}
+	for _, p := range entry.Postings {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES (?, ?, ?)",
+			entry.ID, p.AccountID, p.Amount); err != nil {
+			return Entry{}, MapError(err)
+		}
+	}
+
+	for _, id := range ids {
+		if accounts[id].AllowNegative {
+			continue
+		}
+		balances, err := l.balances(ctx, tx, id)
+		if err != nil {
+			return Entry{}, err
+		}
+		if balances[0].Balance < 0 {
+			return Entry{}, fmt.Errorf("%w: 账户 %s 记账后余额为 %s", ErrInsufficientFunds, accounts[id].Name, balances[0].Balance)
+		}
+	}
+	return l.entry(ctx, tx, "id", entry.ID)
+}
+
+// replay 查询幂等键对应的分录，明细与请求不同时返回 ErrConflict
+func (l *Ledger) replay(ctx context.Context, db DBTX, entry Entry) (Entry, error) {
+	existing, err := l.entry(ctx, db, "idempotency_key", entry.IdempotencyKey)
+	if err != nil {
+		return existing, err
+	}
+	if !samePostings(existing.Postings, entry.Postings) {
+		return Entry{}, fmt.Errorf("%w: 幂等键 %q 已用于不同的分录 %d", ErrConflict, entry.IdempotencyKey, existing.ID)
+	}
+	return existing, nil
+}
+
+// entry 按 id 或 idempotency_key 查询分录及其明细
+func (l *Ledger) entry(ctx context.Context, db DBTX, column string, value interface{}) (Entry, error) {
+	var entry Entry
+	var key sql.NullString
+	var createdAt scanTime
+	err := db.QueryRowContext(ctx,
+		"SELECT id, idempotency_key, description, created_at FROM ledger_entries WHERE "+column+" = ?", value,
+	).Scan(&entry.ID, &key, &entry.Description, &createdAt)
+	if err != nil {
+		return entry, MapError(err)
+	}
+	entry.IdempotencyKey, entry.CreatedAt = key.String, createdAt.Time
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT account_id, amount FROM ledger_postings WHERE entry_id = ? ORDER BY id", entry.ID)
+	if err != nil {
+		return entry, MapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Posting
+		if err := rows.Scan(&p.AccountID, &p.Amount); err != nil {
+			return entry, err
+		}
+		entry.Postings = append(entry.Postings, p)
+	}
+	return entry, rows.Err()
+}
+
+// checkBalanced 检查分录的明细是否平衡
+func checkBalanced(postings []Posting) error {
+	if len(postings) < 2 {
+		return fmt.Errorf("%w: 至少需要两条明细", ErrUnbalanced)
+	}
+	var sum Amount
+	for _, p := range postings {
+		if p.Amount == 0 {
+			return fmt.Errorf("%w: 账户 %d 的明细金额为0", ErrUnbalanced, p.AccountID)
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: 明细合计为 %s", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+func samePostings(a, b []Posting) bool {
+	compare := func(x, y Posting) int {
+		return cmp.Or(cmp.Compare(x.AccountID, y.AccountID), cmp.Compare(x.Amount, y.Amount))
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.SortFunc(a, compare)
+	slices.SortFunc(b, compare)
+	return slices.Equal(a, b)
+}
+
+// balanceSQL 从最近的快照开始累加之后的明细得到余额
+const balanceSQL = `SELECT a.id, a.name, a.currency, a.allow_negative, a.created_at,
+	COALESCE(s.balance, 0) + COALESCE((
+		SELECT SUM(p.amount) FROM ledger_postings p
+		WHERE p.account_id = a.id AND p.entry_id > COALESCE(s.entry_id, 0)
+	), 0)
+FROM ledger_accounts a
+LEFT JOIN ledger_snapshots s ON s.account_id = a.id
+	AND s.entry_id = (SELECT MAX(entry_id) FROM ledger_snapshots WHERE account_id = a.id)`
+
+// Balance 查询账户余额
+func (l *Ledger) Balance(ctx context.Context, accountID int64) (Amount, error) {
+	balances, err := l.balances(ctx, l.db, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return balances[0].Balance, nil
+}
+
+// Balances 查询所有账户的余额，按ID排序
+func (l *Ledger) Balances(ctx context.Context) ([]AccountBalance, error) {
+	return l.balances(ctx, l.db, 0)
+}
+
+// balances 查询一个账户(accountID 非0)或所有账户的余额
+func (l *Ledger) balances(ctx context.Context, db DBTX, accountID int64) ([]AccountBalance, error) {
+	query, args := balanceSQL, []interface{}(nil)
+	if accountID != 0 {
+		query, args = query+" WHERE a.id = ?", []interface{}{accountID}
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY a.id", args...)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	defer rows.Close()
+
+	var balances []AccountBalance
+	for rows.Next() {
+		var b AccountBalance
+		var createdAt scanTime
+		if err := rows.Scan(&b.ID, &b.Name, &b.Currency, &b.AllowNegative, &createdAt, &b.Balance); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = createdAt.Time
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if accountID != 0 && len(balances) == 0 {
+		return nil, fmt.Errorf("账户 %d: %w", accountID, ErrNotFound)
+	}
+	return balances, nil
+}
+
+// Snapshot 为自上次快照后有新分录的账户保存截至最新分录的余额，返回新建的快照数
+func (l *Ledger) Snapshot(ctx context.Context) (int64, error) {
+	var created int64
+	err := l.withTx(ctx, false, func(tx *Tx) error {
+		// MySQL 的锁定读会等待未提交的分录，保证快照不会漏掉编号更小但提交更晚的分录
+		var from, upTo int64
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MIN(COALESCE(s.entry_id, 0)), 0) FROM ledger_accounts a
+LEFT JOIN (SELECT account_id, MAX(entry_id) AS entry_id FROM ledger_snapshots GROUP BY account_id) s ON s.account_id = a.id`).Scan(&from); err != nil {
+			return MapError(err)
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM ledger_entries WHERE id > ?"+ledgerLockClause[l.driver], from)
+		if err != nil {
+			return MapError(err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			upTo = max(upTo, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if upTo == 0 {
+			return nil
+		}
+
+		result, err := tx.ExecContext(ctx, `INSERT INTO ledger_snapshots (account_id, entry_id, balance)
+SELECT b.id, ?, b.balance FROM (
+	SELECT a.id, COALESCE(s.entry_id, 0) AS entry_id, COALESCE(s.balance, 0) + COALESCE((
+		SELECT SUM(p.amount) FROM ledger_postings p
+		WHERE p.account_id = a.id AND p.entry_id > COALESCE(s.entry_id, 0) AND p.entry_id <= ?
+	), 0) AS balance
+	FROM ledger_accounts a
+	LEFT JOIN ledger_snapshots s ON s.account_id = a.id
+		AND s.entry_id = (SELECT MAX(entry_id) FROM ledger_snapshots WHERE account_id = a.id)
+) b
+WHERE b.entry_id < ? AND EXISTS (
+	SELECT 1 FROM ledger_postings p WHERE p.account_id = b.id AND p.entry_id > b.entry_id AND p.entry_id <= ?
+)`, upTo, upTo, upTo, upTo)
+		if err != nil {
+			return MapError(err)
+		}
+		created, err = result.RowsAffected()
+		return err
+	})
+	return created, err
+}
+
+// AccountReconciliation 单个账户的对账结果
+type AccountReconciliation struct {
+	AccountBalance
+	Recomputed   Amount // 从全部明细重新累加的余额
+	BadSnapshots []int64
+}
+
+// OK 快照推导的余额与重新累加的一致、快照都正确且没有不允许的负余额
+func (r AccountReconciliation) OK() bool {
+	return r.Balance == r.Recomputed && len(r.BadSnapshots) == 0 && (r.AllowNegative || r.Balance >= 0)
+}
+
+// ReconcileReport 对账报告
+type ReconcileReport struct {
+	Accounts          []AccountReconciliation
+	UnbalancedEntries []int64 // 明细合计不为0或少于两条的分录
+	Total             Amount  // 所有明细之和，复式记账下必须为0
+}
+
+// OK 账本是否一致
+func (r ReconcileReport) OK() bool {
+	if r.Total != 0 || len(r.UnbalancedEntries) > 0 {
+		return false
+	}
+	for _, a := range r.Accounts {
+		if !a.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// String 格式化对账报告
+func (r ReconcileReport) String() string {
+	var b strings.Builder
+	for _, a := range r.Accounts {
+		state := "一致"
+		if !a.OK() {
+			state = "不一致"
+		}
+		fmt.Fprintf(&b, "%-10s 余额 %12s  重新累加 %12s  %s", a.Name, a.Balance, a.Recomputed, state)
+		if len(a.BadSnapshots) > 0 {
+			fmt.Fprintf(&b, "  错误快照 %v", a.BadSnapshots)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "明细合计 %s，不平衡分录 %d 条", r.Total, len(r.UnbalancedEntries))
+	if r.OK() {
+		b.WriteString("，对账通过")
+	} else {
+		b.WriteString("，对账失败")
+	}
+	return b.String()
+}
+
+// Reconcile 在只读事务中对账：检查每条分录是否平衡，
+// 并将快照推导的余额、每个快照与从全部明细重新累加的结果比较
+func (l *Ledger) Reconcile(ctx context.Context) (ReconcileReport, error) {
+	var report ReconcileReport
+	err := l.withTx(ctx, true, func(tx *Tx) error {
+		report = ReconcileReport{}
+		balances, err := l.balances(ctx, tx, 0)
+		if err != nil {
+			return err
+		}
+		for _, b := range balances {
+			a := AccountReconciliation{AccountBalance: b}
+			if err := tx.QueryRowContext(ctx,
+				"SELECT COALESCE(SUM(amount), 0) FROM ledger_postings WHERE account_id = ?", b.ID).Scan(&a.Recomputed); err != nil {
+				return MapError(err)
+			}
+			if a.BadSnapshots, err = queryIDs(ctx, tx, `SELECT s.entry_id FROM ledger_snapshots s
+WHERE s.account_id = ? AND s.balance <> COALESCE((
+	SELECT SUM(p.amount) FROM ledger_postings p WHERE p.account_id = s.account_id AND p.entry_id <= s.entry_id
+), 0) ORDER BY s.entry_id`, b.ID); err != nil {
+				return err
+			}
+			report.Accounts = append(report.Accounts, a)
+		}
+
+		if report.UnbalancedEntries, err = queryIDs(ctx, tx, `SELECT e.id FROM ledger_entries e
+LEFT JOIN ledger_postings p ON p.entry_id = e.id
+GROUP BY e.id HAVING COUNT(p.id) < 2 OR SUM(p.amount) <> 0 ORDER BY e.id`); err != nil {
+			return err
+		}
+		return MapError(tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM ledger_postings").Scan(&report.Total))
+	})
+	return report, err
+}
+
+func queryIDs(ctx context.Context, db DBTX, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, MapError(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,499 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// 迁移相关错误
+var (
+	ErrMigrationLocked = errors.New("另一个迁移正在运行")
+	ErrNoMigration     = errors.New("没有可执行的迁移")
+	ErrIrreversible    = errors.New("迁移不可回滚")
+)
+
+// Migration 一个版本的迁移，SQL和Go函数二选一，同时设置时先执行SQL
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+	Down    func(ctx context.Context, tx *sql.Tx) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+func (m Migration) reversible() bool {
+	return m.DownSQL != "" || m.Down != nil
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // 数据库中有记录，但已找不到对应的迁移
+}
+
+// migrationFileName 形如 0001_create_users.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations 从 fsys 的 dir 目录加载SQL迁移，up 文件必须存在，down 文件可选
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("版本 %d 有两个不同的迁移: %s 和 %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(data)
+		} else {
+			m.DownSQL = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, fmt.Errorf("迁移 %s 缺少 up 文件", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+// DefaultMigrations 返回内置的迁移，各驱动的SQL位于 migrations/<驱动> 目录
+func DefaultMigrations(driver string) ([]Migration, error) {
+	if _, ok := migrationDialects[driver]; !ok {
+		return nil, fmt.Errorf("不支持的数据库驱动 %q", driver)
+	}
+	return LoadMigrations(migrationFiles, "migrations/"+driver)
+}
+
+// migrationDialect 各数据库在建表和加锁上的差异
+type migrationDialect struct {
+	createTable string
+	lock        func(ctx context.Context, conn *sql.Conn, owner string, timeout time.Duration) error
+	unlock      func(ctx context.Context, conn *sql.Conn) error
+}
+
+var migrationDialects = map[string]migrationDialect{
+	// SQLite 没有咨询锁，用锁表中唯一的一行表示锁，进程异常退出后需要 ForceUnlock
+	"sqlite": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`,
+		lock:   lockWithTable,
+		unlock: unlockTable,
+	},
+	// MySQL 使用 GET_LOCK，连接断开时自动释放
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`,
+		lock: func(ctx context.Context, conn *sql.Conn, owner string, timeout time.Duration) error {
+			var acquired sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK('schema_migrations', ?)", int(timeout.Seconds())).Scan(&acquired)
+			if err != nil {
+				return err
+			}
+			if acquired.Int64 != 1 {
+				return ErrMigrationLocked
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK('schema_migrations')")
+			return err
+		},
+	},
+}
+
+// lockWithTable 插入锁表中 id=1 的行，已存在时等待直到超时
+func lockWithTable(ctx context.Context, conn *sql.Conn, owner string, timeout time.Duration) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INTEGER PRIMARY KEY,
+		owner TEXT NOT NULL,
+		locked_at DATETIME NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)", owner, time.Now().UTC())
+		if err == nil {
+			return nil
+		}
+		// 插入失败时确认是否因为锁已被持有，否则是其他错误
+		var holder string
+		var lockedAt scanTime
+		if scanErr := conn.QueryRowContext(ctx, "SELECT owner, locked_at FROM schema_migrations_lock WHERE id = 1").Scan(&holder, &lockedAt); scanErr != nil {
+			if !errors.Is(scanErr, sql.ErrNoRows) {
+				return err
+			}
+			// 锁行不存在：持有者恰好释放了锁时插入会因主键冲突或数据库繁忙失败，可以重试，
+			// 其他原因(如只读数据库)重试也不会成功
+			if !errors.Is(MapError(err), ErrDuplicate) && !IsRetryable(err) {
+				return err
+			}
+		}
+		if time.Now().After(deadline) {
+			if holder == "" {
+				return fmt.Errorf("%w: %v", ErrMigrationLocked, err)
+			}
+			return fmt.Errorf("%w: 由 %s 于 %s 持有", ErrMigrationLocked, holder, lockedAt.Local().Format(time.DateTime))
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func unlockTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+	return err
+}
+
+// Migrator 按版本号顺序执行迁移，并在 schema_migrations 表中记录已执行的版本
+//
+// 每个迁移和它的版本记录在同一个事务中提交。MySQL 的DDL会隐式提交事务，
+// 包含多条DDL的迁移失败时可能只执行了一部分，需要手动修复后再重试。
+type Migrator struct {
+	db          *sql.DB
+	dialect     migrationDialect
+	migrations  []Migration
+	LockTimeout time.Duration // 等待其他迁移进程的时间，默认15秒
+}
+
+// NewMigrator 创建迁移器，migrations 为空时使用内置迁移
+func NewMigrator(db *sql.DB, driver string, migrations ...Migration) (*Migrator, error) {
+	dialect, ok := migrationDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("不支持的数据库驱动 %q", driver)
+	}
+	if len(migrations) == 0 {
+		var err error
+		if migrations, err = DefaultMigrations(driver); err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i := range sorted {
+		if i > 0 && sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("迁移版本 %d 重复", sorted[i].Version)
+		}
+		if sorted[i].UpSQL == "" && sorted[i].Up == nil {
+			return nil, fmt.Errorf("迁移 %s 没有 up 步骤", sorted[i])
+		}
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: sorted, LockTimeout: 15 * time.Second}, nil
+}
+
+// NewGormMigrator 为 GORM 使用的数据库创建迁移器，驱动由 GORM 的方言决定
+func NewGormMigrator(db *gorm.DB, migrations ...Migration) (*Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(sqlDB, db.Dialector.Name(), migrations...)
+}
+
+// withLock 在同一个连接上加锁、执行 fn、释放锁
+// 使用单个连接还保证了 SQLite 内存数据库在迁移过程中始终是同一个库
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	if err := m.dialect.lock(ctx, conn, owner, m.LockTimeout); err != nil {
+		return err
+	}
+	defer m.dialect.unlock(context.WithoutCancel(ctx), conn)
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// applied 返回已执行的版本及执行时间
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, map[int64]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[int64]time.Time)
+	names := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var name string
+		var appliedAt scanTime
+		if err := rows.Scan(&version, &name, &appliedAt); err != nil {
+			return nil, nil, err
+		}
+		times[version] = appliedAt.Time
+		names[version] = name
+	}
+	return times, names, rows.Err()
+}
+
+// Up 执行所有未执行的迁移，to 大于0时只执行到该版本，返回执行了的迁移
+func (m *Migrator) Up(ctx context.Context, to int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, _, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if to > 0 && migration.Version > to {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按从新到旧的顺序回滚 steps 个已执行的迁移，返回回滚了的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		done, err = m.down(ctx, conn, steps)
+		return err
+	})
+	return done, err
+}
+
+func (m *Migrator) down(ctx context.Context, conn *sql.Conn, steps int) ([]Migration, error) {
+	applied, names, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	slices.Reverse(versions)
+	if len(versions) == 0 {
+		return nil, ErrNoMigration
+	}
+
+	var done []Migration
+	for _, version := range versions[:min(steps, len(versions))] {
+		i := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+		if i < 0 {
+			return done, fmt.Errorf("找不到已执行的迁移 %04d_%s", version, names[version])
+		}
+		migration := m.migrations[i]
+		if !migration.reversible() {
+			return done, fmt.Errorf("%s: %w", migration, ErrIrreversible)
+		}
+		if err := m.run(ctx, conn, migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Redo 回滚并重新执行最近一次迁移，用于开发时修改迁移后验证
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	var migration Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.down(ctx, conn, 1)
+		if err != nil {
+			return err
+		}
+		migration = done[0]
+		return m.run(ctx, conn, migration, true)
+	})
+	return migration, err
+}
+
+// Status 返回所有迁移的执行状态，按版本排序
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, names, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+			delete(applied, migration.Version)
+		}
+		for version, appliedAt := range applied {
+			statuses = append(statuses, MigrationStatus{
+				Migration: Migration{Version: version, Name: names[version]},
+				Applied:   true,
+				AppliedAt: appliedAt,
+				Missing:   true,
+			})
+		}
+		slices.SortFunc(statuses, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+		return nil
+	})
+	return statuses, err
+}
+
+// ForceUnlock 清除 SQLite 锁表中残留的锁，只应在确认没有迁移在运行时使用
+func (m *Migrator) ForceUnlock(ctx context.Context) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return m.dialect.unlock(ctx, conn)
+}
+
+// run 在事务中执行一个迁移并更新版本记录
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, fn := migration.UpSQL, migration.Up
+	if !up {
+		script, fn = migration.DownSQL, migration.Down
+	}
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("迁移 %s 失败: %w", migration, err)
+		}
+	}
+	if fn != nil {
+		if err := fn(ctx, tx); err != nil {
+			return fmt.Errorf("迁移 %s 失败: %w", migration, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements 按行尾的分号拆分SQL脚本，忽略 -- 注释行
+// MySQL 驱动默认不允许一次执行多条语句，所以逐条执行
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// scanTime 兼容驱动返回 time.Time 或字符串两种情况，
+// 如 SQLite 驱动和未设置 parseTime=true 的 MySQL 驱动
+type scanTime struct{ time.Time }
+
+func (t *scanTime) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("无法将 %T 转换为时间", value)
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("无法解析时间 %q", s)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DemonstrateMigrations 展示版本化迁移：执行、Go迁移回填数据、状态、回滚、重做和并发锁
+func DemonstrateMigrations() {
+	fmt.Println("=== 数据库迁移示例 ===")
+	ctx := context.Background()
+
+	// 使用文件数据库，多个连接看到的是同一个库
+	dir, err := os.MkdirTemp("", "go-basics-migrate")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "demo.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		log.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+
+	// 内置的SQL迁移之外，再加一个Go迁移：添加 display_name 列并用 username 回填
+	migrations, err := DefaultMigrations("sqlite")
+	if err != nil {
+		log.Fatalf("加载迁移失败: %v", err)
+	}
+	// 内置迁移之后的版本号，新增内置迁移时不用修改示例
+	builtin := migrations[len(migrations)-1].Version
+	migrations = append(migrations, Migration{
+		Version: builtin + 1,
+		Name:    "backfill_display_name",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "ALTER TABLE users ADD COLUMN display_name TEXT"); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "UPDATE users SET display_name = upper(username)")
+			return err
+		},
+		DownSQL: "ALTER TABLE users DROP COLUMN display_name;",
+	})
+	migrator, err := NewMigrator(db, "sqlite", migrations...)
+	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+
+	fmt.Printf("\n1. 执行到版本%d，并写入数据\n", builtin)
+	printMigrations("已执行", mustMigrate(migrator.Up(ctx, builtin)))
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := db.Exec("INSERT INTO users (username, email) VALUES (?, ?)", name, name+"@example.com"); err != nil {
+			log.Fatalf("插入用户失败: %v", err)
+		}
+	}
+
+	fmt.Println("\n2. 执行剩余迁移，Go迁移回填已有数据")
+	printMigrations("已执行", mustMigrate(migrator.Up(ctx, 0)))
+	rows, err := db.Query("SELECT username, display_name FROM users ORDER BY id")
+	if err != nil {
+		log.Fatalf("查询失败: %v", err)
+	}
+	for rows.Next() {
+		var username, displayName string
+		if err := rows.Scan(&username, &displayName); err != nil {
+			log.Fatalf("读取失败: %v", err)
+		}
+		fmt.Printf("   %s -> %s\n", username, displayName)
+	}
+	rows.Close()
+
+	fmt.Println("\n3. 迁移状态")
+	printMigrationStatus(ctx, migrator)
+
+	fmt.Println("\n4. 重做最近一次迁移，再回滚两个版本后重新执行")
+	redone, err := migrator.Redo(ctx)
+	if err != nil {
+		log.Fatalf("重做失败: %v", err)
+	}
+	fmt.Printf("   已重做 %s\n", redone)
+	printMigrations("已回滚", mustMigrate(migrator.Down(ctx, 2)))
+	printMigrations("已执行", mustMigrate(migrator.Up(ctx, 0)))
+
+	fmt.Println("\n5. 并发运行时，后来的迁移器等待锁")
+	slow := append(migrations, Migration{
+		Version: builtin + 2,
+		Name:    "slow",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			time.Sleep(500 * time.Millisecond)
+			return nil
+		},
+		DownSQL: "SELECT 1;",
+	})
+	first, _ := NewMigrator(db, "sqlite", slow...)
+	impatient, _ := NewMigrator(db, "sqlite", slow...)
+	impatient.LockTimeout = 100 * time.Millisecond
+	patient, _ := NewMigrator(db, "sqlite", slow...)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		printMigrations("第一个迁移器已执行", mustMigrate(first.Up(ctx, 0)))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := impatient.Up(ctx, 0); errors.Is(err, ErrMigrationLocked) {
+		fmt.Printf("   等待100ms的迁移器放弃: %v\n", err)
+	} else {
+		log.Fatalf("期望迁移锁错误，实际为: %v", err)
+	}
+	printMigrations("等待锁的迁移器已执行", mustMigrate(patient.Up(ctx, 0)))
+	wg.Wait()
+}
+
+func mustMigrate(migrations []Migration, err error) []Migration {
+	if err != nil {
+		log.Fatalf("迁移失败: %v", err)
+	}
+	return migrations
+}
+
+func printMigrations(action string, migrations []Migration) {
+	if len(migrations) == 0 {
+		fmt.Printf("   %s: 无\n", action)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("   %s %s\n", action, m)
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("查询迁移状态失败: %v", err)
+	}
+	for _, s := range statuses {
+		state := "未执行"
+		if s.Applied {
+			state = "已执行于 " + s.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Printf("   %s  %s\n", s.Migration, state)
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockWithTable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "lock.db"))
+	if err != nil {
+		t.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("获取连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	if err := lockWithTable(ctx, conn, "第一个进程", time.Second); err != nil {
+		t.Fatalf("加锁失败: %v", err)
+	}
+	// 锁已被持有时等到超时
+	start := time.Now()
+	if err := lockWithTable(ctx, conn, "第二个进程", 200*time.Millisecond); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("期望 ErrMigrationLocked，实际 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("应等待到超时，实际只等了 %v", elapsed)
+	}
+	if err := unlockTable(ctx, conn); err != nil {
+		t.Fatalf("解锁失败: %v", err)
+	}
+
+	// 插入因锁冲突以外的原因失败时立即返回错误，不能一直重试
+	if _, err := conn.ExecContext(ctx, `CREATE TRIGGER reject_lock BEFORE INSERT ON schema_migrations_lock
+		BEGIN SELECT RAISE(ABORT, '拒绝写入'); END`); err != nil {
+		t.Fatalf("创建触发器失败: %v", err)
+	}
+	err = lockWithTable(ctx, conn, "第三个进程", time.Hour)
+	if err == nil || errors.Is(err, ErrMigrationLocked) || ctx.Err() != nil {
+		t.Fatalf("期望插入失败的原始错误，实际 %v", err)
+	}
+}
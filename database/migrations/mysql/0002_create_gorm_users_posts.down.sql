@@ -0,0 +1,2 @@
+DROP TABLE posts;
+DROP TABLE gorm_users;
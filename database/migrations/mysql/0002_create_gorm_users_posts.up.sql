@@ -0,0 +1,22 @@
+-- 与 GormUser、Post 模型对应
+CREATE TABLE gorm_users (
+	id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+	username VARCHAR(100) NOT NULL,
+	email VARCHAR(100) NOT NULL,
+	age BIGINT DEFAULT 18,
+	active BOOLEAN DEFAULT true,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	deleted_at DATETIME(3) NULL,
+	UNIQUE KEY uni_gorm_users_username (username),
+	KEY idx_gorm_users_deleted_at (deleted_at)
+);
+CREATE TABLE posts (
+	id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+	title VARCHAR(200) NOT NULL,
+	content TEXT,
+	user_id BIGINT UNSIGNED,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	CONSTRAINT fk_gorm_users_posts FOREIGN KEY (user_id) REFERENCES gorm_users (id)
+);
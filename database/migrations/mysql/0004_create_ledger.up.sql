@@ -0,0 +1,36 @@
+-- 复式记账：金额以最小货币单位(分)存储，分录和明细写入后不能修改或删除
+CREATE TABLE ledger_accounts (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	currency CHAR(3) NOT NULL DEFAULT 'CNY',
+	allow_negative BOOLEAN NOT NULL DEFAULT false,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE ledger_entries (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	idempotency_key VARCHAR(100) UNIQUE,
+	description VARCHAR(200) NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE ledger_postings (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	entry_id BIGINT NOT NULL,
+	account_id BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	CONSTRAINT fk_ledger_postings_entry FOREIGN KEY (entry_id) REFERENCES ledger_entries (id),
+	CONSTRAINT fk_ledger_postings_account FOREIGN KEY (account_id) REFERENCES ledger_accounts (id),
+	CONSTRAINT chk_ledger_postings_amount CHECK (amount <> 0),
+	INDEX idx_ledger_postings_account_entry (account_id, entry_id)
+);
+CREATE TABLE ledger_snapshots (
+	account_id BIGINT NOT NULL,
+	entry_id BIGINT NOT NULL,
+	balance BIGINT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (account_id, entry_id),
+	CONSTRAINT fk_ledger_snapshots_account FOREIGN KEY (account_id) REFERENCES ledger_accounts (id)
+);
+CREATE TRIGGER ledger_entries_no_update BEFORE UPDATE ON ledger_entries FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger entries are immutable';
+CREATE TRIGGER ledger_entries_no_delete BEFORE DELETE ON ledger_entries FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger entries are immutable';
+CREATE TRIGGER ledger_postings_no_update BEFORE UPDATE ON ledger_postings FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger postings are immutable';
+CREATE TRIGGER ledger_postings_no_delete BEFORE DELETE ON ledger_postings FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger postings are immutable';
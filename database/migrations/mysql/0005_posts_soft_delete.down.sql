@@ -0,0 +1 @@
+ALTER TABLE posts DROP KEY idx_posts_deleted_at, DROP COLUMN deleted_at;
@@ -0,0 +1,2 @@
+-- 文章随用户软删除，与 Post.DeletedAt 对应
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME(3) NULL, ADD KEY idx_posts_deleted_at (deleted_at);
@@ -0,0 +1,16 @@
+-- 与 AuditRecord 模型对应，只能追加
+CREATE TABLE audit_log (
+	id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+	entity VARCHAR(50) NOT NULL,
+	entity_id VARCHAR(64) NOT NULL,
+	action VARCHAR(10) NOT NULL,
+	actor VARCHAR(100),
+	request_id VARCHAR(100),
+	`before` JSON,
+	`after` JSON,
+	created_at DATETIME(3) NOT NULL,
+	INDEX idx_audit_log_entity (entity, entity_id, created_at),
+	INDEX idx_audit_log_created_at (created_at)
+);
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log is append-only';
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log is append-only';
@@ -0,0 +1,20 @@
+-- 与 GormUser、Post 模型对应
+CREATE TABLE gorm_users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username VARCHAR(100) NOT NULL UNIQUE,
+	email VARCHAR(100) NOT NULL,
+	age INTEGER DEFAULT 18,
+	active NUMERIC DEFAULT true,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME
+);
+CREATE INDEX idx_gorm_users_deleted_at ON gorm_users (deleted_at);
+CREATE TABLE posts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(200) NOT NULL,
+	content TEXT,
+	user_id INTEGER REFERENCES gorm_users (id),
+	created_at DATETIME,
+	updated_at DATETIME
+);
@@ -0,0 +1 @@
+DROP INDEX uni_users_username;
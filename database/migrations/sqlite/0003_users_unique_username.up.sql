@@ -0,0 +1 @@
+CREATE UNIQUE INDEX uni_users_username ON users (username);
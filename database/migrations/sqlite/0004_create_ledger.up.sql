@@ -0,0 +1,33 @@
+-- 复式记账：金额以最小货币单位(分)存储，分录和明细写入后不能修改或删除
+CREATE TABLE ledger_accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	currency CHAR(3) NOT NULL DEFAULT 'CNY',
+	allow_negative BOOLEAN NOT NULL DEFAULT false,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE ledger_entries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	idempotency_key VARCHAR(100) UNIQUE,
+	description VARCHAR(200) NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE ledger_postings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entry_id INTEGER NOT NULL REFERENCES ledger_entries (id),
+	account_id INTEGER NOT NULL REFERENCES ledger_accounts (id),
+	amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+CREATE INDEX idx_ledger_postings_account_entry ON ledger_postings (account_id, entry_id);
+CREATE INDEX idx_ledger_postings_entry ON ledger_postings (entry_id);
+CREATE TABLE ledger_snapshots (
+	account_id INTEGER NOT NULL REFERENCES ledger_accounts (id),
+	entry_id INTEGER NOT NULL,
+	balance BIGINT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (account_id, entry_id)
+);
+CREATE TRIGGER ledger_entries_no_update BEFORE UPDATE ON ledger_entries BEGIN SELECT RAISE(ABORT, 'ledger entries are immutable'); END;
+CREATE TRIGGER ledger_entries_no_delete BEFORE DELETE ON ledger_entries BEGIN SELECT RAISE(ABORT, 'ledger entries are immutable'); END;
+CREATE TRIGGER ledger_postings_no_update BEFORE UPDATE ON ledger_postings BEGIN SELECT RAISE(ABORT, 'ledger postings are immutable'); END;
+CREATE TRIGGER ledger_postings_no_delete BEFORE DELETE ON ledger_postings BEGIN SELECT RAISE(ABORT, 'ledger postings are immutable'); END;
@@ -0,0 +1,2 @@
+DROP INDEX idx_posts_deleted_at;
+ALTER TABLE posts DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+-- 文章随用户软删除，与 Post.DeletedAt 对应
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME;
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at);
@@ -0,0 +1,16 @@
+-- 与 AuditRecord 模型对应，只能追加
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entity VARCHAR(50) NOT NULL,
+	entity_id VARCHAR(64) NOT NULL,
+	action VARCHAR(10) NOT NULL,
+	actor VARCHAR(100),
+	request_id VARCHAR(100),
+	before TEXT,
+	after TEXT,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_audit_log_entity ON audit_log (entity, entity_id, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go-basics/config"
+)
+
+// Open 按配置打开数据库并应用连接池参数，返回前会检查连接
+func Open(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyPool(db)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法连接到数据库: %w", err)
+	}
+	return db, nil
+}
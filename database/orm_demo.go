@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -31,6 +33,7 @@ type Post struct {
 	UserID    uint
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
 // DemonstrateORM 展示GORM的使用
@@ -45,8 +48,20 @@ func DemonstrateORM() {
 		log.Fatalf("无法连接到数据库: %v", err)
 	}
 
-	// 自动迁移
-	db.AutoMigrate(&GormUser{}, &Post{})
+	// GORM 日志保持静默，由插件记录超过100ms的慢查询并统计每类语句的耗时
+	instrumentation := NewInstrumentation(100 * time.Millisecond)
+	if err := db.Use(instrumentation); err != nil {
+		log.Fatalf("注册插件失败: %v", err)
+	}
+
+	// 执行迁移，表结构与 GormUser、Post 模型对应
+	migrator, err := NewGormMigrator(db)
+	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		log.Fatalf("迁移失败: %v", err)
+	}
 	fmt.Println("1. 成功创建表")
 
 	// 创建用户
@@ -70,6 +85,29 @@ func DemonstrateORM() {
 
 	// 查询关联
 	queryUserWithPosts(db)
+
+	// 回收站：级联软删除、恢复和彻底删除
+	trashExample(db)
+
+	// 跟踪一次操作执行的语句，以及总耗时最多的语句
+	fmt.Println("\n9. 语句跟踪和耗时统计")
+	ctx, trace := WithQueryTrace(context.Background())
+	var users []GormUser
+	db.WithContext(ctx).Preload("Posts").Where("age > ?", 20).Find(&users)
+	for _, span := range trace.Spans() {
+		fmt.Println(span)
+	}
+	printQueryStats(&instrumentation.Stats, 5)
+}
+
+// printQueryStats 打印总耗时最多的 n 类语句
+func printQueryStats(stats *QueryStats, n int) {
+	fmt.Printf("总耗时前%d的语句:\n", n)
+	for _, stat := range stats.Top(n) {
+		fmt.Printf("%6d次 总计%-12v 平均%-12v 最长%-12v %s\n",
+			stat.Count, stat.Total.Round(time.Microsecond), stat.Avg().Round(time.Microsecond),
+			stat.Max.Round(time.Microsecond), stat.Fingerprint)
+	}
 }
 
 // createGormUsers 创建GORM用户
@@ -94,13 +132,27 @@ func createGormUsers(db *gorm.DB) {
 func queryGormUsers(db *gorm.DB) {
 	fmt.Println("\n3. 查询用户")
 
-	// 查询所有用户
-	var users []GormUser
-	db.Find(&users)
-	fmt.Printf("找到 %d 个用户:\n", len(users))
-	for _, user := range users {
+	// 逐行查询所有用户
+	count := 0
+	for user, err := range GormRows[GormUser](db.Order("id")) {
+		if err != nil {
+			log.Printf("查询失败: %v", err)
+			return
+		}
 		fmt.Printf("- ID=%d, 用户名=%s, 邮箱=%s, 年龄=%d\n",
 			user.ID, user.Username, user.Email, user.Age)
+		count++
+	}
+	fmt.Printf("找到 %d 个用户\n", count)
+
+	// 按主键分批查询，每批2条
+	fmt.Println("\n分批查询:")
+	for user, err := range GormBatches[GormUser](db.Where("age >= ?", 18), 2) {
+		if err != nil {
+			log.Printf("查询失败: %v", err)
+			return
+		}
+		fmt.Printf("- ID=%d, 用户名=%s\n", user.ID, user.Username)
 	}
 
 	// 条件查询
@@ -160,6 +212,63 @@ func deleteGormUser(db *gorm.DB) {
 	// db.Unscoped().Delete(&GormUser{}, 3)
 }
 
+// trashExample 软删除用户时文章一起进入回收站，恢复时只恢复一起删除的文章
+func trashExample(db *gorm.DB) {
+	fmt.Println("\n8. 回收站")
+	ctx := context.Background()
+	users := NewGormUserRepository(db)
+	countPosts := func(userID uint) int64 {
+		var n int64
+		db.Model(&Post{}).Where("user_id = ?", userID).Count(&n)
+		return n
+	}
+
+	// 先单独删除用户1的第一篇文章，再删除用户1
+	db.Delete(&Post{}, 1)
+	if err := users.SoftDelete(ctx, 1); err != nil {
+		log.Fatalf("删除用户失败: %v", err)
+	}
+	fmt.Printf("软删除用户ID=1，可见文章数: %d\n", countPosts(1))
+
+	trashed, err := users.Trashed(ctx, 1, 10)
+	if err != nil {
+		log.Fatalf("查询回收站失败: %v", err)
+	}
+	fmt.Printf("回收站中有 %d 个用户:\n", trashed.Total)
+	for _, u := range trashed.Items {
+		fmt.Printf("- ID=%d, 用户名=%s, 随用户删除的文章=%d\n", u.ID, u.Username, u.Posts)
+	}
+
+	restored, err := users.Restore(ctx, 1)
+	if err != nil {
+		log.Fatalf("恢复用户失败: %v", err)
+	}
+	fmt.Printf("恢复用户: ID=%d, 用户名=%s, 可见文章数: %d (单独删除的文章仍在回收站)\n",
+		restored.ID, restored.Username, countPosts(1))
+
+	// 只能彻底删除回收站中的用户
+	if _, err := users.Purge(ctx, 2); errors.Is(err, ErrConflict) {
+		fmt.Printf("彻底删除用户ID=2失败: %v\n", err)
+	}
+	if err := users.SoftDelete(ctx, 2); err != nil {
+		log.Fatalf("删除用户失败: %v", err)
+	}
+	purged, err := users.Purge(ctx, 2)
+	if err != nil {
+		log.Fatalf("彻底删除用户失败: %v", err)
+	}
+	fmt.Printf("彻底删除用户ID=2: %d 个用户、%d 篇文章\n", purged.Users, purged.Posts)
+
+	// 定期清理任务删除超过保留时间的记录，这里保留时间极短，剩下的回收站记录都会被清理
+	job := NewPurgeJob(users, PurgeJobOptions{Retention: time.Nanosecond})
+	if _, err := job.RunOnce(ctx); err != nil {
+		log.Fatalf("清理回收站失败: %v", err)
+	}
+	var total int64
+	db.Unscoped().Model(&GormUser{}).Count(&total)
+	fmt.Printf("清理后包括已删除的总用户数: %d\n", total)
+}
+
 // createPostsForUser 为用户创建文章
 func createPostsForUser(db *gorm.DB) {
 	fmt.Println("\n6. 创建文章")
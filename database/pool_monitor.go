@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 连接池监控的默认值
+const (
+	DefaultPoolMonitorInterval = 10 * time.Second
+	DefaultPoolSaturation      = 0.9
+	DefaultPoolTargetWait      = 5 * time.Millisecond
+)
+
+// PoolSample 一次连接池采样，Delta 字段是与上次采样的差值
+type PoolSample struct {
+	Time time.Time
+	sql.DBStats
+	WaitCountDelta    int64
+	WaitDurationDelta time.Duration
+}
+
+// AvgWait 本周期内每次等待连接的平均时间
+func (s PoolSample) AvgWait() time.Duration {
+	if s.WaitCountDelta == 0 {
+		return 0
+	}
+	return s.WaitDurationDelta / time.Duration(s.WaitCountDelta)
+}
+
+// Saturated 使用中的连接达到上限的 ratio 且本周期有请求在等待连接
+func (s PoolSample) Saturated(ratio float64) bool {
+	return s.MaxOpenConnections > 0 && s.WaitCountDelta > 0 &&
+		float64(s.InUse) >= ratio*float64(s.MaxOpenConnections)
+}
+
+// PoolMonitorOptions 连接池监控选项，零值使用默认值且不自动调整
+type PoolMonitorOptions struct {
+	Interval   time.Duration // 采样间隔
+	Saturation float64       // 使用中连接占上限的比例，超过且有等待时告警
+	Logger     *log.Logger
+
+	// MinOpenConns 和 MaxOpenConns 都大于0时按等待时间在此范围内调整 SetMaxOpenConns：
+	// 平均等待超过 TargetWait 时增加一半，没有等待且使用不到一半时减少四分之一
+	MinOpenConns int
+	MaxOpenConns int
+	TargetWait   time.Duration
+}
+
+// PoolMonitor 定期采样 sql.DBStats，导出指标，饱和时告警，可选地自动调整连接数上限
+type PoolMonitor struct {
+	db   *sql.DB
+	opts PoolMonitorOptions
+
+	mu   sync.Mutex
+	last PoolSample
+}
+
+// NewPoolMonitor 创建连接池监控，调用 Run 开始采样
+func NewPoolMonitor(db *sql.DB, opts PoolMonitorOptions) *PoolMonitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPoolMonitorInterval
+	}
+	if opts.Saturation <= 0 {
+		opts.Saturation = DefaultPoolSaturation
+	}
+	if opts.TargetWait <= 0 {
+		opts.TargetWait = DefaultPoolTargetWait
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	return &PoolMonitor{db: db, opts: opts, last: PoolSample{Time: time.Now(), DBStats: db.Stats()}}
+}
+
+// Run 每隔 Interval 采样一次，直到 ctx 取消
+func (m *PoolMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Sample()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sample 立即采样一次：记录指标，饱和时告警，开启自动调整时修改连接数上限
+func (m *PoolMonitor) Sample() PoolSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.db.Stats()
+	sample := PoolSample{
+		Time:              time.Now(),
+		DBStats:           stats,
+		WaitCountDelta:    stats.WaitCount - m.last.WaitCount,
+		WaitDurationDelta: stats.WaitDuration - m.last.WaitDuration,
+	}
+	m.last = sample
+
+	if sample.Saturated(m.opts.Saturation) {
+		m.opts.Logger.Printf("连接池饱和: 使用中 %d/%d，本周期等待 %d 次，平均等待 %v",
+			sample.InUse, sample.MaxOpenConnections, sample.WaitCountDelta, sample.AvgWait())
+	}
+	if limit, ok := m.adjust(sample); ok {
+		m.opts.Logger.Printf("连接数上限调整: %d -> %d (平均等待 %v)", sample.MaxOpenConnections, limit, sample.AvgWait())
+		m.db.SetMaxOpenConns(limit)
+	}
+	return sample
+}
+
+// adjust 根据采样计算新的连接数上限，不需要调整时返回 false
+func (m *PoolMonitor) adjust(s PoolSample) (int, bool) {
+	lo, hi := m.opts.MinOpenConns, m.opts.MaxOpenConns
+	if lo <= 0 || hi < lo {
+		return 0, false
+	}
+	// 0 表示不限制，视为已在上限
+	limit := s.MaxOpenConnections
+	if limit == 0 {
+		limit = hi
+	}
+
+	next := limit
+	switch {
+	case s.AvgWait() > m.opts.TargetWait:
+		next = limit + max(limit/2, 1)
+	case s.WaitCountDelta == 0 && s.InUse < limit/2:
+		next = limit - max(limit/4, 1)
+	}
+	next = min(max(next, lo), hi)
+	return next, next != s.MaxOpenConnections
+}
+
+// Last 返回最近一次采样
+func (m *PoolMonitor) Last() PoolSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Publish 以 name 将最近一次采样导出到 expvar，通过 expvar.Handler() 的 /debug/vars 查看
+// name 在进程内已被使用时返回错误
+func (m *PoolMonitor) Publish(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar 变量 %q 已存在", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		s := m.Last()
+		return map[string]interface{}{
+			"max_open_connections": s.MaxOpenConnections,
+			"open_connections":     s.OpenConnections,
+			"in_use":               s.InUse,
+			"idle":                 s.Idle,
+			"wait_count":           s.WaitCount,
+			"wait_duration_ms":     s.WaitDuration.Milliseconds(),
+			"avg_wait_ms":          float64(s.AvgWait().Microseconds()) / 1000,
+			"saturated":            s.Saturated(m.opts.Saturation),
+		}
+	}))
+	return nil
+}
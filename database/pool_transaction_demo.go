@@ -3,11 +3,17 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"go-basics/config"
+
 	"github.com/glebarez/sqlite" // 纯Go实现的SQLite驱动
 	"gorm.io/gorm"
 )
@@ -33,20 +39,47 @@ func DemonstratePoolAndTransaction() {
 	demonstrateGORMTransaction()
 }
 
-// demonstrateSQLPool 展示原生SQL连接池
+// demonstrateSQLPool 展示原生SQL连接池和连接池监控
 func demonstrateSQLPool() {
-	// 打开数据库连接
-	db, err := sql.Open("sqlite", ":memory:")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 打开数据库连接，文件数据库让多个连接访问同一个库
+	dir, err := os.MkdirTemp("", "go-basics-pool")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "pool.db"))
 	if err != nil {
 		log.Fatalf("无法打开数据库: %v", err)
 	}
 	defer db.Close()
 
-	// 配置连接池
-	db.SetMaxOpenConns(25)                  // 最大打开连接数
-	db.SetMaxIdleConns(10)                  // 最大空闲连接数
-	db.SetConnMaxLifetime(5 * time.Minute)  // 连接最大生命周期
-	db.SetConnMaxIdleTime(10 * time.Minute) // 空闲连接最大生命周期
+	// 配置连接池，参数来自配置文件或环境变量，修改后热加载
+	manager, err := config.NewManager(config.DefaultOptions())
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	manager.Current().Database.ApplyPool(db)
+	manager.OnChange(func(cfg *config.Config) {
+		cfg.Database.ApplyPool(db)
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	manager.Watch(5*time.Second, stop)
+
+	// 故意从2个连接开始，监控发现等待时间过长后在 2~配置上限 之间调整
+	db.SetMaxOpenConns(2)
+	monitor := NewPoolMonitor(db, PoolMonitorOptions{
+		Interval:     100 * time.Millisecond,
+		MinOpenConns: 2,
+		MaxOpenConns: manager.Current().Database.MaxOpenConns,
+	})
+	if err := monitor.Publish("database_pool"); err != nil {
+		log.Printf("导出连接池指标失败: %v", err)
+	}
+	go monitor.Run(ctx)
 
 	// 创建测试表
 	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS pool_test (
@@ -58,30 +91,34 @@ func demonstrateSQLPool() {
 	}
 
 	// 展示连接池状态
-	printPoolStats(db)
+	printPoolStats(monitor.Sample())
 
-	// 模拟高并发请求
-	var wg sync.WaitGroup
+	// 模拟高并发请求，分三轮进行，观察上限的调整
 	start := time.Now()
-
-	// 启动20个并发goroutine
-	for i := 0; i < 20; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			// 模拟数据库操作
-			_, err := db.Exec("INSERT INTO pool_test (data) VALUES (?)",
-				fmt.Sprintf("数据 %d", id))
-			if err != nil {
-				log.Printf("插入失败: %v", err)
-			}
-			// 模拟一些处理时间
-			time.Sleep(100 * time.Millisecond)
-		}(i)
+	for round := 1; round <= 3; round++ {
+		var wg sync.WaitGroup
+		// 启动20个并发goroutine
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				// 模拟持有连接时的处理时间，例如在一个连接上执行多条语句
+				conn, err := db.Conn(ctx)
+				if err != nil {
+					log.Printf("获取连接失败: %v", err)
+					return
+				}
+				defer conn.Close()
+				if _, err := conn.ExecContext(ctx, "INSERT INTO pool_test (data) VALUES (?)",
+					fmt.Sprintf("数据 %d-%d", round, id)); err != nil {
+					log.Printf("插入失败: %v", err)
+				}
+				time.Sleep(50 * time.Millisecond)
+			}(i)
+		}
+		wg.Wait()
+		fmt.Printf("第%d轮完成，连接数上限 %d\n", round, db.Stats().MaxOpenConnections)
 	}
-
-	// 等待所有goroutine完成
-	wg.Wait()
 	elapsed := time.Since(start)
 
 	// 查询结果
@@ -90,8 +127,9 @@ func demonstrateSQLPool() {
 	fmt.Printf("插入了 %d 条记录\n", count)
 	fmt.Printf("总耗时: %v\n", elapsed)
 
-	// 再次展示连接池状态
-	printPoolStats(db)
+	// 再次展示连接池状态和导出的指标
+	printPoolStats(monitor.Sample())
+	fmt.Printf("expvar database_pool: %s\n", expvar.Get("database_pool"))
 
 	// 连接池最佳实践
 	fmt.Println("\n连接池最佳实践:")
@@ -102,68 +140,56 @@ func demonstrateSQLPool() {
 	fmt.Println("5. 总是关闭查询结果集(rows.Close())以释放连接")
 }
 
-// printPoolStats 打印连接池状态
-func printPoolStats(db *sql.DB) {
-	stats := db.Stats()
+// printPoolStats 打印一次连接池采样
+func printPoolStats(s PoolSample) {
 	fmt.Println("\n连接池状态:")
-	fmt.Printf("打开的连接数: %d\n", stats.OpenConnections)
-	fmt.Printf("使用中的连接数: %d\n", stats.InUse)
-	fmt.Printf("空闲连接数: %d\n", stats.Idle)
-	fmt.Printf("等待的连接请求数: %d\n", stats.WaitCount)
-	fmt.Printf("最大等待时间: %v\n", stats.WaitDuration)
+	fmt.Printf("连接数上限: %d\n", s.MaxOpenConnections)
+	fmt.Printf("打开的连接数: %d\n", s.OpenConnections)
+	fmt.Printf("使用中的连接数: %d\n", s.InUse)
+	fmt.Printf("空闲连接数: %d\n", s.Idle)
+	fmt.Printf("等待的连接请求数: %d\n", s.WaitCount)
+	fmt.Printf("累计等待时间: %v\n", s.WaitDuration)
 }
 
-// demonstrateSQLTransaction 展示原生SQL事务处理
+// demonstrateSQLTransaction 展示原生SQL事务处理，转账通过复式记账账本完成
 func demonstrateSQLTransaction() {
-	// 打开数据库连接
+	ctx := context.Background()
+
+	// 打开数据库连接，内存数据库的每个连接是独立的库，所以只用一个连接
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		log.Fatalf("无法打开数据库: %v", err)
 	}
 	defer db.Close()
+	db.SetMaxOpenConns(1)
 
-	// 创建测试表
-	_, err = db.Exec(`CREATE TABLE accounts (
-		id INTEGER PRIMARY KEY,
-		name TEXT,
-		balance REAL
-	)`)
-	if err != nil {
-		log.Fatalf("创建表失败: %v", err)
-	}
-
-	// 插入初始数据
-	_, err = db.Exec(`INSERT INTO accounts (id, name, balance) VALUES 
-		(1, '张三', 1000), 
-		(2, '李四', 500)`)
-	if err != nil {
-		log.Fatalf("插入数据失败: %v", err)
-	}
+	// 创建账本并开户
+	ledger := newDemoLedger(ctx, db)
 
 	// 打印初始余额
-	printBalances(db)
+	printBalances(ctx, ledger)
 
 	// 1. 基本事务 - 转账成功
 	fmt.Println("\n1.1 基本事务 - 转账成功示例")
-	transferMoney(db, 1, 2, 200, false)
-	printBalances(db)
+	transferMoney(ledger, 1, 2, Yuan(200))
+	printBalances(ctx, ledger)
 
 	// 2. 事务回滚 - 转账失败
 	fmt.Println("\n1.2 事务回滚 - 转账失败示例")
-	transferMoney(db, 1, 2, 2000, false) // 余额不足
-	printBalances(db)
+	transferMoney(ledger, 1, 2, Yuan(2000)) // 余额不足
+	printBalances(ctx, ledger)
 
 	// 3. 带有上下文的事务
 	fmt.Println("\n1.3 带有上下文的事务")
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
-	transferMoneyWithContext(ctx, db, 2, 1, 100)
-	printBalances(db)
+	transferMoneyWithContext(ctx, ledger, 2, 1, Yuan(100))
+	printBalances(ctx, ledger)
 
-	// 4. 嵌套事务 (SQLite不支持真正的嵌套事务，这里模拟实现)
-	fmt.Println("\n1.4 嵌套事务 (模拟)")
-	nestedTransactionDemo(db)
-	printBalances(db)
+	// 4. 嵌套事务，内层 WithTx 使用保存点
+	fmt.Println("\n1.4 嵌套事务 (保存点)")
+	nestedTransactionDemo(db, ledger)
+	printBalances(ctx, ledger)
 
 	// 5. 事务隔离级别
 	fmt.Println("\n1.5 事务隔离级别")
@@ -173,9 +199,14 @@ func demonstrateSQLTransaction() {
 	fmt.Println("- READ COMMITTED: 只能读取已提交的数据")
 	fmt.Println("- REPEATABLE READ: 确保事务内多次读取结果一致")
 	fmt.Println("- SERIALIZABLE: 最高级别，完全隔离")
+	readOnlyTotal(db)
+
+	// 6. 幂等、快照和对账
+	fmt.Println("\n1.6 幂等转账、余额快照和对账")
+	ledgerDemo(ctx, ledger)
 
-	// 6. 事务最佳实践
-	fmt.Println("\n1.6 事务最佳实践:")
+	// 7. 事务最佳实践
+	fmt.Println("\n1.7 事务最佳实践:")
 	fmt.Println("1. 事务应尽可能短")
 	fmt.Println("2. 避免在事务中进行耗时操作")
 	fmt.Println("3. 正确处理错误和回滚")
@@ -184,204 +215,180 @@ func demonstrateSQLTransaction() {
 	fmt.Println("6. 使用defer确保事务正确结束")
 }
 
-// printBalances 打印所有账户余额
-func printBalances(db *sql.DB) {
-	fmt.Println("当前账户余额:")
-	rows, err := db.Query("SELECT id, name, balance FROM accounts")
+// newDemoLedger 执行迁移，创建张三(ID 1)、李四(ID 2)和外部资金账户，并从外部资金入账
+func newDemoLedger(ctx context.Context, db *sql.DB) *Ledger {
+	migrator, err := NewMigrator(db, "sqlite")
 	if err != nil {
-		log.Printf("查询失败: %v", err)
-		return
+		log.Fatalf("创建迁移器失败: %v", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var id int
-		var name string
-		var balance float64
-		rows.Scan(&id, &name, &balance)
-		fmt.Printf("账户 %d (%s): %.2f\n", id, name, balance)
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		log.Fatalf("创建表失败: %v", err)
 	}
-}
-
-// transferMoney 转账函数
-func transferMoney(db *sql.DB, fromID, toID int, amount float64, slowMode bool) {
-	// 开始事务
-	tx, err := db.Begin()
+	ledger, err := NewLedger(db, "sqlite")
 	if err != nil {
-		log.Printf("开始事务失败: %v", err)
-		return
+		log.Fatalf("创建账本失败: %v", err)
 	}
+	seedLedger(ctx, ledger)
+	return ledger
+}
 
-	// 使用defer和recover确保事务正确结束
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			log.Printf("事务panic并回滚: %v", r)
+// seedLedger 开户并从外部资金账户入账
+func seedLedger(ctx context.Context, ledger *Ledger) {
+	initial := map[string]Amount{"张三": Yuan(1000), "李四": Yuan(500)}
+	for _, name := range []string{"张三", "李四"} {
+		if _, err := ledger.CreateAccount(ctx, name, "CNY", false); err != nil {
+			log.Fatalf("创建账户失败: %v", err)
 		}
-	}()
-
-	// 检查余额是否足够
-	var balance float64
-	err = tx.QueryRow("SELECT balance FROM accounts WHERE id = ?", fromID).Scan(&balance)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("查询余额失败: %v", err)
-		return
 	}
-
-	if balance < amount {
-		tx.Rollback()
-		log.Printf("余额不足: 当前%.2f, 需要%.2f", balance, amount)
-		return
+	external, err := ledger.CreateAccount(ctx, "外部资金", "CNY", true)
+	if err != nil {
+		log.Fatalf("创建账户失败: %v", err)
 	}
-
-	// 模拟慢速操作，用于演示长事务的问题
-	if slowMode {
-		fmt.Println("事务中执行慢速操作...")
-		time.Sleep(2 * time.Second)
+	for id, name := range []string{"张三", "李四"} {
+		if _, err := ledger.Transfer(ctx, "deposit-"+name, external.ID, int64(id+1), initial[name], "初始入账"); err != nil {
+			log.Fatalf("入账失败: %v", err)
+		}
 	}
+}
 
-	// 扣除发送方余额
-	_, err = tx.Exec("UPDATE accounts SET balance = balance - ? WHERE id = ?", amount, fromID)
+// printBalances 打印所有账户余额
+func printBalances(ctx context.Context, ledger *Ledger) {
+	fmt.Println("当前账户余额:")
+	balances, err := ledger.Balances(ctx)
 	if err != nil {
-		tx.Rollback()
-		log.Printf("扣款失败: %v", err)
+		log.Printf("查询失败: %v", err)
 		return
 	}
-
-	// 增加接收方余额
-	_, err = tx.Exec("UPDATE accounts SET balance = balance + ? WHERE id = ?", amount, toID)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("加款失败: %v", err)
-		return
+	for _, b := range balances {
+		fmt.Printf("账户 %d (%s): %s\n", b.ID, b.Name, b.Balance)
 	}
+}
 
-	// 提交事务
-	err = tx.Commit()
-	if err != nil {
-		log.Printf("提交事务失败: %v", err)
+// transferMoney 转账函数，并发转账时遇到锁冲突整个事务自动重试
+func transferMoney(ledger *Ledger, fromID, toID int64, amount Amount) {
+	if _, err := ledger.Transfer(context.Background(), "", fromID, toID, amount, "转账"); err != nil {
+		log.Printf("转账失败，事务已回滚: %v", err)
 		return
 	}
 
-	fmt.Printf("成功从账户%d转账%.2f到账户%d\n", fromID, amount, toID)
+	fmt.Printf("成功从账户%d转账%s到账户%d\n", fromID, amount, toID)
 }
 
-// transferMoneyWithContext 带上下文的转账函数
-func transferMoneyWithContext(ctx context.Context, db *sql.DB, fromID, toID int, amount float64) {
-	// 开始事务
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		log.Printf("开始事务失败: %v", err)
+// transferMoneyWithContext 带上下文的转账函数，上下文取消时事务自动回滚
+func transferMoneyWithContext(ctx context.Context, ledger *Ledger, fromID, toID int64, amount Amount) {
+	if _, err := ledger.Transfer(ctx, "", fromID, toID, amount, "带上下文的转账"); err != nil {
+		log.Printf("转账失败，事务已回滚: %v", err)
 		return
 	}
 
-	// 使用defer确保事务正确结束
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			log.Printf("事务panic并回滚: %v", r)
-		}
-	}()
-
-	// 检查上下文是否已取消
-	select {
-	case <-ctx.Done():
-		tx.Rollback()
-		log.Printf("事务上下文已取消: %v", ctx.Err())
-		return
-	default:
-		// 继续执行
-	}
+	fmt.Printf("成功从账户%d转账%s到账户%d (带上下文)\n", fromID, amount, toID)
+}
 
-	// 检查余额
-	var balance float64
-	err = tx.QueryRowContext(ctx, "SELECT balance FROM accounts WHERE id = ?", fromID).Scan(&balance)
+// readOnlyTotal 在只读事务中统计客户账户的总余额，MySQL 会拒绝只读事务中的写操作
+func readOnlyTotal(db *sql.DB) {
+	ctx := context.Background()
+	var total Amount
+	err := WithTx(ctx, db, &sql.TxOptions{ReadOnly: true}, func(tx *Tx) error {
+		return tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(p.amount), 0) FROM ledger_postings p
+JOIN ledger_accounts a ON a.id = p.account_id WHERE NOT a.allow_negative`).Scan(&total)
+	})
 	if err != nil {
-		tx.Rollback()
-		log.Printf("查询余额失败: %v", err)
-		return
-	}
-
-	if balance < amount {
-		tx.Rollback()
-		log.Printf("余额不足: 当前%.2f, 需要%.2f", balance, amount)
+		log.Printf("统计余额失败: %v", err)
 		return
 	}
+	fmt.Printf("只读事务统计客户总余额: %s\n", total)
+}
 
-	// 扣除发送方余额
-	_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - ? WHERE id = ?", amount, fromID)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("扣款失败: %v", err)
-		return
-	}
+// nestedTransactionDemo 嵌套事务示例：内层 WithTx 使用保存点，失败只回滚内层
+func nestedTransactionDemo(db *sql.DB, ledger *Ledger) {
+	ctx := context.Background()
+	err := WithTx(ctx, db, nil, func(tx *Tx) error {
+		// 事务中的账本，每次记账都在一个保存点中
+		ledger := ledger.With(tx)
+		if _, err := ledger.Transfer(ctx, "", 1, 2, Yuan(50), "外部事务"); err != nil {
+			return fmt.Errorf("外部事务转账失败: %w", err)
+		}
+		fmt.Println("外部事务: 从账户1转账50到账户2")
 
-	// 增加接收方余额
-	_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = balance + ? WHERE id = ?", amount, toID)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("加款失败: %v", err)
-		return
-	}
+		// 内层事务成功，保存点被释放
+		err := WithTx(ctx, tx, nil, func(tx *Tx) error {
+			_, err := ledger.With(tx).Transfer(ctx, "", 2, 1, Yuan(30), "内部事务")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println("内部事务: 从账户2转账30到账户1")
 
-	// 提交事务
-	err = tx.Commit()
+		// 内层事务失败，只回滚到保存点，外部事务继续
+		err = WithTx(ctx, tx, nil, func(tx *Tx) error {
+			ledger := ledger.With(tx)
+			if _, err := ledger.Transfer(ctx, "", 1, 2, Yuan(10), "内部事务"); err != nil {
+				return err
+			}
+			_, err := ledger.Transfer(ctx, "", 2, 1, Yuan(5000), "内部事务")
+			return err
+		})
+		fmt.Printf("内部事务(深度%d)失败并回滚到保存点: %v\n", tx.Depth()+1, err)
+
+		// 内层 panic 时回滚到保存点并继续 panic，由外层决定如何处理
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("内部事务panic并回滚到保存点: %v\n", r)
+				}
+			}()
+			WithTx(ctx, tx, nil, func(tx *Tx) error {
+				ledger.With(tx).Transfer(ctx, "", 1, 2, Yuan(1), "内部事务")
+				panic("模拟内部事务panic")
+			})
+		}()
+		return nil
+	})
 	if err != nil {
-		log.Printf("提交事务失败: %v", err)
+		log.Printf("嵌套事务失败: %v", err)
 		return
 	}
 
-	fmt.Printf("成功从账户%d转账%.2f到账户%d (带上下文)\n", fromID, amount, toID)
+	fmt.Println("嵌套事务示例完成")
 }
 
-// nestedTransactionDemo 嵌套事务示例
-func nestedTransactionDemo(db *sql.DB) {
-	// 开始外部事务
-	outerTx, err := db.Begin()
-	if err != nil {
-		log.Printf("开始外部事务失败: %v", err)
-		return
-	}
+// ledgerDemo 展示复式记账的约束、幂等转账、快照和对账
+func ledgerDemo(ctx context.Context, ledger *Ledger) {
+	// 借贷不平衡的分录被拒绝
+	_, err := ledger.Post(ctx, Entry{Postings: []Posting{{AccountID: 1, Amount: -Yuan(10)}, {AccountID: 2, Amount: Yuan(9)}}})
+	fmt.Printf("不平衡的分录: %v (ErrUnbalanced: %t)\n", err, errors.Is(err, ErrUnbalanced))
 
-	// 更新第一个账户
-	_, err = outerTx.Exec("UPDATE accounts SET balance = balance - 50 WHERE id = 1")
+	// 相同幂等键的请求只记账一次，重试安全
+	first, err := ledger.Transfer(ctx, "order-1001", 1, 2, Yuan(25), "订单1001")
 	if err != nil {
-		outerTx.Rollback()
-		log.Printf("外部事务更新失败: %v", err)
-		return
+		log.Fatalf("转账失败: %v", err)
 	}
-
-	fmt.Println("外部事务: 从账户1扣除50")
-
-	// 创建保存点 (SQLite支持)
-	_, err = outerTx.Exec("SAVEPOINT nested_tx")
+	again, err := ledger.Transfer(ctx, "order-1001", 1, 2, Yuan(25), "订单1001")
 	if err != nil {
-		outerTx.Rollback()
-		log.Printf("创建保存点失败: %v", err)
-		return
+		log.Fatalf("重复转账失败: %v", err)
 	}
+	fmt.Printf("幂等键 order-1001 两次请求得到同一分录: %d, %d\n", first.ID, again.ID)
+	_, err = ledger.Transfer(ctx, "order-1001", 1, 2, Yuan(99), "订单1001")
+	fmt.Printf("同一幂等键的不同金额: %v (ErrConflict: %t)\n", err, errors.Is(err, ErrConflict))
 
-	// 模拟内部事务操作
-	_, err = outerTx.Exec("UPDATE accounts SET balance = balance + 30 WHERE id = 2")
+	// 分录写入后不可修改
+	_, err = ledger.db.ExecContext(ctx, "UPDATE ledger_postings SET amount = amount * 2")
+	fmt.Printf("修改已记账的明细: %v (ErrConflict: %t)\n", MapError(err), errors.Is(MapError(err), ErrConflict))
+
+	// 快照之后余额从快照开始累加
+	created, err := ledger.Snapshot(ctx)
 	if err != nil {
-		outerTx.Exec("ROLLBACK TO SAVEPOINT nested_tx")
-		log.Printf("内部事务更新失败并回滚: %v", err)
-		// 外部事务可以继续
-	} else {
-		fmt.Println("内部事务: 向账户2添加30")
-		// 释放保存点
-		outerTx.Exec("RELEASE SAVEPOINT nested_tx")
+		log.Fatalf("创建快照失败: %v", err)
 	}
+	fmt.Printf("创建了 %d 个余额快照\n", created)
+	transferMoney(ledger, 2, 1, Yuan(5))
 
-	// 提交外部事务
-	err = outerTx.Commit()
+	report, err := ledger.Reconcile(ctx)
 	if err != nil {
-		log.Printf("提交外部事务失败: %v", err)
-		return
+		log.Fatalf("对账失败: %v", err)
 	}
-
-	fmt.Println("嵌套事务示例完成")
+	fmt.Println(report)
 }
 
 // demonstrateGORMPool 展示GORM连接池
@@ -398,10 +405,20 @@ func demonstrateGORMPool() {
 		log.Fatalf("获取底层DB失败: %v", err)
 	}
 
-	// 配置连接池
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	// 配置连接池，参数来自配置，监控在等待过长时在配置的上限内自动调整
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	cfg.Database.ApplyPool(sqlDB)
+	monitor := NewPoolMonitor(sqlDB, PoolMonitorOptions{
+		Interval:     100 * time.Millisecond,
+		MinOpenConns: 1,
+		MaxOpenConns: cfg.Database.MaxOpenConns,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go monitor.Run(ctx)
 
 	// 创建测试模型
 	type PoolTest struct {
@@ -436,11 +453,7 @@ func demonstrateGORMPool() {
 	fmt.Printf("总耗时: %v\n", elapsed)
 
 	// 打印连接池状态
-	stats := sqlDB.Stats()
-	fmt.Println("\nGORM连接池状态:")
-	fmt.Printf("打开的连接数: %d\n", stats.OpenConnections)
-	fmt.Printf("使用中的连接数: %d\n", stats.InUse)
-	fmt.Printf("空闲连接数: %d\n", stats.Idle)
+	printPoolStats(monitor.Sample())
 
 	fmt.Println("\nGORM连接池最佳实践:")
 	fmt.Println("1. 通常只需要一个全局的gorm.DB实例")
@@ -451,32 +464,39 @@ func demonstrateGORMPool() {
 
 // demonstrateGORMTransaction 展示GORM事务处理
 func demonstrateGORMTransaction() {
-	// 配置GORM
+	ctx := context.Background()
+
+	// 配置GORM，内存数据库只用一个连接
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("无法连接到数据库: %v", err)
 	}
-
-	// 创建账户模型
-	type Account struct {
-		ID      uint `gorm:"primarykey"`
-		Name    string
-		Balance float64
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("获取底层连接失败: %v", err)
 	}
+	sqlDB.SetMaxOpenConns(1)
 
-	// 自动迁移
-	db.AutoMigrate(&Account{})
-
-	// 插入初始数据
-	db.Create(&Account{ID: 1, Name: "张三", Balance: 1000})
-	db.Create(&Account{ID: 2, Name: "李四", Balance: 500})
+	// 执行迁移创建账本表，开户并入账
+	migrator, err := NewGormMigrator(db)
+	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		log.Fatalf("迁移失败: %v", err)
+	}
+	ledger, err := NewGormLedger(db)
+	if err != nil {
+		log.Fatalf("创建账本失败: %v", err)
+	}
+	seedLedger(ctx, ledger)
 
 	// 打印初始余额
 	printGORMBalances(db)
 
 	// 1. 基本事务
 	fmt.Println("\n4.1 GORM基本事务")
-	transferMoneyGORM(db, 1, 2, 200)
+	transferMoneyGORM(db, 1, 2, Yuan(200))
 	printGORMBalances(db)
 
 	// 2. 手动事务
@@ -486,47 +506,48 @@ func demonstrateGORMTransaction() {
 
 	// 3. 事务闭包
 	fmt.Println("\n4.3 GORM事务闭包")
-	db.Transaction(func(tx *gorm.DB) error {
-		// 在事务中执行操作
-		var account Account
-		if err := tx.First(&account, 1).Error; err != nil {
-			return err
-		}
-
-		// 更新账户
-		account.Balance -= 100
-		if err := tx.Save(&account).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 传入 tx 创建的账本，记账属于这个事务
+		ledger, err := NewGormLedger(tx)
+		if err != nil {
 			return err
 		}
-
-		// 更新另一个账户
-		if err := tx.Model(&Account{}).Where("id = ?", 2).
-			Update("balance", gorm.Expr("balance + ?", 100)).Error; err != nil {
+		if _, err := ledger.Transfer(ctx, "", 1, 2, Yuan(100), "GORM事务闭包"); err != nil {
 			return err
 		}
 
 		fmt.Println("GORM事务闭包: 从账户1转账100到账户2")
 		return nil // 返回nil提交事务
 	})
+	if err != nil {
+		log.Printf("GORM事务闭包失败: %v", err)
+	}
 	printGORMBalances(db)
 
 	// 4. 嵌套事务
 	fmt.Println("\n4.4 GORM嵌套事务")
 	db.Transaction(func(tx *gorm.DB) error {
 		// 外部事务
-		tx.Model(&Account{}).Where("id = ?", 1).
-			Update("balance", gorm.Expr("balance - ?", 50))
-		fmt.Println("外部事务: 从账户1扣除50")
-
-		// 嵌套事务
-		return tx.Transaction(func(tx2 *gorm.DB) error {
-			if err := tx2.Model(&Account{}).Where("id = ?", 2).
-				Update("balance", gorm.Expr("balance + ?", 50)).Error; err != nil {
+		ledger, err := NewGormLedger(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := ledger.Transfer(ctx, "", 1, 2, Yuan(50), "外部事务"); err != nil {
+			return err
+		}
+		fmt.Println("外部事务: 从账户1转账50到账户2")
+
+		// 嵌套事务失败只回滚自己
+		err = tx.Transaction(func(tx2 *gorm.DB) error {
+			ledger, err := NewGormLedger(tx2)
+			if err != nil {
 				return err
 			}
-			fmt.Println("内部事务: 向账户2添加50")
-			return nil
+			_, err = ledger.Transfer(ctx, "", 2, 1, Yuan(5000), "内部事务")
+			return err
 		})
+		fmt.Printf("内部事务失败并回滚: %v\n", err)
+		return nil
 	})
 	printGORMBalances(db)
 
@@ -539,56 +560,40 @@ func demonstrateGORMTransaction() {
 	fmt.Println("5. 避免大事务，保持事务简短")
 }
 
-// printGORMBalances 打印GORM账户余额
+// printGORMBalances 用GORM查询账户，余额由账本从分录明细推导
 func printGORMBalances(db *gorm.DB) {
-	type Account struct {
-		ID      uint
-		Name    string
-		Balance float64
+	var accounts []struct {
+		ID   int64
+		Name string
 	}
+	db.Table("ledger_accounts").Where("allow_negative = ?", false).Order("id").Find(&accounts)
 
-	var accounts []Account
-	db.Find(&accounts)
-
+	ledger, err := NewGormLedger(db)
+	if err != nil {
+		log.Printf("创建账本失败: %v", err)
+		return
+	}
 	fmt.Println("当前GORM账户余额:")
 	for _, acc := range accounts {
-		fmt.Printf("账户 %d (%s): %.2f\n", acc.ID, acc.Name, acc.Balance)
+		balance, err := ledger.Balance(context.Background(), acc.ID)
+		if err != nil {
+			log.Printf("查询余额失败: %v", err)
+			continue
+		}
+		fmt.Printf("账户 %d (%s): %s\n", acc.ID, acc.Name, balance)
 	}
 }
 
 // transferMoneyGORM GORM转账函数
-func transferMoneyGORM(db *gorm.DB, fromID, toID uint, amount float64) {
-	type Account struct {
-		ID      uint
-		Name    string
-		Balance float64
-	}
-
-	// 使用事务闭包
+func transferMoneyGORM(db *gorm.DB, fromID, toID int64, amount Amount) {
+	// 使用事务闭包，账本的余额检查和记账都在同一个事务中
 	err := db.Transaction(func(tx *gorm.DB) error {
-		// 检查余额
-		var fromAccount Account
-		if err := tx.First(&fromAccount, fromID).Error; err != nil {
-			return err
-		}
-
-		if fromAccount.Balance < amount {
-			return fmt.Errorf("余额不足: 当前%.2f, 需要%.2f", fromAccount.Balance, amount)
-		}
-
-		// 扣除发送方余额
-		if err := tx.Model(&Account{}).Where("id = ?", fromID).
-			Update("balance", gorm.Expr("balance - ?", amount)).Error; err != nil {
-			return err
-		}
-
-		// 增加接收方余额
-		if err := tx.Model(&Account{}).Where("id = ?", toID).
-			Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		ledger, err := NewGormLedger(tx)
+		if err != nil {
 			return err
 		}
-
-		return nil
+		_, err = ledger.Transfer(context.Background(), "", fromID, toID, amount, "GORM转账")
+		return err
 	})
 
 	if err != nil {
@@ -596,16 +601,12 @@ func transferMoneyGORM(db *gorm.DB, fromID, toID uint, amount float64) {
 		return
 	}
 
-	fmt.Printf("GORM成功从账户%d转账%.2f到账户%d\n", fromID, amount, toID)
+	fmt.Printf("GORM成功从账户%d转账%s到账户%d\n", fromID, amount, toID)
 }
 
 // manualGORMTransaction GORM手动事务示例
 func manualGORMTransaction(db *gorm.DB) {
-	type Account struct {
-		ID      uint
-		Name    string
-		Balance float64
-	}
+	ctx := context.Background()
 
 	// 开始事务
 	tx := db.Begin()
@@ -623,17 +624,15 @@ func manualGORMTransaction(db *gorm.DB) {
 	}()
 
 	// 执行事务操作
-	if err := tx.Model(&Account{}).Where("id = ?", 1).
-		Update("balance", gorm.Expr("balance - ?", 150)).Error; err != nil {
+	ledger, err := NewGormLedger(tx)
+	if err != nil {
 		tx.Rollback()
-		log.Printf("更新账户1失败: %v", err)
+		log.Printf("创建账本失败: %v", err)
 		return
 	}
-
-	if err := tx.Model(&Account{}).Where("id = ?", 2).
-		Update("balance", gorm.Expr("balance + ?", 150)).Error; err != nil {
+	if _, err := ledger.Transfer(ctx, "manual-1", 1, 2, Yuan(150), "GORM手动事务"); err != nil {
 		tx.Rollback()
-		log.Printf("更新账户2失败: %v", err)
+		log.Printf("转账失败: %v", err)
 		return
 	}
 
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 仓储层的哨兵错误，驱动返回的错误会被映射为这些错误，调用方用 errors.Is 判断
+var (
+	ErrNotFound  = errors.New("记录不存在")
+	ErrDuplicate = errors.New("记录已存在")
+	ErrConflict  = errors.New("与现有数据冲突")
+)
+
+// SQLite 的扩展错误码，见 https://www.sqlite.org/rescode.html
+const (
+	sqliteConstraint           = 19
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// MySQL 的错误号
+const (
+	mysqlDuplicateEntry    = 1062
+	mysqlRowIsReferenced   = 1451
+	mysqlNoReferencedRow   = 1452
+	mysqlCheckConstraint   = 3819
+	mysqlDuplicateEntryKey = 1586
+	mysqlSignalException   = 1644 // 触发器中 SIGNAL 抛出的错误
+)
+
+// MapError 将驱动相关的错误映射为哨兵错误，原始错误信息保留在错误消息中
+// 无法识别的错误原样返回
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	// SQLite 驱动的错误都实现了 Code()，无需依赖具体的驱动包
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqliteConstraintUnique, sqliteConstraintPrimaryKey:
+			return fmt.Errorf("%w: %v", ErrDuplicate, err)
+		case sqliteConstraintForeignKey:
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		if sqliteErr.Code()&0xff == sqliteConstraint {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry, mysqlDuplicateEntryKey:
+			return fmt.Errorf("%w: %v", ErrDuplicate, err)
+		case mysqlRowIsReferenced, mysqlNoReferencedRow, mysqlCheckConstraint, mysqlSignalException:
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+	}
+	return err
+}
+
+// DBTX 是 *sql.DB、*sql.Tx 和 *sql.Conn 的公共方法，仓储可以在事务中使用
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Table 描述实体和表的映射
+type Table[T any] struct {
+	Name     string
+	Key      string   // 自增主键列
+	Columns  []string // 查询的列，顺序与 Scan 一致，也是允许过滤和排序的列
+	Writable []string // 插入和更新的列，顺序与 Values 一致
+	Scan     func(scan func(dest ...interface{}) error, item *T) error
+	Values   func(item *T) []interface{}
+	KeyOf    func(item *T) int64
+}
+
+// Filter 查询条件，列名必须属于表的 Columns，值始终作为参数传递
+type Filter struct {
+	Column string
+	Op     string // =、!=、<、<=、>、>=、LIKE、IN
+	Value  interface{}
+}
+
+// Where 创建查询条件
+func Where(column, op string, value interface{}) Filter {
+	return Filter{Column: column, Op: strings.ToUpper(op), Value: value}
+}
+
+var filterOps = []string{"=", "!=", "<", "<=", ">", ">=", "LIKE", "IN"}
+
+// 分页大小的默认值和上限
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListOptions 列表查询选项
+type ListOptions struct {
+	Filters  []Filter
+	OrderBy  string // 排序列，默认为主键
+	Desc     bool
+	Page     int // 页码，从1开始
+	PageSize int // 每页条数，默认 DefaultPageSize，不超过 MaxPageSize
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	Items    []T
+	Total    int64
+	Page     int
+	PageSize int
+}
+
+// TotalPages 总页数
+func (p Page[T]) TotalPages() int {
+	if p.PageSize == 0 {
+		return 0
+	}
+	return int((p.Total + int64(p.PageSize) - 1) / int64(p.PageSize))
+}
+
+// Repository 基于 database/sql 的通用仓储，所有错误都经过 MapError
+type Repository[T any] struct {
+	db    DBTX
+	table Table[T]
+}
+
+// NewRepository 创建通用仓储
+func NewRepository[T any](db DBTX, table Table[T]) *Repository[T] {
+	return &Repository[T]{db: db, table: table}
+}
+
+// With 返回使用 db 的仓储副本，通常传入事务
+func (r *Repository[T]) With(db DBTX) *Repository[T] {
+	return &Repository[T]{db: db, table: r.table}
+}
+
+func (r *Repository[T]) selectSQL() string {
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(r.table.Columns, ", "), r.table.Name)
+}
+
+// Get 按主键查询，不存在时返回 ErrNotFound
+func (r *Repository[T]) Get(ctx context.Context, id int64) (T, error) {
+	var item T
+	row := r.db.QueryRowContext(ctx, r.selectSQL()+" WHERE "+r.table.Key+" = ?", id)
+	if err := r.table.Scan(row.Scan, &item); err != nil {
+		return item, MapError(err)
+	}
+	return item, nil
+}
+
+// FindOne 返回满足条件的第一条记录，没有时返回 ErrNotFound
+func (r *Repository[T]) FindOne(ctx context.Context, filters ...Filter) (T, error) {
+	var item T
+	where, args, err := r.where(filters)
+	if err != nil {
+		return item, err
+	}
+	row := r.db.QueryRowContext(ctx, r.selectSQL()+where+" LIMIT 1", args...)
+	if err := r.table.Scan(row.Scan, &item); err != nil {
+		return item, MapError(err)
+	}
+	return item, nil
+}
+
+// List 按条件分页查询
+func (r *Repository[T]) List(ctx context.Context, opts ListOptions) (Page[T], error) {
+	page := Page[T]{Page: max(opts.Page, 1), PageSize: opts.PageSize}
+	if page.PageSize <= 0 {
+		page.PageSize = DefaultPageSize
+	}
+	page.PageSize = min(page.PageSize, MaxPageSize)
+
+	orderBy := opts.OrderBy
+	if orderBy == "" {
+		orderBy = r.table.Key
+	}
+	if !slices.Contains(r.table.Columns, orderBy) {
+		return page, fmt.Errorf("不能按 %s 排序", orderBy)
+	}
+	where, args, err := r.where(opts.Filters)
+	if err != nil {
+		return page, err
+	}
+
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+r.table.Name+where, args...).Scan(&page.Total); err != nil {
+		return page, MapError(err)
+	}
+
+	direction := "ASC"
+	if opts.Desc {
+		direction = "DESC"
+	}
+	query := fmt.Sprintf("%s%s ORDER BY %s %s LIMIT ? OFFSET ?", r.selectSQL(), where, orderBy, direction)
+	rows, err := r.db.QueryContext(ctx, query, append(args, page.PageSize, (page.Page-1)*page.PageSize)...)
+	if err != nil {
+		return page, MapError(err)
+	}
+	defer rows.Close()
+
+	page.Items = make([]T, 0, page.PageSize)
+	for rows.Next() {
+		var item T
+		if err := r.table.Scan(rows.Scan, &item); err != nil {
+			return page, MapError(err)
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page, MapError(rows.Err())
+}
+
+// where 生成 WHERE 子句，拒绝不在 Columns 中的列和未知的运算符
+func (r *Repository[T]) where(filters []Filter) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "", nil, nil
+	}
+	conditions := make([]string, 0, len(filters))
+	var args []interface{}
+	for _, f := range filters {
+		if !slices.Contains(r.table.Columns, f.Column) {
+			return "", nil, fmt.Errorf("不能按 %s 过滤", f.Column)
+		}
+		if !slices.Contains(filterOps, f.Op) {
+			return "", nil, fmt.Errorf("不支持的运算符 %q", f.Op)
+		}
+		if f.Op != "IN" {
+			conditions = append(conditions, f.Column+" "+f.Op+" ?")
+			args = append(args, f.Value)
+			continue
+		}
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", nil, fmt.Errorf("IN 的值必须是非空的 []interface{}")
+		}
+		conditions = append(conditions, f.Column+" IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")+")")
+		args = append(args, values...)
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// Create 插入记录并返回数据库中的完整记录(包括默认值)
+func (r *Repository[T]) Create(ctx context.Context, item T) (T, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(r.table.Writable)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.table.Name, strings.Join(r.table.Writable, ", "), placeholders)
+	result, err := r.db.ExecContext(ctx, query, r.table.Values(&item)...)
+	if err != nil {
+		return item, MapError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return item, err
+	}
+	// 读回刚写入的记录，使用 Resolver 时不能读到还没复制的从库
+	return r.Get(UsePrimary(ctx), id)
+}
+
+// Update 按主键更新可写列，不存在时返回 ErrNotFound
+func (r *Repository[T]) Update(ctx context.Context, item T) (T, error) {
+	sets := make([]string, len(r.table.Writable))
+	for i, column := range r.table.Writable {
+		sets[i] = column + " = ?"
+	}
+	id := r.table.KeyOf(&item)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", r.table.Name, strings.Join(sets, ", "), r.table.Key)
+	if _, err := r.db.ExecContext(ctx, query, append(r.table.Values(&item), id)...); err != nil {
+		return item, MapError(err)
+	}
+	// MySQL 在值没有变化时影响行数为0，不能据此判断记录不存在，由 Get 从主库读回时返回 ErrNotFound
+	return r.Get(UsePrimary(ctx), id)
+}
+
+// Delete 按主键删除，不存在时返回 ErrNotFound
+func (r *Repository[T]) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM "+r.table.Name+" WHERE "+r.table.Key+" = ?", id)
+	if err != nil {
+		return MapError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 从库健康检查的默认值
+const (
+	DefaultHealthCheckInterval = 5 * time.Second
+	DefaultHealthCheckTimeout  = time.Second
+)
+
+// ResolverOptions 读写分离选项，零值使用默认值
+type ResolverOptions struct {
+	HealthInterval time.Duration // 健康检查间隔
+	HealthTimeout  time.Duration // 单次 Ping 的超时
+	Logger         *log.Logger
+}
+
+// ReplicaStatus 从库的健康状态和路由到它的读语句数
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+	Reads   int64
+	LastErr error
+}
+
+type replica struct {
+	name  string
+	db    *sql.DB
+	reads atomic.Int64
+
+	mu      sync.Mutex
+	healthy bool
+	lastErr error
+}
+
+// Resolver 读写分离：写语句、事务和加锁读发往主库，其余读语句轮询健康的从库，
+// 没有健康的从库时读主库
+//
+// Resolver 实现了 DBTX，可以直接传给仓储；也实现了 gorm.ConnPool，
+// 作为 sqlite.Dialector 的 Conn 时 GORM 的读写同样分离，GORM 事务在主库上执行。
+type Resolver struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	opts     ResolverOptions
+}
+
+// NewResolver 创建读写分离，从库按顺序命名为 replica-1、replica-2…，初始都视为健康
+func NewResolver(primary *sql.DB, replicas []*sql.DB, opts ResolverOptions) *Resolver {
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultHealthCheckInterval
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = DefaultHealthCheckTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	r := &Resolver{primary: primary, opts: opts}
+	for i, db := range replicas {
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i+1), db: db, healthy: true})
+	}
+	return r
+}
+
+// Primary 返回主库
+func (r *Resolver) Primary() *sql.DB {
+	return r.primary
+}
+
+// GetDBConn 返回主库，GORM 的 db.DB() 和迁移通过它获取 *sql.DB
+func (r *Resolver) GetDBConn() (*sql.DB, error) {
+	return r.primary, nil
+}
+
+// Close 关闭主库和所有从库
+func (r *Resolver) Close() error {
+	errs := []error{r.primary.Close()}
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ExecContext 在主库执行，成功后 ctx 中的会话改为读主库
+func (r *Resolver) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := r.primary.ExecContext(ctx, query, args...)
+	if err == nil {
+		markWritten(ctx)
+	}
+	return result, err
+}
+
+// QueryContext 只读语句发往从库，其余(如 INSERT ... RETURNING)发往主库
+func (r *Resolver) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db, write := r.route(ctx, query)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err == nil && write {
+		markWritten(ctx)
+	}
+	return rows, err
+}
+
+// QueryRowContext 与 QueryContext 的路由相同
+func (r *Resolver) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db, write := r.route(ctx, query)
+	if write {
+		markWritten(ctx)
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// PrepareContext 无法确定预处理语句之后如何使用，总是在主库准备
+func (r *Resolver) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.primary.PrepareContext(ctx, query)
+}
+
+// BeginTx 在主库开始事务，事务中的读也在主库执行
+func (r *Resolver) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := r.primary.BeginTx(ctx, opts)
+	if err == nil {
+		markWritten(ctx)
+	}
+	return tx, err
+}
+
+// route 选择执行语句的库，返回的 write 表示语句会修改数据
+func (r *Resolver) route(ctx context.Context, query string) (db *sql.DB, write bool) {
+	if !isReadOnlyQuery(query) {
+		return r.primary, true
+	}
+	if readsPrimary(ctx) {
+		return r.primary, false
+	}
+	if rep := r.pick(); rep != nil {
+		rep.reads.Add(1)
+		return rep.db, false
+	}
+	return r.primary, false
+}
+
+// pick 从当前位置开始轮询，跳过不健康的从库，都不健康时返回 nil
+func (r *Resolver) pick() *replica {
+	n := uint64(len(r.replicas))
+	if n == 0 {
+		return nil
+	}
+	start := r.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.isHealthy() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// sessionFunctions 结果与连接会话绑定的函数，只有在执行写入的主库连接上才有意义
+var sessionFunctions = map[string]bool{
+	"LAST_INSERT_ID": true, "ROW_COUNT": true, "FOUND_ROWS": true, "CONNECTION_ID": true,
+	"GET_LOCK": true, "RELEASE_LOCK": true, "RELEASE_ALL_LOCKS": true, "IS_FREE_LOCK": true, "IS_USED_LOCK": true,
+	"LAST_INSERT_ROWID": true, "CHANGES": true, "TOTAL_CHANGES": true,
+}
+
+// statementKeywords WITH 之后可能出现的语句
+var statementKeywords = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "VALUES", "TABLE"}
+
+// isReadOnlyQuery 只读的 SELECT 可以发往从库，其余发往主库，包括：
+// WITH 之后不是 SELECT 的语句(如 WITH ... UPDATE)、加锁读、SELECT ... INTO，
+// 以及调用 LAST_INSERT_ID()、GET_LOCK() 等会话函数的语句
+func isReadOnlyQuery(query string) bool {
+	tokens := scanSQL(query)
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[0].word {
+	case "SELECT":
+	case "WITH":
+		// 公用表表达式的定义都在括号内，括号外的第一个语句关键字才是真正执行的语句
+		main := ""
+		for _, t := range tokens[1:] {
+			if t.depth == 0 && slices.Contains(statementKeywords, t.word) {
+				main = t.word
+				break
+			}
+		}
+		if main != "SELECT" {
+			return false
+		}
+	default:
+		return false
+	}
+
+	for i, t := range tokens {
+		if t.call && sessionFunctions[t.word] {
+			return false
+		}
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1].word
+		}
+		switch {
+		case t.word == "INTO", // SELECT ... INTO @变量 或 OUTFILE
+			t.word == "FOR" && (next == "UPDATE" || next == "SHARE"),
+			t.word == "LOCK" && next == "IN":
+			return false
+		}
+	}
+	return true
+}
+
+// sqlToken SQL 语句中的一个单词(已转为大写)，depth 为所在的括号层数，call 表示后面紧跟左括号
+type sqlToken struct {
+	word  string
+	depth int
+	call  bool
+}
+
+// scanSQL 拆分出语句中的单词，跳过注释、字符串和带引号的标识符
+func scanSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 引号内连续两个引号表示引号本身
+			i++
+			for i < len(query) {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '#' || strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isWordByte(c):
+			start := i
+			for i < len(query) && isWordByte(query[i]) {
+				i++
+			}
+			rest := strings.TrimLeft(query[i:], " \t\r\n")
+			tokens = append(tokens, sqlToken{
+				word:  strings.ToUpper(query[start:i]),
+				depth: depth,
+				call:  strings.HasPrefix(rest, "("),
+			})
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// isWordByte 标识符和关键字中的字符，非ASCII字节都当作标识符的一部分
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func (rep *replica) isHealthy() bool {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	return rep.healthy
+}
+
+// Run 每隔 HealthInterval 检查一次从库，直到 ctx 取消
+func (r *Resolver) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.CheckHealth(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckHealth 立即 Ping 所有从库，状态变化时记录日志
+func (r *Resolver) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, r.opts.HealthTimeout)
+			defer cancel()
+			err := rep.db.PingContext(pingCtx)
+
+			rep.mu.Lock()
+			defer rep.mu.Unlock()
+			switch {
+			case err != nil && rep.healthy:
+				r.opts.Logger.Printf("从库 %s 不可用，读请求改发其他库: %v", rep.name, err)
+			case err == nil && !rep.healthy:
+				r.opts.Logger.Printf("从库 %s 已恢复", rep.name)
+			}
+			rep.healthy, rep.lastErr = err == nil, err
+		}()
+	}
+	wg.Wait()
+}
+
+// Replicas 返回所有从库的状态
+func (r *Resolver) Replicas() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, len(r.replicas))
+	for i, rep := range r.replicas {
+		rep.mu.Lock()
+		statuses[i] = ReplicaStatus{Name: rep.name, Healthy: rep.healthy, Reads: rep.reads.Load(), LastErr: rep.lastErr}
+		rep.mu.Unlock()
+	}
+	return statuses
+}
+
+// readSession 记录一个请求是否写过数据
+type readSession struct {
+	written atomic.Bool
+}
+
+type readSessionKey struct{}
+
+type primaryKey struct{}
+
+// WithReadYourWrites 返回带读写会话的 ctx：通过它写入数据后，之后的读都发往主库，
+// 避免从库复制延迟导致读不到刚写入的数据
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readSessionKey{}, &readSession{})
+}
+
+// UsePrimary 返回的 ctx 中所有读都发往主库，用于不能接受复制延迟的读取
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func markWritten(ctx context.Context) {
+	if s, ok := ctx.Value(readSessionKey{}).(*readSession); ok {
+		s.written.Store(true)
+	}
+}
+
+func readsPrimary(ctx context.Context) bool {
+	if ctx.Value(primaryKey{}) != nil {
+		return true
+	}
+	s, ok := ctx.Value(readSessionKey{}).(*readSession)
+	return ok && s.written.Load()
+}
+
+// ReadYourWritesMiddleware 为每个请求开启读写会话，请求中写过数据后读主库
+func ReadYourWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithReadYourWrites(r.Context())))
+	})
+}
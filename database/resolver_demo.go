@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DemonstrateReadWriteSplit 展示读写分离：三个 SQLite 文件分别充当主库和两个从库，
+// 复制由 replicate 手动触发，两次复制之间从库的数据是旧的
+func DemonstrateReadWriteSplit() {
+	fmt.Println("=== 读写分离示例 ===")
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "go-basics-resolver")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := []string{"primary.db", "replica1.db", "replica2.db"}
+	dbs := make([]*sql.DB, len(paths))
+	for i, name := range paths {
+		paths[i] = filepath.Join(dir, name)
+		if dbs[i], err = sql.Open("sqlite", paths[i]); err != nil {
+			log.Fatalf("无法打开数据库: %v", err)
+		}
+		// 表结构在每个库上迁移，相当于已经复制到从库
+		migrator, err := NewMigrator(dbs[i], "sqlite")
+		if err != nil {
+			log.Fatalf("创建迁移器失败: %v", err)
+		}
+		if _, err := migrator.Up(ctx, 0); err != nil {
+			log.Fatalf("迁移 %s 失败: %v", name, err)
+		}
+	}
+	resolver := NewResolver(dbs[0], dbs[1:], ResolverOptions{})
+	defer resolver.Close()
+	replicate := func() {
+		for _, replica := range dbs[1:] {
+			if err := replicateTables(ctx, replica, paths[0], "users", "gorm_users"); err != nil {
+				log.Fatalf("复制失败: %v", err)
+			}
+		}
+	}
+
+	fmt.Println("\n1. 写主库，读从库")
+	users := NewUserRepository(resolver)
+	created, err := users.Create(ctx, User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		log.Fatalf("创建用户失败: %v", err)
+	}
+	fmt.Printf("主库创建用户: ID=%d, 用户名=%s\n", created.ID, created.Username)
+	if _, err := users.FindByUsername(ctx, "alice"); errors.Is(err, ErrNotFound) {
+		fmt.Println("复制前从库读不到 alice")
+	}
+	replicate()
+	if found, err := users.FindByUsername(ctx, "alice"); err == nil {
+		fmt.Printf("复制后从库读到: ID=%d, 用户名=%s\n", found.ID, found.Username)
+	}
+
+	fmt.Println("\n2. 读自己的写")
+	readYourWritesExample(resolver)
+
+	fmt.Println("\n3. GORM 读写分离")
+	gormReadWriteSplitExample(resolver)
+
+	fmt.Println("\n4. 轮询和健康检查")
+	replicate()
+	readAll := func() {
+		for range 6 {
+			if _, err := users.List(ctx, ListOptions{}); err != nil {
+				log.Fatalf("查询失败: %v", err)
+			}
+		}
+		printReplicas(resolver)
+	}
+	readAll()
+	// 模拟从库宕机：关闭后 Ping 失败，健康检查把它摘除
+	dbs[2].Close()
+	resolver.CheckHealth(ctx)
+	readAll()
+	// 从库全部不可用时读主库
+	dbs[1].Close()
+	resolver.CheckHealth(ctx)
+	if page, err := users.List(ctx, ListOptions{}); err == nil {
+		fmt.Printf("没有可用的从库，主库返回 %d 个用户\n", page.Total)
+	}
+}
+
+// readYourWritesExample 请求中写入后立即读取，中间件开启的会话保证读主库
+func readYourWritesExample(resolver *Resolver) {
+	handler := ReadYourWritesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := NewUserRepository(resolver)
+		ctx := r.Context()
+
+		// 读写会话开始时还没有写入，读从库
+		before, err := users.List(ctx, ListOptions{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = WithTx(ctx, resolver, nil, func(tx *Tx) error {
+			_, err := users.WithTx(tx).Create(ctx, User{Username: "bob", Email: "bob@example.com"})
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// 事务之后同一请求的读都发往主库
+		after, err := users.FindByUsername(ctx, "bob")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "写入前 %d 个用户，写入后读到 ID=%d, 用户名=%s", before.Total, after.ID, after.Username)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users", nil))
+	fmt.Printf("响应: %s\n", recorder.Body)
+
+	// 会话之外仍然读从库，还没复制就读不到
+	if _, err := NewUserRepository(resolver).FindByUsername(context.Background(), "bob"); errors.Is(err, ErrNotFound) {
+		fmt.Println("请求之外从库仍然读不到 bob")
+	}
+}
+
+// gormReadWriteSplitExample Resolver 作为 GORM 的连接池，插入、更新和事务走主库，查询走从库
+func gormReadWriteSplitExample(resolver *Resolver) {
+	db, err := gorm.Open(sqlite.Dialector{Conn: resolver}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatalf("无法连接到数据库: %v", err)
+	}
+
+	user := GormUser{Username: "gorm_carol", Email: "carol@example.com", Age: 28}
+	if err := db.Create(&user).Error; err != nil {
+		log.Fatalf("创建用户失败: %v", err)
+	}
+	fmt.Printf("主库创建用户: ID=%d, 用户名=%s\n", user.ID, user.Username)
+
+	var found GormUser
+	if err := db.Where("username = ?", "gorm_carol").First(&found).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Println("复制前从库查不到 gorm_carol")
+	}
+	ctx := WithReadYourWrites(context.Background())
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&GormUser{}).Where("id = ?", user.ID).Update("age", 29).Error
+	})
+	if err != nil {
+		log.Fatalf("更新用户失败: %v", err)
+	}
+	if err := db.WithContext(ctx).Where("username = ?", "gorm_carol").First(&found).Error; err == nil {
+		fmt.Printf("会话中读主库: ID=%d, 用户名=%s, 年龄=%d\n", found.ID, found.Username, found.Age)
+	}
+
+	// 不能接受复制延迟的读取显式读主库
+	var onReplica, onPrimary int64
+	db.Model(&GormUser{}).Count(&onReplica)
+	db.WithContext(UsePrimary(context.Background())).Model(&GormUser{}).Count(&onPrimary)
+	fmt.Printf("用户数: 从库 %d，主库 %d\n", onReplica, onPrimary)
+}
+
+// replicateTables 用主库的数据覆盖从库中的表，模拟一次复制
+func replicateTables(ctx context.Context, replica *sql.DB, primaryPath string, tables ...string) error {
+	// ATTACH 只对当前连接有效，整个复制在一个连接上完成
+	conn, err := replica.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS primary_db", primaryPath); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "DETACH DATABASE primary_db")
+
+	return WithTx(ctx, conn, nil, func(tx *Tx) error {
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" SELECT * FROM primary_db."+table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// printReplicas 打印每个从库的健康状态和累计读语句数
+func printReplicas(resolver *Resolver) {
+	for _, status := range resolver.Replicas() {
+		state := "健康"
+		if !status.Healthy {
+			state = fmt.Sprintf("不可用(%v)", status.LastErr)
+		}
+		fmt.Printf("%s: %s, 累计读 %d 次\n", status.Name, state, status.Reads)
+	}
+}
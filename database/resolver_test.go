@@ -0,0 +1,41 @@
+package database
+
+import "testing"
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+	}{
+		{"SELECT * FROM users WHERE id = ?", true},
+		{"  select username, updated_at from users", true},
+		{"/* 报表 */ SELECT COUNT(*) FROM users", true},
+		{"WITH recent AS (SELECT * FROM users ORDER BY id DESC LIMIT 10) SELECT * FROM recent", true},
+		{"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 5) SELECT x FROM n", true},
+		{"SELECT REPLACE(username, 'a', 'b') FROM users", true},
+		{"SELECT 'LAST_INSERT_ID()', \"for update\" FROM users", true},
+		{"SELECT * FROM users -- FOR UPDATE\nWHERE id = 1", true},
+
+		{"INSERT INTO users (username) VALUES (?)", false},
+		{"UPDATE users SET email = ? WHERE id = ?", false},
+		{"WITH old AS (SELECT id FROM users WHERE id < 10) DELETE FROM users WHERE id IN (SELECT id FROM old)", false},
+		{"WITH t AS (SELECT 1 AS id) UPDATE users SET email = '' WHERE id IN (SELECT id FROM t)", false},
+		{"WITH t AS (SELECT 'x' AS name) INSERT INTO users (username) SELECT name FROM t", false},
+		{"SELECT * FROM users WHERE id = ? FOR UPDATE", false},
+		{"SELECT * FROM users WHERE id = ? FOR SHARE", false},
+		{"SELECT * FROM users WHERE id = ? LOCK IN SHARE MODE", false},
+		{"SELECT LAST_INSERT_ID()", false},
+		{"select last_insert_rowid()", false},
+		{"SELECT GET_LOCK('job', 10)", false},
+		{"SELECT RELEASE_LOCK ('job')", false},
+		{"SELECT FOUND_ROWS()", false},
+		{"SELECT COUNT(*) INTO @total FROM users", false},
+		{"/* SELECT */ DELETE FROM users", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnlyQuery(tt.query); got != tt.readOnly {
+			t.Errorf("isReadOnlyQuery(%q) = %t，期望 %t", tt.query, got, tt.readOnly)
+		}
+	}
+}
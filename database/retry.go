@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SQLite 的锁冲突错误码，扩展错误码的低8位与之相同
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// MySQL 的锁冲突错误号
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// 事务重试的默认值
+const (
+	DefaultMaxAttempts    = 5
+	DefaultRetryBaseDelay = 10 * time.Millisecond
+	DefaultRetryMaxDelay  = time.Second
+)
+
+// RetryOptions 事务重试选项，零值使用默认值
+type RetryOptions struct {
+	MaxAttempts int           // 最多执行次数，包括第一次
+	BaseDelay   time.Duration // 首次重试前的等待，之后指数增长
+	MaxDelay    time.Duration // 单次等待的上限
+
+	// OnRetry 在每次等待重试前调用，可用于日志和统计
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// IsRetryable 判断错误是否由锁冲突引起，重新执行整个事务可能成功：
+// SQLite 的 SQLITE_BUSY、SQLITE_LOCKED，MySQL 的死锁(1213)和锁等待超时(1205)
+func IsRetryable(err error) bool {
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqliteBusy, sqliteLocked:
+			return true
+		}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDeadlock, mysqlLockWaitTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// WithTxRetry 与 WithTx 相同，但事务因锁冲突失败时按指数退避加随机抖动重新执行 fn，
+// 所以 fn 可能被执行多次，不能有事务之外的副作用
+//
+// 等待前会检查 ctx 的截止时间，来不及再执行一次时直接返回最后的错误。
+// 嵌套调用(db 为 *Tx 或 *sql.Tx)不重试：锁冲突时外层事务已经失效，应由外层重试。
+func WithTxRetry(ctx context.Context, db DBTX, opts *sql.TxOptions, retry RetryOptions, fn func(tx *Tx) error) error {
+	switch db.(type) {
+	case *Tx, *sql.Tx:
+		return WithTx(ctx, db, opts, fn)
+	}
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = DefaultMaxAttempts
+	}
+	if retry.BaseDelay <= 0 {
+		retry.BaseDelay = DefaultRetryBaseDelay
+	}
+	if retry.MaxDelay <= 0 {
+		retry.MaxDelay = DefaultRetryMaxDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := WithTx(ctx, db, opts, fn)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt >= retry.MaxAttempts {
+			return fmt.Errorf("事务执行%d次后仍然失败: %w", attempt, err)
+		}
+
+		// 指数退避，上限 MaxDelay，在后一半区间内随机抖动
+		delay := retry.backoff(attempt)
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return fmt.Errorf("截止时间前来不及重试事务: %w", err)
+		}
+		if retry.OnRetry != nil {
+			retry.OnRetry(attempt, err, delay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("等待重试事务时取消: %w", errors.Join(ctx.Err(), err))
+		}
+	}
+}
+
+// backoff 返回第 attempt 次重试前的等待(不含抖动)：从 BaseDelay 开始每次翻倍，
+// 达到 MaxDelay 后不再增长，重试次数再多也不会溢出
+func (r RetryOptions) backoff(attempt int) time.Duration {
+	delay := r.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if delay > r.MaxDelay/2 {
+			return r.MaxDelay
+		}
+		delay *= 2
+	}
+	return min(delay, r.MaxDelay)
+}
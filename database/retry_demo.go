@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DemonstrateTransactionRetry 展示锁冲突时的事务重试：并发转账后总余额保持不变
+func DemonstrateTransactionRetry() {
+	fmt.Println("=== 事务重试示例 ===")
+	ctx := context.Background()
+
+	// 文件数据库才能让多个连接并发访问同一个库；驱动默认等锁5秒，这里设为0让锁冲突立即返回 SQLITE_BUSY
+	dir, err := os.MkdirTemp("", "go-basics-retry")
+	if err != nil {
+		log.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite", filepath.Join(dir, "retry.db")+"?_pragma=busy_timeout(0)")
+	if err != nil {
+		log.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(8)
+
+	migrator, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		log.Fatalf("创建表失败: %v", err)
+	}
+	ledger, err := NewLedger(db, "sqlite")
+	if err != nil {
+		log.Fatalf("创建账本失败: %v", err)
+	}
+
+	// 5个客户账户各从外部资金入账1000
+	const accounts = 5
+	initial := Yuan(1000)
+	external, err := ledger.CreateAccount(ctx, "外部资金", "CNY", true)
+	if err != nil {
+		log.Fatalf("创建账户失败: %v", err)
+	}
+	ids := make([]int64, accounts)
+	for i := range ids {
+		account, err := ledger.CreateAccount(ctx, fmt.Sprintf("账户%d", i+1), "CNY", false)
+		if err != nil {
+			log.Fatalf("创建账户失败: %v", err)
+		}
+		ids[i] = account.ID
+		if _, err := ledger.Transfer(ctx, "", external.ID, account.ID, initial, "初始入账"); err != nil {
+			log.Fatalf("入账失败: %v", err)
+		}
+	}
+
+	fmt.Println("\n1. 错误分类")
+	// 一个连接持有写锁时，另一个连接写入立即失败
+	holder, err := db.Begin()
+	if err != nil {
+		log.Fatalf("开始事务失败: %v", err)
+	}
+	if _, err := holder.Exec("UPDATE ledger_accounts SET name = name WHERE id = ?", external.ID); err != nil {
+		log.Fatalf("更新失败: %v", err)
+	}
+	_, busyErr := db.Exec("UPDATE ledger_accounts SET name = name WHERE id = ?", ids[0])
+	holder.Rollback()
+	fmt.Printf("   %v -> 可重试: %t\n", busyErr, IsRetryable(busyErr))
+	_, constraintErr := ledger.CreateAccount(ctx, "账户1", "CNY", false)
+	fmt.Printf("   %v -> 可重试: %t\n", constraintErr, IsRetryable(constraintErr))
+
+	fmt.Println("\n2. 16个goroutine并发执行400次随机转账")
+	var retries, failed atomic.Int64
+	ledger.Retry = RetryOptions{
+		MaxAttempts: 20,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			retries.Add(1)
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				from := rand.Intn(accounts)
+				to := ids[(from+1)%accounts]
+				_, err := ledger.Transfer(ctx, "", ids[from], to, Amount(rand.Intn(10000)+1), "并发转账")
+				if err != nil {
+					failed.Add(1)
+					if IsRetryable(err) {
+						log.Printf("重试用尽: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("   耗时 %v，重试 %d 次，失败 %d 次(余额不足或重试用尽)\n",
+		time.Since(start).Round(time.Millisecond), retries.Load(), failed.Load())
+
+	fmt.Println("\n3. 检查总余额守恒并对账")
+	var total Amount
+	balances, err := ledger.Balances(ctx)
+	if err != nil {
+		log.Fatalf("查询余额失败: %v", err)
+	}
+	for _, b := range balances {
+		if !b.AllowNegative {
+			total += b.Balance
+		}
+	}
+	if total != accounts*initial {
+		log.Fatalf("总余额不守恒: 期望 %s，实际 %s", accounts*initial, total)
+	}
+	fmt.Printf("   客户总余额 %s，与初始值一致\n", total)
+	report, err := ledger.Reconcile(ctx)
+	if err != nil {
+		log.Fatalf("对账失败: %v", err)
+	}
+	fmt.Println(report)
+}
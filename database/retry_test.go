@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RetryOptions
+	}{
+		{"默认值", RetryOptions{BaseDelay: DefaultRetryBaseDelay, MaxDelay: DefaultRetryMaxDelay}},
+		{"1纳秒起步", RetryOptions{BaseDelay: time.Nanosecond, MaxDelay: time.Hour}},
+		{"上限接近最大值", RetryOptions{BaseDelay: time.Second, MaxDelay: time.Duration(1<<63 - 1)}},
+	}
+	for _, tt := range tests {
+		prev := time.Duration(0)
+		for attempt := 1; attempt <= 200; attempt++ {
+			delay := tt.opts.backoff(attempt)
+			if delay < prev || delay > tt.opts.MaxDelay || delay < tt.opts.BaseDelay {
+				t.Fatalf("%s: 第%d次重试的等待为 %v，应在 [%v, %v] 之间且不小于上一次的 %v",
+					tt.name, attempt, delay, tt.opts.BaseDelay, tt.opts.MaxDelay, prev)
+			}
+			prev = delay
+		}
+		if prev != tt.opts.MaxDelay {
+			t.Errorf("%s: 多次重试后等待应达到上限 %v，实际 %v", tt.name, tt.opts.MaxDelay, prev)
+		}
+	}
+}
+
+// TestConcurrentTransfersConserveBalance 多个连接并发转账，锁冲突由事务重试处理，客户总余额必须保持不变
+func TestConcurrentTransfersConserveBalance(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// busy_timeout(0) 让锁冲突立即返回 SQLITE_BUSY，全部交给重试处理
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "retry.db")+"?_pragma=busy_timeout(0)")
+	if err != nil {
+		t.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(8)
+
+	migrator, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	ledger, err := NewLedger(db, "sqlite")
+	if err != nil {
+		t.Fatalf("创建账本失败: %v", err)
+	}
+
+	const accounts = 5
+	initial := Yuan(100)
+	external, err := ledger.CreateAccount(ctx, "外部资金", "CNY", true)
+	if err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+	ids := make([]int64, accounts)
+	for i := range ids {
+		account, err := ledger.CreateAccount(ctx, fmt.Sprintf("账户%d", i+1), "CNY", false)
+		if err != nil {
+			t.Fatalf("创建账户失败: %v", err)
+		}
+		ids[i] = account.ID
+		if _, err := ledger.Transfer(ctx, "", external.ID, account.ID, initial, "初始入账"); err != nil {
+			t.Fatalf("入账失败: %v", err)
+		}
+	}
+
+	var retries, succeeded, exhausted atomic.Int64
+	ledger.Retry = RetryOptions{
+		MaxAttempts: 100,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    20 * time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			retries.Add(1)
+		},
+	}
+
+	// 金额可能超过余额，余额不足和竞争激烈时重试用尽是预期内的失败，其他错误都说明记账有问题
+	const workers, transfers = 16, 25
+	errs := make(chan error, workers*transfers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < transfers; i++ {
+				from, to := rand.Intn(accounts), rand.Intn(accounts-1)
+				if to >= from {
+					to++
+				}
+				_, err := ledger.Transfer(ctx, "", ids[from], ids[to], Amount(rand.Intn(int(initial))+1), "并发转账")
+				switch {
+				case err == nil:
+					succeeded.Add(1)
+				case IsRetryable(err):
+					exhausted.Add(1)
+				case !errors.Is(err, ErrInsufficientFunds):
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("转账失败: %v", err)
+	}
+	if succeeded.Load() == 0 {
+		t.Fatal("没有一次转账成功")
+	}
+	t.Logf("成功 %d 次转账，重试 %d 次，重试用尽 %d 次", succeeded.Load(), retries.Load(), exhausted.Load())
+
+	balances, err := ledger.Balances(ctx)
+	if err != nil {
+		t.Fatalf("查询余额失败: %v", err)
+	}
+	var total Amount
+	for _, b := range balances {
+		if b.Balance < 0 && !b.AllowNegative {
+			t.Errorf("账户 %s 余额为负: %s", b.Name, b.Balance)
+		}
+		if !b.AllowNegative {
+			total += b.Balance
+		}
+	}
+	if total != accounts*initial {
+		t.Errorf("客户总余额不守恒: 期望 %s，实际 %s", accounts*initial, total)
+	}
+
+	report, err := ledger.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("对账失败: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("对账不一致:\n%s", report)
+	}
+}
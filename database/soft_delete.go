@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 回收站清理的默认值
+const (
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	DefaultPurgeInterval  = time.Hour
+)
+
+// softDeleteCascade 一个随用户软删除、恢复和彻底删除的关联
+type softDeleteCascade struct {
+	model      interface{}
+	foreignKey string
+}
+
+// gormUserCascades GormUser 的级联规则：
+//   - 软删除用户时同时软删除其未删除的记录，deleted_at 与用户相同
+//   - 恢复用户时只恢复与用户同时删除的记录，之前单独删除的仍在回收站
+//   - 彻底删除用户时删除其所有记录，包括已软删除的
+var gormUserCascades = []softDeleteCascade{
+	{model: &Post{}, foreignKey: "user_id"},
+}
+
+// TrashedUser 回收站中的用户
+type TrashedUser struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Posts     int64     `json:"posts"` // 随用户删除的文章数
+}
+
+// PurgeResult 彻底删除的行数
+type PurgeResult struct {
+	Users int64
+	Posts int64
+}
+
+// GormUserRepository GormUser 的软删除、恢复和彻底删除，关联按 gormUserCascades 级联
+type GormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository 创建 GormUser 仓储
+func NewGormUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{db: db}
+}
+
+// SoftDelete 软删除用户及其关联，用户不存在或已在回收站时返回 ErrNotFound
+func (r *GormUserRepository) SoftDelete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// UpdateColumn 不修改 updated_at，默认的软删除条件排除已删除的行
+		result := tx.Model(&GormUser{}).Where("id = ?", id).UpdateColumn("deleted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		for _, c := range gormUserCascades {
+			if err := tx.Model(c.model).Where(c.foreignKey+" = ?", id).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Restore 从回收站恢复用户和与它同时删除的关联，用户不在回收站时返回 ErrNotFound
+func (r *GormUserRepository) Restore(ctx context.Context, id uint) (GormUser, error) {
+	var user GormUser
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.trashed(tx, id, &user); err != nil {
+			return err
+		}
+		deletedAt := user.DeletedAt.Time
+		for _, c := range gormUserCascades {
+			err := tx.Unscoped().Model(c.model).Where(c.foreignKey+" = ? AND deleted_at = ?", id, deletedAt).
+				UpdateColumn("deleted_at", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Model(&user).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		return nil
+	})
+	return user, err
+}
+
+// Purge 彻底删除回收站中的用户及其所有关联，用户不存在时返回 ErrNotFound，
+// 用户未被软删除时返回 ErrConflict
+func (r *GormUserRepository) Purge(ctx context.Context, id uint) (PurgeResult, error) {
+	var result PurgeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user GormUser
+		if err := r.trashed(tx, id, &user); err != nil {
+			return err
+		}
+		var err error
+		result, err = purgeUsers(tx, []uint{id})
+		return err
+	})
+	return result, err
+}
+
+// PurgeDeletedBefore 彻底删除在 cutoff 之前软删除的用户及其所有关联，
+// 以及在 cutoff 之前单独软删除的关联记录
+func (r *GormUserRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Unscoped().Model(&GormUser{}).Where("deleted_at < ?", cutoff).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		var err error
+		if result, err = purgeUsers(tx, ids); err != nil {
+			return err
+		}
+		for _, c := range gormUserCascades {
+			deleted := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(c.model)
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			result.Posts += deleted.RowsAffected
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Trashed 按删除时间倒序分页列出回收站中的用户
+func (r *GormUserRepository) Trashed(ctx context.Context, page, pageSize int) (Page[TrashedUser], error) {
+	result := Page[TrashedUser]{Page: max(page, 1), PageSize: pageSize}
+	if result.PageSize <= 0 {
+		result.PageSize = DefaultPageSize
+	}
+	result.PageSize = min(result.PageSize, MaxPageSize)
+
+	// Session 让条件可以在计数和查询中重复使用
+	db := r.db.WithContext(ctx).Unscoped().Model(&GormUser{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+	if err := db.Count(&result.Total).Error; err != nil {
+		return result, err
+	}
+	// 只统计与用户同时删除的文章，即恢复时会一起恢复的文章
+	err := db.Select("gorm_users.id, gorm_users.username, gorm_users.email, gorm_users.deleted_at, " +
+		"(SELECT COUNT(*) FROM posts WHERE posts.user_id = gorm_users.id AND posts.deleted_at = gorm_users.deleted_at) AS posts").
+		Order("deleted_at DESC, id DESC").
+		Limit(result.PageSize).Offset((result.Page - 1) * result.PageSize).
+		Scan(&result.Items).Error
+	return result, err
+}
+
+// trashed 读取回收站中的用户，区分不存在(ErrNotFound)和未删除(ErrConflict)
+func (r *GormUserRepository) trashed(tx *gorm.DB, id uint, user *GormUser) error {
+	err := tx.Unscoped().First(user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !user.DeletedAt.Valid {
+		return fmt.Errorf("%w: 用户 %d 不在回收站中", ErrConflict, id)
+	}
+	return nil
+}
+
+// purgeUsers 先删除关联再删除用户，外键约束要求这个顺序
+func purgeUsers(tx *gorm.DB, ids []uint) (PurgeResult, error) {
+	var result PurgeResult
+	if len(ids) == 0 {
+		return result, nil
+	}
+	for _, c := range gormUserCascades {
+		deleted := tx.Unscoped().Where(c.foreignKey+" IN ?", ids).Delete(c.model)
+		if deleted.Error != nil {
+			return result, deleted.Error
+		}
+		result.Posts += deleted.RowsAffected
+	}
+	deleted := tx.Unscoped().Delete(&GormUser{}, ids)
+	result.Users = deleted.RowsAffected
+	return result, deleted.Error
+}
+
+// PurgeJobOptions 回收站定期清理选项，零值使用默认值
+type PurgeJobOptions struct {
+	Retention time.Duration // 软删除后保留的时间
+	Interval  time.Duration // 清理间隔
+	Logger    *log.Logger
+}
+
+// PurgeJob 定期彻底删除超过保留时间的软删除记录
+type PurgeJob struct {
+	users *GormUserRepository
+	opts  PurgeJobOptions
+}
+
+// NewPurgeJob 创建回收站清理任务，调用 Run 开始定期清理
+func NewPurgeJob(users *GormUserRepository, opts PurgeJobOptions) *PurgeJob {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultTrashRetention
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPurgeInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	return &PurgeJob{users: users, opts: opts}
+}
+
+// Run 启动时清理一次，之后每隔 Interval 清理一次，直到 ctx 取消
+func (j *PurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			j.opts.Logger.Printf("清理回收站失败: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce 立即清理一次，有记录被删除时记录日志
+func (j *PurgeJob) RunOnce(ctx context.Context) (PurgeResult, error) {
+	result, err := j.users.PurgeDeletedBefore(ctx, time.Now().Add(-j.opts.Retention))
+	if err == nil && (result.Users > 0 || result.Posts > 0) {
+		j.opts.Logger.Printf("清理回收站: 彻底删除 %d 个用户、%d 篇文章", result.Users, result.Posts)
+	}
+	return result, err
+}
@@ -1,9 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
 	_ "github.com/glebarez/sqlite"     // 使用纯Go实现的SQLite驱动
@@ -22,8 +26,9 @@ type User struct {
 func DemonstrateSQL() {
 	fmt.Println("=== SQL数据库操作示例 ===")
 
-	// 使用SQLite内存数据库进行演示
-	db, err := sql.Open("sqlite", ":memory:")
+	// 使用SQLite内存数据库进行演示，每条语句都经过计时，超过100ms记录慢查询日志
+	instrumentation := NewInstrumentation(100 * time.Millisecond)
+	db, err := OpenInstrumented("sqlite", ":memory:", instrumentation)
 	if err != nil {
 		log.Fatalf("无法打开数据库: %v", err)
 	}
@@ -72,48 +77,47 @@ func DemonstrateSQL() {
 	// 预处理语句示例
 	preparedStatementExample(db)
 	fmt.Println("\n9. 预处理语句示例完成")
+
+	// 仓储的错误映射和分页
+	fmt.Println("\n10. 仓储错误映射和分页")
+	repositoryErrorExample(db)
+
+	// 键集分页和逐批导出
+	fmt.Println("\n11. 键集分页和流式导出")
+	keysetExample(db)
+
+	// 跟踪一个HTTP请求执行的语句，以及总耗时最多的语句
+	fmt.Println("\n12. 语句跟踪和耗时统计")
+	queryTraceExample(db)
+	printQueryStats(&instrumentation.Stats, 5)
 }
 
-// createTable 创建用户表
+// createTable 执行迁移创建用户表，表结构见 migrations/sqlite
 func createTable(db *sql.DB) {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL,
-		email TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := db.Exec(query)
+	migrator, err := NewMigrator(db, "sqlite")
 	if err != nil {
+		log.Fatalf("创建迁移器失败: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
 		log.Fatalf("创建表失败: %v", err)
 	}
 }
 
 // insertUsers 插入示例用户数据
 func insertUsers(db *sql.DB) {
-	// 插入多个用户
-	users := []struct {
-		username string
-		email    string
-	}{
-		{"user1", "user1@example.com"},
-		{"user2", "user2@example.com"},
-		{"user3", "user3@example.com"},
+	// 用一条多行 INSERT 插入多个用户
+	users := []User{
+		{Username: "user1", Email: "user1@example.com"},
+		{Username: "user2", Email: "user2@example.com"},
+		{Username: "user3", Email: "user3@example.com"},
 	}
-
-	for _, user := range users {
-		result, err := db.Exec(
-			"INSERT INTO users (username, email) VALUES (?, ?)",
-			user.username, user.email,
-		)
-		if err != nil {
-			log.Printf("插入用户失败: %v", err)
-			continue
-		}
-
-		id, _ := result.LastInsertId()
-		fmt.Printf("插入用户 ID: %d, 用户名: %s\n", id, user.username)
+	result, err := NewUserRepository(db).CreateMany(context.Background(), users, BulkOptions{})
+	if err != nil {
+		log.Printf("插入用户失败: %v", err)
+		return
+	}
+	for i, id := range result.IDs {
+		fmt.Printf("插入用户 ID: %d, 用户名: %s\n", id, users[i].Username)
 	}
 }
 
@@ -121,14 +125,9 @@ func insertUsers(db *sql.DB) {
 func queryUser(db *sql.DB, id int) {
 	fmt.Printf("\n4. 查询用户 ID: %d\n", id)
 
-	// 查询单行
-	var user User
-	err := db.QueryRow(
-		"SELECT id, username, email, created_at FROM users WHERE id = ?", id,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
-
+	user, err := NewUserRepository(db).Get(context.Background(), int64(id))
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, ErrNotFound) {
 			fmt.Printf("未找到ID为%d的用户\n", id)
 		} else {
 			log.Printf("查询失败: %v", err)
@@ -144,102 +143,147 @@ func queryUser(db *sql.DB, id int) {
 func queryAllUsers(db *sql.DB) {
 	fmt.Println("\n5. 查询所有用户")
 
-	// 查询多行
-	rows, err := db.Query("SELECT id, username, email, created_at FROM users")
+	// 逐行读取并打印，不把整个结果集放进内存
+	count := 0
+	for user, err := range NewUserRepository(db).Stream(context.Background()) {
+		if err != nil {
+			log.Printf("查询失败: %v", err)
+			return
+		}
+		fmt.Printf("- ID=%d, 用户名=%s, 邮箱=%s\n",
+			user.ID, user.Username, user.Email)
+		count++
+	}
+	fmt.Printf("找到 %d 个用户\n", count)
+}
+
+// keysetExample 按创建时间倒序翻页，时间相同的用户由主键区分，游标不受新插入的数据影响
+func keysetExample(db *sql.DB) {
+	ctx := context.Background()
+	users := NewUserRepository(db)
+
+	// 同一秒插入的1000个用户，created_at 全部相同
+	bulk := make([]User, 1000)
+	for i := range bulk {
+		name := fmt.Sprintf("bulk%04d", i+1)
+		bulk[i] = User{Username: name, Email: name + "@example.com"}
+	}
+	if _, err := users.CreateMany(ctx, bulk, BulkOptions{}); err != nil {
+		log.Fatalf("批量插入失败: %v", err)
+	}
+
+	opts := SeekOptions{OrderBy: "created_at", Desc: true, Limit: 3}
+	first, err := users.Seek(ctx, opts)
 	if err != nil {
-		log.Printf("查询失败: %v", err)
-		return
+		log.Fatalf("查询失败: %v", err)
 	}
-	defer rows.Close() // 重要：关闭rows以释放连接
+	printSeekPage("第一页", first)
 
-	var users []User
-	for rows.Next() {
-		var user User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
-		if err != nil {
-			log.Printf("扫描行失败: %v", err)
-			continue
-		}
-		users = append(users, user)
+	// 翻页前插入的新用户排在最前面，不会让第二页重复第一页的记录
+	if _, err := users.Create(ctx, User{Username: "latecomer", Email: "late@example.com"}); err != nil {
+		log.Fatalf("创建用户失败: %v", err)
+	}
+	opts.After = first.Next
+	second, err := users.Seek(ctx, opts)
+	if err != nil {
+		log.Fatalf("查询失败: %v", err)
 	}
+	printSeekPage("第二页", second)
 
-	// 检查迭代过程中是否有错误
-	if err := rows.Err(); err != nil {
-		log.Printf("行迭代错误: %v", err)
+	// 游标与排序不一致时拒绝
+	if _, err := users.Seek(ctx, SeekOptions{After: first.Next}); errors.Is(err, ErrInvalidCursor) {
+		fmt.Printf("按主键排序时使用该游标: %v\n", err)
 	}
 
-	// 打印结果
-	fmt.Printf("找到 %d 个用户:\n", len(users))
-	for _, user := range users {
-		fmt.Printf("- ID=%d, 用户名=%s, 邮箱=%s\n",
-			user.ID, user.Username, user.Email)
+	// 每批100条导出所有 bulk 用户，内存中最多只有一批
+	exported, seen := 0, make(map[int]bool)
+	for user, err := range users.Iterate(ctx, SeekOptions{Filters: []Filter{Where("username", "LIKE", "bulk%")}, OrderBy: "created_at", Limit: 100}) {
+		if err != nil {
+			log.Fatalf("导出失败: %v", err)
+		}
+		seen[user.ID] = true
+		exported++
 	}
+	fmt.Printf("逐批导出 %d 个用户，其中不重复的 %d 个\n", exported, len(seen))
+}
+
+// printSeekPage 打印一页结果和下一页的游标
+func printSeekPage(title string, page SeekPage[User]) {
+	fmt.Printf("%s:\n", title)
+	for _, user := range page.Items {
+		fmt.Printf("- ID=%d, 用户名=%s, 创建时间=%s\n", user.ID, user.Username, user.CreatedAt.Format(time.DateTime))
+	}
+	fmt.Printf("下一页游标: %s\n", page.Next)
 }
 
 // updateUser 更新用户信息
 func updateUser(db *sql.DB, id int, newUsername, newEmail string) {
-	result, err := db.Exec(
-		"UPDATE users SET username = ?, email = ? WHERE id = ?",
-		newUsername, newEmail, id,
-	)
+	user, err := NewUserRepository(db).Update(context.Background(), User{ID: id, Username: newUsername, Email: newEmail})
 	if err != nil {
 		log.Printf("更新用户失败: %v", err)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("更新了 %d 行数据\n", rowsAffected)
+	fmt.Printf("更新后的用户: ID=%d, 用户名=%s\n", user.ID, user.Username)
 }
 
 // deleteUser 删除用户
 func deleteUser(db *sql.DB, id int) {
-	result, err := db.Exec("DELETE FROM users WHERE id = ?", id)
-	if err != nil {
+	if err := NewUserRepository(db).Delete(context.Background(), int64(id)); err != nil {
 		log.Printf("删除用户失败: %v", err)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("删除了 %d 行数据\n", rowsAffected)
+	fmt.Printf("删除了用户 ID: %d\n", id)
 }
 
-// transactionExample 事务示例
-func transactionExample(db *sql.DB) {
-	// 开始事务
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("开始事务失败: %v", err)
-		return
-	}
+// repositoryErrorExample 仓储返回的哨兵错误，调用方不需要知道驱动的错误码
+func repositoryErrorExample(db *sql.DB) {
+	ctx := context.Background()
+	users := NewUserRepository(db)
 
-	// 使用defer和recover处理panic
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			log.Printf("事务panic并回滚: %v", r)
-		}
-	}()
+	// username 有唯一索引，重复创建返回 ErrDuplicate
+	if _, err := users.Create(ctx, User{Username: "user3", Email: "dup@example.com"}); errors.Is(err, ErrDuplicate) {
+		fmt.Printf("用户名 user3 已被占用: %v\n", err)
+	} else if err != nil {
+		log.Printf("创建用户失败: %v", err)
+	}
 
-	// 执行多个操作
-	_, err = tx.Exec("INSERT INTO users (username, email) VALUES (?, ?)",
-		"tx_user1", "tx1@example.com")
-	if err != nil {
-		tx.Rollback()
-		log.Printf("事务中插入失败: %v", err)
-		return
+	// 删除不存在的用户返回 ErrNotFound
+	if err := users.Delete(ctx, 9999); errors.Is(err, ErrNotFound) {
+		fmt.Println("用户 9999 不存在，无需删除")
 	}
 
-	_, err = tx.Exec("INSERT INTO users (username, email) VALUES (?, ?)",
-		"tx_user2", "tx2@example.com")
+	// 按条件分页查询
+	page, err := users.List(ctx, ListOptions{
+		Filters:  []Filter{Where("email", "LIKE", "%@example.com")},
+		OrderBy:  "username",
+		Desc:     true,
+		Page:     2,
+		PageSize: 2,
+	})
 	if err != nil {
-		tx.Rollback()
-		log.Printf("事务中插入失败: %v", err)
+		log.Printf("分页查询失败: %v", err)
 		return
 	}
+	fmt.Printf("第 %d/%d 页，共 %d 个用户:\n", page.Page, page.TotalPages(), page.Total)
+	for _, user := range page.Items {
+		fmt.Printf("- ID=%d, 用户名=%s\n", user.ID, user.Username)
+	}
+}
 
-	// 提交事务
-	if err := tx.Commit(); err != nil {
-		log.Printf("提交事务失败: %v", err)
+// transactionExample 事务示例，两个用户要么都插入，要么都不插入
+func transactionExample(db *sql.DB) {
+	ctx := context.Background()
+	err := WithTx(ctx, db, nil, func(tx *Tx) error {
+		users := NewUserRepository(tx)
+		for _, name := range []string{"tx_user1", "tx_user2"} {
+			if _, err := users.Create(ctx, User{Username: name, Email: name + "@example.com"}); err != nil {
+				return fmt.Errorf("事务中插入失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("事务已回滚: %v", err)
 		return
 	}
 
@@ -276,3 +320,23 @@ func preparedStatementExample(db *sql.DB) {
 
 	fmt.Println("使用预处理语句插入了3个用户")
 }
+
+// queryTraceExample 中间件为请求附加 QueryTrace，处理函数把 r.Context() 传给数据库调用
+func queryTraceExample(db *sql.DB) {
+	handler := QueryTraceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := NewUserRepository(db)
+		page, err := users.List(r.Context(), ListOptions{Filters: []Filter{Where("username", "LIKE", "prep%")}})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, span := range QueryTraceFrom(r.Context()).Spans() {
+			fmt.Println(span)
+		}
+		fmt.Fprintf(w, "%d 个用户", page.Total)
+	}), nil)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users?q=prep", nil))
+	fmt.Printf("响应: %s\n", recorder.Body)
+}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor 游标无法解析，或者与查询的排序不一致
+var ErrInvalidCursor = errors.New("无效的游标")
+
+// ScanRows 逐行扫描 rows，迭代结束或提前退出时关闭 rows。
+// scan 与 Table.Scan 的签名相同；出错时产出一次错误后结束
+func ScanRows[T any](rows *sql.Rows, scan func(scan func(dest ...interface{}) error, item *T) error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var item T
+			if err := scan(rows.Scan, &item); err != nil {
+				yield(item, MapError(err))
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			var zero T
+			yield(zero, MapError(err))
+		}
+	}
+}
+
+// Stream 用一条查询按主键顺序流式返回满足条件的记录，迭代期间占用一个连接
+func (r *Repository[T]) Stream(ctx context.Context, filters ...Filter) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		where, args, err := r.where(filters)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		rows, err := r.db.QueryContext(ctx, r.selectSQL()+where+" ORDER BY "+r.table.Key, args...)
+		if err != nil {
+			var zero T
+			yield(zero, MapError(err))
+			return
+		}
+		for item, err := range ScanRows(rows, r.table.Scan) {
+			if !yield(item, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SeekOptions 键集分页选项：按 OrderBy 和主键排序，从 After 游标之后开始取
+type SeekOptions struct {
+	Filters []Filter
+	OrderBy string // 排序列，默认为主键；不能包含 NULL
+	Desc    bool
+	After   string // 上一页返回的 Next，空表示第一页
+	Limit   int    // 每页条数，默认 DefaultPageSize，不超过 MaxPageSize
+}
+
+// SeekPage 键集分页结果
+type SeekPage[T any] struct {
+	Items []T
+	Next  string // 下一页的游标，最后一页为空
+}
+
+// seekCursor 游标内容：排序列和方向用于校验游标属于同一种查询，
+// Value 是排序列在数据库中的文本形式，与列比较时由数据库转换类型
+type seekCursor struct {
+	OrderBy string `json:"o"`
+	Desc    bool   `json:"d,omitempty"`
+	Value   string `json:"v,omitempty"`
+	Key     int64  `json:"k"`
+}
+
+func (c seekCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (seekCursor, error) {
+	var c seekCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Seek 键集分页：用 WHERE 定位到游标之后而不是 OFFSET，任何一页的代价都与第一页相同，
+// 翻页期间插入或删除记录也不会重复或遗漏。主键总是作为最后的排序列，保证顺序稳定
+func (r *Repository[T]) Seek(ctx context.Context, opts SeekOptions) (SeekPage[T], error) {
+	var page SeekPage[T]
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	orderBy := opts.OrderBy
+	if orderBy == "" {
+		orderBy = r.table.Key
+	}
+	if !slices.Contains(r.table.Columns, orderBy) {
+		return page, fmt.Errorf("不能按 %s 排序", orderBy)
+	}
+	where, args, err := r.where(opts.Filters)
+	if err != nil {
+		return page, err
+	}
+
+	byKey := orderBy == r.table.Key
+	op, direction := ">", "ASC"
+	if opts.Desc {
+		op, direction = "<", "DESC"
+	}
+	if opts.After != "" {
+		cursor, err := decodeCursor(opts.After)
+		if err != nil {
+			return page, err
+		}
+		if cursor.OrderBy != orderBy || cursor.Desc != opts.Desc {
+			return page, fmt.Errorf("%w: 游标按 %s 排序", ErrInvalidCursor, cursor.OrderBy)
+		}
+		condition := fmt.Sprintf("%s %s ?", r.table.Key, op)
+		seekArgs := []interface{}{cursor.Key}
+		if !byKey {
+			condition = fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?))", orderBy, op, r.table.Key)
+			seekArgs = []interface{}{cursor.Value, cursor.Value, cursor.Key}
+		}
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+		args = append(args, seekArgs...)
+	}
+
+	// 多取一条判断是否还有下一页；按其他列排序时再取出排序列的文本形式放进游标
+	columns := strings.Join(r.table.Columns, ", ")
+	orderClause := fmt.Sprintf("%s %s", r.table.Key, direction)
+	if !byKey {
+		columns += fmt.Sprintf(", CAST(%s AS CHAR)", orderBy)
+		orderClause = fmt.Sprintf("%s %s, %s", orderBy, direction, orderClause)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT ?", columns, r.table.Name, where, orderClause)
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit+1)...)
+	if err != nil {
+		return page, MapError(err)
+	}
+	defer rows.Close()
+
+	page.Items = make([]T, 0, limit)
+	var last seekCursor
+	for rows.Next() {
+		if len(page.Items) == limit {
+			page.Next = last.encode()
+			break
+		}
+		var item T
+		var value sql.NullString
+		scan := func(dest ...interface{}) error {
+			if !byKey {
+				dest = append(dest, &value)
+			}
+			return rows.Scan(dest...)
+		}
+		if err := r.table.Scan(scan, &item); err != nil {
+			return page, MapError(err)
+		}
+		if !byKey && !value.Valid {
+			return page, fmt.Errorf("排序列 %s 为 NULL，不能用于键集分页", orderBy)
+		}
+		page.Items = append(page.Items, item)
+		last = seekCursor{OrderBy: orderBy, Desc: opts.Desc, Value: value.String, Key: r.table.KeyOf(&item)}
+	}
+	return page, MapError(rows.Err())
+}
+
+// Iterate 按键集分页逐页读取并逐条返回，每页一条短查询，不会长时间占用连接，
+// 适合导出大量记录；opts.Limit 是每批的条数，opts.After 可以从某个游标继续
+func (r *Repository[T]) Iterate(ctx context.Context, opts SeekOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			page, err := r.Seek(ctx, opts)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			opts.After = page.Next
+		}
+	}
+}
+
+// GormRows 流式返回 GORM 查询的结果，db 上的条件、排序和软删除规则照常生效
+func GormRows[T any](db *gorm.DB) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := db.Model(&zero).Rows()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var item T
+			if err := db.ScanRows(rows, &item); err != nil {
+				yield(item, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// GormBatches 按主键键集分批查询，每批 batchSize 条，逐条返回；
+// db 上的条件照常生效，排序和分页由 GormBatches 控制
+func GormBatches[T any](db *gorm.DB, batchSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&zero); err != nil {
+			yield(zero, err)
+			return
+		}
+		field := stmt.Schema.PrioritizedPrimaryField
+		if field == nil {
+			yield(zero, fmt.Errorf("%s 没有主键，不能分批查询", stmt.Schema.Name))
+			return
+		}
+		if batchSize <= 0 {
+			batchSize = DefaultPageSize
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+
+		var last interface{}
+		for {
+			query := db.Session(&gorm.Session{}).Order(clause.OrderByColumn{Column: column}).Limit(batchSize)
+			if last != nil {
+				query = query.Where(clause.Gt{Column: column, Value: last})
+			}
+			var batch []T
+			if err := query.Find(&batch).Error; err != nil {
+				yield(zero, err)
+				return
+			}
+			for i := range batch {
+				if !yield(batch[i], nil) {
+					return
+				}
+			}
+			if len(batch) < batchSize {
+				return
+			}
+			last, _ = field.ValueOf(db.Statement.Context, reflect.ValueOf(&batch[len(batch)-1]).Elem())
+		}
+	}
+}
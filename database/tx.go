@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Tx 是 WithTx 传给回调的事务，嵌入 *sql.Tx，也可以直接传给仓储的 With
+type Tx struct {
+	*sql.Tx
+	depth int // 0 为最外层事务，嵌套一层加1
+}
+
+// Depth 返回嵌套深度，最外层事务为0
+func (tx *Tx) Depth() int {
+	return tx.depth
+}
+
+// WithTx 在事务中执行 fn：fn 返回 nil 时提交，返回错误时回滚并返回该错误，
+// fn panic 时回滚后继续 panic
+//
+// db 为 *sql.DB、*sql.Conn 或 *Resolver(使用主库)时开始新事务，opts 设置隔离级别和只读，nil 使用驱动默认值。
+// MySQL 会应用这些选项，SQLite 始终是 SERIALIZABLE 且忽略只读。
+//
+// db 为 *Tx 或 *sql.Tx 时嵌套执行：fn 在 SAVEPOINT 中运行，成功时 RELEASE，
+// 失败时 ROLLBACK TO，外层事务可以继续。保存点不能改变隔离级别，嵌套时 opts 必须为 nil。
+func WithTx(ctx context.Context, db DBTX, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	switch db := db.(type) {
+	case *Tx:
+		return db.savepoint(ctx, opts, fn)
+	case *sql.Tx:
+		return (&Tx{Tx: db}).savepoint(ctx, opts, fn)
+	case *sql.DB:
+		// 固定一个连接，提交失败时才能在同一个连接上回滚
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("获取连接失败: %w", err)
+		}
+		defer conn.Close()
+		return withConnTx(ctx, conn, opts, fn)
+	case *sql.Conn:
+		return withConnTx(ctx, db, opts, fn)
+	case *Resolver:
+		// 事务总是在主库执行，之后同一会话的读也发往主库
+		markWritten(ctx)
+		return WithTx(ctx, db.Primary(), opts, fn)
+	default:
+		return fmt.Errorf("%T 不支持事务", db)
+	}
+}
+
+func withConnTx(ctx context.Context, conn *sql.Conn, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	sqlTx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	tx := &Tx{Tx: sqlTx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("回滚事务失败: %w", rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		// SQLite 提交返回 SQLITE_BUSY 时事务仍然打开，database/sql 却认为事务已结束，
+		// 不回滚的话连接回到连接池后会一直持有锁
+		conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// savepoint 在保存点中执行 fn，保存点按嵌套深度命名，同一时刻每层只有一个
+func (tx *Tx) savepoint(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	if opts != nil {
+		return errors.New("嵌套事务不能设置隔离级别或只读")
+	}
+	nested := &Tx{Tx: tx.Tx, depth: tx.depth + 1}
+	name := fmt.Sprintf("sp_%d", nested.depth)
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("创建保存点失败: %w", err)
+	}
+
+	// ROLLBACK TO 之后保存点仍然存在，需要再 RELEASE
+	rollback := func() error {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(nested); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("回滚到保存点失败: %w", rbErr))
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("释放保存点失败: %w", err)
+	}
+	return nil
+}
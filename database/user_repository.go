@@ -0,0 +1,44 @@
+package database
+
+import "context"
+
+// userTable users 表与 User 的映射
+var userTable = Table[User]{
+	Name:     "users",
+	Key:      "id",
+	Columns:  []string{"id", "username", "email", "created_at"},
+	Writable: []string{"username", "email"},
+	Scan: func(scan func(dest ...interface{}) error, u *User) error {
+		// created_at 用 scanTime 读取，MySQL 未设置 parseTime=true 时驱动返回的是字符串
+		var createdAt scanTime
+		if err := scan(&u.ID, &u.Username, &u.Email, &createdAt); err != nil {
+			return err
+		}
+		u.CreatedAt = createdAt.Time
+		return nil
+	},
+	Values: func(u *User) []interface{} {
+		return []interface{}{u.Username, u.Email}
+	},
+	KeyOf: func(u *User) int64 { return int64(u.ID) },
+}
+
+// UserRepository 用户仓储
+type UserRepository struct {
+	*Repository[User]
+}
+
+// NewUserRepository 创建用户仓储，db 可以是 *sql.DB、*sql.Tx 或 *sql.Conn
+func NewUserRepository(db DBTX) *UserRepository {
+	return &UserRepository{NewRepository(db, userTable)}
+}
+
+// WithTx 返回在 tx 中执行的用户仓储
+func (r *UserRepository) WithTx(tx DBTX) *UserRepository {
+	return &UserRepository{r.With(tx)}
+}
+
+// FindByUsername 按用户名查询
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (User, error) {
+	return r.FindOne(ctx, Where("username", "=", username))
+}
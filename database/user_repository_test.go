@@ -0,0 +1,26 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// TestUserTableScanTextTime 直接查询文本字面量，模拟未设置 parseTime=true 的 MySQL 驱动以字符串返回 created_at
+func TestUserTableScanTextTime(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("无法打开数据库: %v", err)
+	}
+	defer db.Close()
+
+	row := db.QueryRow("SELECT 7, 'gopher', 'gopher@example.com', '2024-05-06 07:08:09'")
+	var u User
+	if err := userTable.Scan(row.Scan, &u); err != nil {
+		t.Fatalf("读取用户失败: %v", err)
+	}
+	want := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	if u.ID != 7 || u.Username != "gopher" || !u.CreatedAt.Equal(want) {
+		t.Errorf("期望 ID=7、用户名 gopher、创建时间 %v，实际 %+v", want, u)
+	}
+}
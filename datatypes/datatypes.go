@@ -176,7 +176,7 @@ func demonstrateTypeConversion() {
 
 	// 字符串与数字转换
 	var i2 int = 65
-	var s string = string(i2) // 将ASCII码转为字符
+	var s string = string(rune(i2)) // 将ASCII码转为字符
 
 	fmt.Println("\n字符串与数字:")
 	fmt.Printf("  int -> string: %d -> %s\n", i2, s)
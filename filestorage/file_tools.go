@@ -0,0 +1,117 @@
+package filestorage
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SplitFile 将文件按 chunkSize 切分到 outputDir，分片命名为 <文件名>.001、<文件名>.002 ...
+// 返回按顺序排列的分片路径
+func SplitFile(filePath, outputDir string, chunkSize int64) ([]string, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("分片大小必须大于0")
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	base := filepath.Base(filePath)
+	for i := 1; ; i++ {
+		partPath := filepath.Join(outputDir, fmt.Sprintf("%s.%03d", base, i))
+		part, err := os.Create(partPath)
+		if err != nil {
+			return parts, err
+		}
+		n, err := io.CopyN(part, file, chunkSize)
+		if closeErr := part.Close(); err == nil {
+			err = closeErr
+		}
+		if n == 0 {
+			// 文件恰好是分片大小的整数倍时会多创建一个空分片，空文件除外
+			if len(parts) > 0 {
+				os.Remove(partPath)
+			} else {
+				parts = append(parts, partPath)
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			return parts, err
+		}
+		parts = append(parts, partPath)
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+	}
+}
+
+// MergeFiles 按顺序合并分片到 dst，返回写入的字节数
+func MergeFiles(dst string, parts []string) (int64, error) {
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, partPath := range parts {
+		part, err := os.Open(partPath)
+		if err != nil {
+			out.Close()
+			return total, err
+		}
+		n, err := io.Copy(out, part)
+		part.Close()
+		total += n
+		if err != nil {
+			out.Close()
+			return total, fmt.Errorf("合并 %s 失败: %w", partPath, err)
+		}
+	}
+	return total, out.Close()
+}
+
+// HashAlgorithms 支持的哈希算法
+var HashAlgorithms = []string{"md5", "sha1", "sha256"}
+
+// HashFile 计算文件的哈希值(十六进制)，algorithm 为 md5、sha1 或 sha256
+func HashFile(filePath, algorithm string) (string, error) {
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("不支持的哈希算法 %q", algorithm)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
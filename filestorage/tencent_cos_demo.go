@@ -10,7 +10,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"go-basics/config"
+
 	"github.com/tencentyun/cos-go-sdk-v5"
 )
 
@@ -82,21 +83,30 @@ func DemonstrateTencentCOS() {
 	}
 }
 
+// 加载COS配置，来源包括配置文件、.env 和环境变量
+func loadCOSConfig() (config.COSConfig, error) {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return config.COSConfig{}, err
+	}
+
+	// 检查必要的配置
+	cosCfg := cfg.COS
+	if cosCfg.BucketURL == "" || cosCfg.SecretID == "" || cosCfg.SecretKey == "" {
+		return cosCfg, fmt.Errorf("请设置COS_BUCKET_URL, COS_SECRET_ID和COS_SECRET_KEY环境变量")
+	}
+	return cosCfg, nil
+}
+
 // 创建COS客户端
 func createCOSClient() (*cos.Client, error) {
-	godotenv.Load() // 加载.env文件
-	// 从环境变量获取COS配置
-	bucketURL := os.Getenv("COS_BUCKET_URL")
-	secretID := os.Getenv("COS_SECRET_ID")
-	secretKey := os.Getenv("COS_SECRET_KEY")
-
-	// 检查必要的环境变量
-	if bucketURL == "" || secretID == "" || secretKey == "" {
-		return nil, fmt.Errorf("请设置COS_BUCKET_URL, COS_SECRET_ID和COS_SECRET_KEY环境变量")
+	cosCfg, err := loadCOSConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	// 解析存储桶URL
-	u, err := url.Parse(bucketURL)
+	u, err := url.Parse(cosCfg.BucketURL)
 	if err != nil {
 		return nil, fmt.Errorf("解析存储桶URL失败: %v", err)
 	}
@@ -107,8 +117,8 @@ func createCOSClient() (*cos.Client, error) {
 	// 创建COS客户端
 	client := cos.NewClient(b, &http.Client{
 		Transport: &cos.AuthorizationTransport{
-			SecretID:  secretID,
-			SecretKey: secretKey,
+			SecretID:  cosCfg.SecretID,
+			SecretKey: cosCfg.SecretKey,
 		},
 	})
 
@@ -252,9 +262,14 @@ func listObjects(client *cos.Client, prefix string) error {
 func generatePresignedURL(client *cos.Client, objectKey string) error {
 	ctx := context.Background()
 
+	cosCfg, err := loadCOSConfig()
+	if err != nil {
+		return err
+	}
+
 	// 设置URL过期时间为1小时
 	presignedURL, err := client.Object.GetPresignedURL(ctx, http.MethodGet, objectKey,
-		os.Getenv("COS_SECRET_ID"), os.Getenv("COS_SECRET_KEY"), time.Hour, nil)
+		cosCfg.SecretID, cosCfg.SecretKey, time.Hour, nil)
 	if err != nil {
 		return fmt.Errorf("生成预签名URL失败: %v", err)
 	}
@@ -0,0 +1,80 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// GetOptions GET请求选项，零值使用 DefaultTimeout、DefaultRetryBackoff 和 DefaultMaxBackoff，不重试
+type GetOptions struct {
+	Timeout    time.Duration // 单次请求超时
+	Retries    int           // 失败后的重试次数
+	Backoff    time.Duration // 首次重试的等待时间，之后指数增长
+	MaxBackoff time.Duration // 单次等待的上限(不含抖动)
+	Header     http.Header   // 额外的请求头
+}
+
+// Get 发送GET请求，网络错误、429和5xx响应按指数退避重试
+// 返回的响应由调用方关闭；重试用尽时返回最后一次的响应或错误
+func Get(ctx context.Context, url string, opts GetOptions) (*http.Response, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultRetryBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range opts.Header {
+			req.Header[name] = values
+		}
+
+		resp, err := client.Do(req)
+		if attempt >= opts.Retries || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		// 指数退避加随机抖动
+		delay := opts.backoff(attempt) + time.Duration(rand.Int63n(int64(opts.Backoff)))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待重试时取消: %w", ctx.Err())
+		}
+	}
+}
+
+// backoff 返回第 attempt 次重试(从0开始)前的等待(不含抖动)：从 Backoff 开始每次翻倍，
+// 达到 MaxBackoff 后不再增长，重试次数再多也不会溢出
+func (o GetOptions) backoff(attempt int) time.Duration {
+	delay := o.Backoff
+	for i := 0; i < attempt; i++ {
+		if delay > o.MaxBackoff/2 {
+			return o.MaxBackoff
+		}
+		delay *= 2
+	}
+	return min(delay, o.MaxBackoff)
+}
+
+// shouldRetry 判断请求是否值得重试
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,31 @@
+package httpclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		opts GetOptions
+	}{
+		{"默认值", GetOptions{Backoff: DefaultRetryBackoff, MaxBackoff: DefaultMaxBackoff}},
+		{"1纳秒起步", GetOptions{Backoff: time.Nanosecond, MaxBackoff: time.Hour}},
+		{"上限接近最大值", GetOptions{Backoff: time.Second, MaxBackoff: time.Duration(1<<63 - 1)}},
+	}
+	for _, tt := range tests {
+		prev := time.Duration(0)
+		for attempt := 0; attempt < 200; attempt++ {
+			delay := tt.opts.backoff(attempt)
+			if delay < prev || delay > tt.opts.MaxBackoff || delay < tt.opts.Backoff {
+				t.Fatalf("%s: 第%d次重试的等待为 %v，应在 [%v, %v] 之间且不小于上一次的 %v",
+					tt.name, attempt+1, delay, tt.opts.Backoff, tt.opts.MaxBackoff, prev)
+			}
+			prev = delay
+		}
+		if prev != tt.opts.MaxBackoff {
+			t.Errorf("%s: 多次重试后等待应达到上限 %v，实际 %v", tt.name, tt.opts.MaxBackoff, prev)
+		}
+	}
+}
@@ -36,4 +36,5 @@ const (
 	DefaultTimeout      = 10 * time.Second
 	DefaultRetryCount   = 3
 	DefaultRetryBackoff = 1 * time.Second
+	DefaultMaxBackoff   = 30 * time.Second
 )
@@ -6,24 +6,28 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
-	"github.com/joho/godotenv"
+	"go-basics/config"
 )
 
 // DemonstrateThirdPartyAPIs 展示调用第三方API
 func DemonstrateThirdPartyAPIs() {
-	// 加载环境变量
-	godotenv.Load()
+	// 加载配置，API密钥来自配置文件、.env 或环境变量
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		return
+	}
+	apis := cfg.APIs
 
 	// 调用天气API
 	// fmt.Println("5.1 调用天气API")
-	// callWeatherAPI()
+	// callWeatherAPI(apis.OpenWeatherKey)
 
 	// // 调用汇率API
 	// fmt.Println("\n5.2 调用汇率API")
-	// callExchangeRateAPI()
+	// callExchangeRateAPI(apis.ExchangeRateKey)
 
 	// 调用GitHub API
 	// fmt.Println("\n5.3 调用GitHub API")
@@ -31,11 +35,11 @@ func DemonstrateThirdPartyAPIs() {
 
 	// // 调用新闻API
 	fmt.Println("\n5.4 调用新闻API")
-	callNewsAPI()
+	callNewsAPI(apis.NewsKey)
 }
 
 // 调用天气API
-func callWeatherAPI() {
+func callWeatherAPI(apiKey string) {
 	// 创建请求URL
 	baseURL := "https://api.openweathermap.org/data/2.5/weather"
 
@@ -46,8 +50,6 @@ func callWeatherAPI() {
 	params.Add("lang", "zh_cn")
 
 	// 添加API密钥
-	apiKey := os.Getenv("OPENWEATHER_API_KEY")
-	fmt.Printf("当前使用的 API Key: %s\n", apiKey)
 	if apiKey == "" {
 		fmt.Println("未设置OPENWEATHER_API_KEY环境变量，使用模拟数据")
 		fmt.Println("模拟数据: 北京，温度25°C，天气晴朗，湿度45%")
@@ -116,7 +118,7 @@ func callWeatherAPI() {
 }
 
 // 调用汇率API
-func callExchangeRateAPI() {
+func callExchangeRateAPI(apiKey string) {
 	// 创建请求URL
 	baseURL := "https://api.exchangerate.host/live"
 
@@ -124,7 +126,7 @@ func callExchangeRateAPI() {
 	params := url.Values{}
 	params.Add("source", "CNY")
 	params.Add("currencies", "USD,EUR,JPY,GBP")
-	params.Add("access_key", os.Getenv("EXCHANGERATE_API_KEY"))
+	params.Add("access_key", apiKey)
 
 	// 构建完整URL
 	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
@@ -248,7 +250,7 @@ func callGitHubAPI() {
 }
 
 // 调用新闻API
-func callNewsAPI() {
+func callNewsAPI(apiKey string) {
 	// 创建请求URL
 	baseURL := "https://newsapi.org/v2/top-headlines"
 
@@ -258,7 +260,6 @@ func callNewsAPI() {
 	params.Add("category", "technology")
 
 	// 添加API密钥
-	apiKey := os.Getenv("NEWS_API_KEY")
 	if apiKey == "" {
 		fmt.Println("未设置NEWS_API_KEY环境变量，使用模拟数据")
 		fmt.Println("模拟数据:")
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支持的压缩编码，按服务端偏好排序
+// br 需要引入 brotli 编码库，目前未支持，客户端只接受 br 时按原样返回
+const (
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+var supportedEncodings = []string{EncodingZstd, EncodingGzip}
+
+// CompressionOptions 压缩中间件选项
+type CompressionOptions struct {
+	MinSize      int      // 小于该字节数的响应不压缩，默认1024
+	ContentTypes []string // 允许压缩的媒体类型，以 / 结尾表示前缀匹配
+}
+
+// DefaultCompressionContentTypes 默认压缩的媒体类型
+// 事件流(text/event-stream)不在其中，压缩缓冲会增加推送延迟
+var DefaultCompressionContentTypes = []string{
+	"application/json",
+	"application/xml",
+	"text/xml",
+	"text/html",
+	"text/plain",
+	"text/csv",
+	"application/x-ndjson",
+	"application/graphql-response+json",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// allows 判断媒体类型是否允许压缩，+json、+xml 结尾的厂商类型视同JSON、XML
+func (o *CompressionOptions) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range o.ContentTypes {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed) || mediaType == allowed {
+			return true
+		}
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// 压缩器复用，避免每个请求都分配窗口内存
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}
+	zstdWriters = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// CompressionMiddleware 按 Accept-Encoding 使用 zstd 或 gzip 压缩响应
+// 响应先缓冲到 MinSize，足够大且媒体类型在允许列表中时才压缩
+func CompressionMiddleware(opts CompressionOptions) gin.HandlerFunc {
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	if opts.ContentTypes == nil {
+		opts.ContentTypes = DefaultCompressionContentTypes
+	}
+
+	return func(c *gin.Context) {
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		// WebSocket 等协议升级的连接不能压缩
+		if encoding == "" || c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, opts: &opts, encoding: encoding, status: http.StatusOK}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 解析 Accept-Encoding 的q值，返回最合适的编码，不压缩时返回空字符串
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		for _, encoding := range supportedEncodings {
+			if (name == encoding || name == "*") && (q > bestQ || q == bestQ && preferEncoding(encoding, best)) {
+				best, bestQ = encoding, q
+			}
+		}
+	}
+	return best
+}
+
+// preferEncoding q值相同时按服务端偏好选择
+func preferEncoding(a, b string) bool {
+	for _, encoding := range supportedEncodings {
+		if encoding == a {
+			return true
+		}
+		if encoding == b {
+			return false
+		}
+	}
+	return false
+}
+
+// compressWriter 缓冲响应直到可以决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	opts     *CompressionOptions
+	encoding string
+	status   int
+	written  bool   // 处理函数是否已写出响应(可能还在缓冲中)
+	decided  bool   // 是否已决定压缩方式并写出响应头
+	buffer   []byte // 决定之前的响应体
+	encoder  io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *compressWriter) Status() int {
+	if w.decided {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *compressWriter) Written() bool {
+	return w.written || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Size() int {
+	if !w.decided {
+		if !w.written {
+			return -1
+		}
+		return len(w.buffer)
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.written = true
+	if !w.decided {
+		w.buffer = append(w.buffer, data...)
+		if len(w.buffer) < w.opts.MinSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 流式响应无法预知大小，首次刷新时只按媒体类型决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(len(w.buffer) > 0); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide 写出响应头并决定是否压缩，然后写出缓冲的数据
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	allowed := w.opts.allows(header.Get("Content-Type"))
+	if allowed {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	compress = compress && allowed && w.status >= http.StatusOK &&
+		w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" &&
+		!strings.Contains(header.Get("Cache-Control"), "no-transform")
+
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		switch w.encoding {
+		case EncodingZstd:
+			encoder := zstdWriters.Get().(*zstd.Encoder)
+			encoder.Reset(w.ResponseWriter)
+			w.encoder = encoder
+		default:
+			encoder := gzipWriters.Get().(*gzip.Writer)
+			encoder.Reset(w.ResponseWriter)
+			w.encoder = encoder
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buffer := w.buffer
+	w.buffer = nil
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buffer)
+		return err
+	}
+	if len(buffer) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buffer)
+	return err
+}
+
+// finish 请求结束时写出剩余数据，未达到 MinSize 的响应原样返回
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if !w.written {
+			// 处理函数没有写出任何内容，只设置了状态码
+			if w.status != http.StatusOK {
+				w.ResponseWriter.WriteHeader(w.status)
+			}
+			return
+		}
+		w.decide(false)
+	}
+	if w.encoder == nil {
+		return
+	}
+
+	w.encoder.Close()
+	switch encoder := w.encoder.(type) {
+	case *zstd.Encoder:
+		encoder.Reset(io.Discard)
+		zstdWriters.Put(encoder)
+	case *gzip.Writer:
+		encoder.Reset(io.Discard)
+		gzipWriters.Put(encoder)
+	}
+	w.encoder = nil
+}
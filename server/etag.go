@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productETag 生成产品的强ETag，版本号变化即ETag变化
+func productETag(product Product) string {
+	return fmt.Sprintf(`"%d-%d"`, product.ID, product.Version)
+}
+
+// etagMatches 判断 If-Match / If-None-Match 列表中是否包含指定ETag
+// 强比较：弱ETag(W/前缀)永远不匹配
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch 校验写操作的 If-Match 前置条件，在解析请求体之前尽早拒绝过期的请求
+// 缺少请求头返回428，ETag不匹配返回412，校验失败时已中止请求。
+// 校验之后产品仍可能被其他请求修改，写入时还要用 ifMatchPrecondition 在存储的锁内再次校验
+func checkIfMatch(c *gin.Context, product Product) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{
+			"error": "缺少 If-Match 请求头，请先获取产品的 ETag",
+		})
+		return false
+	}
+	if !etagMatches(ifMatch, productETag(product)) {
+		abortPreconditionFailed(c, product)
+		return false
+	}
+	return true
+}
+
+// ifMatchPrecondition 返回在存储的锁内校验 If-Match 的函数，ETag不匹配时返回 ErrVersionConflict
+func ifMatchPrecondition(c *gin.Context) func(current Product) error {
+	ifMatch := c.GetHeader("If-Match")
+	return func(current Product) error {
+		if !etagMatches(ifMatch, productETag(current)) {
+			return ErrVersionConflict
+		}
+		return nil
+	}
+}
+
+// abortPreconditionFailed 响应412，并返回产品当前的ETag
+func abortPreconditionFailed(c *gin.Context, current Product) {
+	c.Header("ETag", productETag(current))
+	c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{
+		"error": "产品已被修改，请重新获取后再提交",
+	})
+}
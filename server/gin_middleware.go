@@ -1,16 +1,30 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"go-basics/config"
+
 	"github.com/gin-gonic/gin"
 )
 
 // DemonstrateGin 展示Gin框架的中间件使用
 func DemonstrateGin() {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if err := RunGin(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunGin 启动Gin中间件示例服务器，直到 ctx 取消
+func RunGin(ctx context.Context, cfg *config.Config) error {
 	// 创建 Gin 引擎实例
 	r := gin.Default()
 
@@ -47,15 +61,19 @@ func DemonstrateGin() {
 	})
 
 	// 启动服务器
-	fmt.Println("Gin服务器启动在 http://localhost:8080")
+	opts, err := ServerOptionsFromConfig(cfg.Server)
+	if err != nil {
+		return fmt.Errorf("读取服务器配置失败: %w", err)
+	}
+	fmt.Println("Gin服务器启动在 " + opts.URL())
 	fmt.Println("可以尝试访问以下URL：")
-	fmt.Println("1. http://localhost:8080/ (首页)")
-	fmt.Println("2. http://localhost:8080/test (测试延迟中间件)")
-	fmt.Println("3. http://localhost:8080/auth/profile?token=valid (需要认证)")
-	fmt.Println("4. http://localhost:8080/auth/profile (无token将被拒绝)")
+	fmt.Println("1. " + opts.URL() + "/ (首页)")
+	fmt.Println("2. " + opts.URL() + "/test (测试延迟中间件)")
+	fmt.Println("3. " + opts.URL() + "/auth/profile?token=valid (需要认证)")
+	fmt.Println("4. " + opts.URL() + "/auth/profile (无token将被拒绝)")
 	fmt.Println("按 Ctrl+C 停止服务器")
 
-	r.Run(":8080")
+	return Serve(ctx, opts, r)
 }
 
 // LoggerMiddleware 日志中间件
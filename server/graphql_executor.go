@@ -0,0 +1,538 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// 单个操作展开片段后的嵌套深度和字段总数上限，防止恶意查询耗尽资源
+const (
+	gqlMaxDepth  = 10
+	gqlMaxFields = 500
+)
+
+// gqlResolver 字段解析函数，可以直接返回值，也可以返回 gqlThunk 延迟求值
+type gqlResolver func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// gqlThunk 延迟求值的结果，同一层的所有 thunk 注册完毕后才会被求值，DataLoader 借此合并查询
+type gqlThunk func() (interface{}, error)
+
+// gqlObjectType 对象类型
+type gqlObjectType struct {
+	name   string
+	fields map[string]*gqlFieldDef
+}
+
+// gqlFieldDef 字段定义，typ 为空表示标量或标量列表
+type gqlFieldDef struct {
+	typ     *gqlObjectType
+	resolve gqlResolver
+}
+
+// gqlSchema 根类型
+type gqlSchema struct {
+	query    *gqlObjectType
+	mutation *gqlObjectType
+}
+
+// gqlError 返回给客户端的错误
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// gqlResult 执行结果
+type gqlResult struct {
+	Data   interface{} `json:"data"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+// gqlContext 单次请求的执行上下文
+type gqlContext struct {
+	context.Context
+	variables map[string]interface{}
+	fragments map[string]*gqlFragment
+	loaders   map[string]*gqlLoader
+	errors    []gqlError
+}
+
+// loader 获取请求内共享的 DataLoader，同名 loader 只创建一次
+func (ctx *gqlContext) loader(name string, fetch func(keys []uint) (map[uint]interface{}, error)) *gqlLoader {
+	if l, ok := ctx.loaders[name]; ok {
+		return l
+	}
+	l := &gqlLoader{fetch: fetch, results: make(map[uint]interface{}), done: make(map[uint]bool)}
+	ctx.loaders[name] = l
+	return l
+}
+
+// gqlLoader DataLoader：收集同一层中所有待加载的键，第一次求值时批量查询
+type gqlLoader struct {
+	fetch   func(keys []uint) (map[uint]interface{}, error)
+	pending []uint
+	results map[uint]interface{}
+	done    map[uint]bool
+	errs    map[uint]error
+}
+
+// Load 注册键并返回延迟结果
+func (l *gqlLoader) Load(key uint) gqlThunk {
+	if !l.done[key] {
+		l.pending = append(l.pending, key)
+	}
+	return func() (interface{}, error) {
+		l.dispatch()
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.results[key], nil
+	}
+}
+
+// dispatch 批量加载所有待处理的键
+func (l *gqlLoader) dispatch() {
+	var keys []uint
+	for _, key := range l.pending {
+		if !l.done[key] {
+			l.done[key] = true
+			keys = append(keys, key)
+		}
+	}
+	l.pending = nil
+	if len(keys) == 0 {
+		return
+	}
+
+	results, err := l.fetch(keys)
+	for _, key := range keys {
+		if err != nil {
+			if l.errs == nil {
+				l.errs = make(map[uint]error)
+			}
+			l.errs[key] = err
+			continue
+		}
+		l.results[key] = results[key]
+	}
+}
+
+// gqlOrderedMap 保持字段顺序的对象，序列化时按查询中的顺序输出
+type gqlOrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newGQLOrderedMap() *gqlOrderedMap {
+	return &gqlOrderedMap{values: make(map[string]interface{})}
+}
+
+func (m *gqlOrderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (m *gqlOrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlPending 等待解析字段的对象
+type gqlPending struct {
+	objType    *gqlObjectType
+	source     interface{}
+	selections []gqlSelection
+	out        *gqlOrderedMap
+	path       []interface{}
+}
+
+// gqlSlot 一个已调用解析函数、等待补全的字段
+type gqlSlot struct {
+	out        *gqlOrderedMap
+	key        string
+	def        *gqlFieldDef
+	selections []gqlSelection
+	path       []interface{}
+	value      interface{}
+	err        error
+}
+
+// executeGraphQL 执行文档中的一个操作
+func executeGraphQL(ctx context.Context, schema *gqlSchema, doc *gqlDocument, operationName string, variables map[string]interface{}) *gqlResult {
+	op, err := selectOperation(doc, operationName)
+	if err != nil {
+		return &gqlResult{Errors: []gqlError{{Message: err.Error()}}}
+	}
+
+	root := schema.query
+	if op.kind == "mutation" {
+		root = schema.mutation
+	}
+	if root == nil {
+		return &gqlResult{Errors: []gqlError{{Message: "不支持的操作类型: " + op.kind}}}
+	}
+
+	if err := checkQueryLimits(op.selections, doc.fragments, 1, make(map[string]bool), new(int)); err != nil {
+		return &gqlResult{Errors: []gqlError{{Message: err.Error()}}}
+	}
+
+	vars, err := coerceVariables(op.variables, variables)
+	if err != nil {
+		return &gqlResult{Errors: []gqlError{{Message: err.Error()}}}
+	}
+
+	gctx := &gqlContext{
+		Context:   ctx,
+		variables: vars,
+		fragments: doc.fragments,
+		loaders:   make(map[string]*gqlLoader),
+	}
+	data := newGQLOrderedMap()
+
+	// 逐层执行：先调用本层所有字段的解析函数，再统一求值 thunk，然后展开下一层
+	level := []*gqlPending{{objType: root, selections: op.selections, out: data}}
+	for len(level) > 0 {
+		var slots []*gqlSlot
+		for _, pending := range level {
+			slots = append(slots, gctx.resolveFields(pending)...)
+		}
+
+		var next []*gqlPending
+		for _, slot := range slots {
+			if thunk, ok := slot.value.(gqlThunk); ok && slot.err == nil {
+				slot.value, slot.err = thunk()
+			}
+			if slot.err != nil {
+				gctx.addError(slot.err, slot.path)
+				continue
+			}
+			value, err := gctx.complete(slot.value, slot.def.typ, slot.selections, slot.path, &next)
+			if err != nil {
+				gctx.addError(err, slot.path)
+				continue
+			}
+			slot.out.set(slot.key, value)
+		}
+		level = next
+	}
+
+	return &gqlResult{Data: data, Errors: gctx.errors}
+}
+
+// selectOperation 按名称选择要执行的操作
+func selectOperation(doc *gqlDocument, name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("文档包含多个操作，必须指定 operationName")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("操作 %q 不存在", name)
+}
+
+// checkQueryLimits 在执行前展开片段，检查字段的嵌套深度和总数
+// 不考虑 @skip/@include，按最坏情况计算
+func checkQueryLimits(selections []gqlSelection, fragments map[string]*gqlFragment, depth int, visiting map[string]bool, fields *int) error {
+	if depth > gqlMaxDepth {
+		return fmt.Errorf("查询嵌套超过 %d 层", gqlMaxDepth)
+	}
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *gqlField:
+			if *fields++; *fields > gqlMaxFields {
+				return fmt.Errorf("查询的字段超过 %d 个", gqlMaxFields)
+			}
+			if len(s.selections) > 0 {
+				if err := checkQueryLimits(s.selections, fragments, depth+1, visiting, fields); err != nil {
+					return err
+				}
+			}
+		case *gqlFragmentSpread:
+			fragment, ok := fragments[s.name]
+			if !ok || visiting[s.name] {
+				continue
+			}
+			visiting[s.name] = true
+			err := checkQueryLimits(fragment.selections, fragments, depth, visiting, fields)
+			delete(visiting, s.name)
+			if err != nil {
+				return err
+			}
+		case *gqlInlineFragment:
+			if err := checkQueryLimits(s.selections, fragments, depth, visiting, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// coerceVariables 应用变量默认值并检查必填变量
+func coerceVariables(defs []gqlVariableDef, provided map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, def := range defs {
+		value, ok := provided[def.name]
+		switch {
+		case ok:
+			vars[def.name] = value
+		case def.hasDefault:
+			vars[def.name] = resolveGQLValue(def.defaultValue, nil)
+		case def.nonNull:
+			return nil, fmt.Errorf("缺少必填变量 $%s", def.name)
+		}
+		if def.nonNull && ok && value == nil {
+			return nil, fmt.Errorf("变量 $%s 不能为 null", def.name)
+		}
+	}
+	return vars, nil
+}
+
+// resolveGQLValue 将参数字面量中的变量替换为实际值
+func resolveGQLValue(value interface{}, vars map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case gqlVariable:
+		return vars[string(v)]
+	case gqlEnum:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = resolveGQLValue(item, vars)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[key] = resolveGQLValue(item, vars)
+		}
+		return object
+	default:
+		return v
+	}
+}
+
+func (ctx *gqlContext) addError(err error, path []interface{}) {
+	ctx.errors = append(ctx.errors, gqlError{Message: err.Error(), Path: path})
+}
+
+// resolveFields 调用对象上所有被选择字段的解析函数
+func (ctx *gqlContext) resolveFields(pending *gqlPending) []*gqlSlot {
+	keys, fields := ctx.collectFields(pending.objType, pending.selections, nil, nil, make(map[string]bool))
+
+	var slots []*gqlSlot
+	for _, key := range keys {
+		field := fields[key][0]
+		path := appendPath(pending.path, key)
+
+		if field.name == "__typename" {
+			pending.out.set(key, pending.objType.name)
+			continue
+		}
+
+		// 先占位以保持字段顺序，出错时保留 null
+		pending.out.set(key, nil)
+
+		def, ok := pending.objType.fields[field.name]
+		if !ok {
+			ctx.addError(fmt.Errorf("类型 %s 上不存在字段 %q", pending.objType.name, field.name), path)
+			continue
+		}
+
+		var selections []gqlSelection
+		for _, f := range fields[key] {
+			selections = append(selections, f.selections...)
+		}
+
+		args := make(map[string]interface{}, len(field.args))
+		for name, value := range field.args {
+			args[name] = resolveGQLValue(value, ctx.variables)
+		}
+
+		value, err := def.resolve(ctx, pending.source, args)
+		slots = append(slots, &gqlSlot{
+			out:        pending.out,
+			key:        key,
+			def:        def,
+			selections: selections,
+			path:       path,
+			value:      value,
+			err:        err,
+		})
+	}
+	return slots
+}
+
+// collectFields 展开片段并按返回键名合并字段
+func (ctx *gqlContext) collectFields(objType *gqlObjectType, selections []gqlSelection, keys []string, fields map[string][]*gqlField, visited map[string]bool) ([]string, map[string][]*gqlField) {
+	if fields == nil {
+		fields = make(map[string][]*gqlField)
+	}
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *gqlField:
+			if !ctx.shouldInclude(s.directives) {
+				continue
+			}
+			key := s.responseKey()
+			if _, ok := fields[key]; !ok {
+				keys = append(keys, key)
+			}
+			fields[key] = append(fields[key], s)
+		case *gqlFragmentSpread:
+			fragment, ok := ctx.fragments[s.name]
+			if !ok || visited[s.name] || !ctx.shouldInclude(s.directives) || fragment.typeCondition != objType.name {
+				continue
+			}
+			visited[s.name] = true
+			keys, fields = ctx.collectFields(objType, fragment.selections, keys, fields, visited)
+		case *gqlInlineFragment:
+			if !ctx.shouldInclude(s.directives) || (s.typeCondition != "" && s.typeCondition != objType.name) {
+				continue
+			}
+			keys, fields = ctx.collectFields(objType, s.selections, keys, fields, visited)
+		}
+	}
+	return keys, fields
+}
+
+// shouldInclude 处理 @skip 和 @include 指令
+func (ctx *gqlContext) shouldInclude(directives []gqlDirective) bool {
+	for _, d := range directives {
+		condition, _ := resolveGQLValue(d.args["if"], ctx.variables).(bool)
+		if d.name == "skip" && condition {
+			return false
+		}
+		if d.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// complete 补全字段值：标量直接返回，对象加入下一层待解析队列，列表逐项补全
+func (ctx *gqlContext) complete(value interface{}, typ *gqlObjectType, selections []gqlSelection, path []interface{}, next *[]*gqlPending) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	if value == nil || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Slice) && rv.IsNil()) {
+		return nil, nil
+	}
+
+	if typ == nil {
+		if len(selections) > 0 {
+			return nil, fmt.Errorf("标量字段不能包含子字段选择")
+		}
+		return value, nil
+	}
+
+	if rv.Kind() == reflect.Slice {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			item, err := ctx.complete(rv.Index(i).Interface(), typ, selections, appendPath(path, i), next)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+	}
+
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("类型 %s 的字段必须指定子字段", typ.name)
+	}
+	out := newGQLOrderedMap()
+	*next = append(*next, &gqlPending{
+		objType:    typ,
+		source:     value,
+		selections: selections,
+		out:        out,
+		path:       path,
+	})
+	return out, nil
+}
+
+// appendPath 复制路径并追加一段，避免共享底层数组
+func appendPath(path []interface{}, segment interface{}) []interface{} {
+	newPath := make([]interface{}, len(path), len(path)+1)
+	copy(newPath, path)
+	return append(newPath, segment)
+}
+
+// gqlArgInt 读取整数参数，JSON变量中的数字为 float64
+func gqlArgInt(args map[string]interface{}, name string, defaultValue int) (int, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return defaultValue, nil
+	case int:
+		return v, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("参数 %s 必须是整数", name)
+}
+
+// gqlArgString 读取字符串参数
+func gqlArgString(args map[string]interface{}, name string) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("参数 %s 必须是字符串", name)
+}
+
+// gqlArgID 读取ID参数，接受字符串或整数
+func gqlArgID(args map[string]interface{}, name string) (uint, error) {
+	switch v := args[name].(type) {
+	case string:
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err == nil {
+			return uint(id), nil
+		}
+	case int:
+		if v >= 0 {
+			return uint(v), nil
+		}
+	case float64:
+		if v >= 0 && v == float64(uint(v)) {
+			return uint(v), nil
+		}
+	}
+	return 0, fmt.Errorf("参数 %s 必须是有效的ID", name)
+}
+
+// gqlDecodeInput 将输入对象参数解码到结构体
+func gqlDecodeInput(value interface{}, dst interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go-basics/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// graphQLRequest GraphQL请求体，extensions 中可携带持久化查询的哈希
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			Sha256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// maxPersistedQueries 客户端自动注册的持久化查询最多保留的条数，超出时淘汰最久未使用的
+const maxPersistedQueries = 1000
+
+// GraphQL 请求的大小上限，GET 请求的查询在URL中，同样受 gqlMaxQueryBytes 限制
+const (
+	gqlMaxBodyBytes  = 1 << 20
+	gqlMaxQueryBytes = 64 << 10
+)
+
+// GraphQLHandler /graphql 端点
+// 支持 Automatic Persisted Queries：客户端先只发送查询的sha256哈希，未命中时再发送完整查询
+type GraphQLHandler struct {
+	schema    *gqlSchema
+	persisted *persistedQueries
+}
+
+// NewGraphQLHandler 创建GraphQL处理器，db 用于读取用户和文章
+func NewGraphQLHandler(db *gorm.DB) *GraphQLHandler {
+	return &GraphQLHandler{schema: newGraphQLSchema(db), persisted: newPersistedQueries(maxPersistedQueries)}
+}
+
+// RegisterPersistedQuery 预注册持久化查询，返回客户端使用的哈希
+// 预注册的查询不会被淘汰
+func (h *GraphQLHandler) RegisterPersistedQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	h.persisted.register(hash, query)
+	return hash
+}
+
+// persistedQueries 持久化查询缓存：预注册的查询常驻，客户端注册的查询按LRU淘汰
+type persistedQueries struct {
+	mutex      sync.Mutex
+	registered map[string]string
+	capacity   int
+	order      *list.List // 最近使用的在前，元素为 persistedQuery
+	entries    map[string]*list.Element
+}
+
+type persistedQuery struct {
+	hash  string
+	query string
+}
+
+func newPersistedQueries(capacity int) *persistedQueries {
+	return &persistedQueries{
+		registered: make(map[string]string),
+		capacity:   capacity,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (p *persistedQueries) register(hash, query string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.registered[hash] = query
+}
+
+func (p *persistedQueries) load(hash string) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if query, ok := p.registered[hash]; ok {
+		return query, true
+	}
+	element, ok := p.entries[hash]
+	if !ok {
+		return "", false
+	}
+	p.order.MoveToFront(element)
+	return element.Value.(persistedQuery).query, true
+}
+
+func (p *persistedQueries) store(hash, query string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.registered[hash]; ok {
+		return
+	}
+	if element, ok := p.entries[hash]; ok {
+		p.order.MoveToFront(element)
+		return
+	}
+	p.entries[hash] = p.order.PushFront(persistedQuery{hash: hash, query: query})
+	if p.order.Len() > p.capacity {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(persistedQuery).hash)
+	}
+}
+
+// Handle 处理GET和POST请求，GET只允许执行查询操作
+func (h *GraphQLHandler) Handle(c *gin.Context) {
+	var req graphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		for name, target := range map[string]interface{}{
+			"variables":  &req.Variables,
+			"extensions": &req.Extensions,
+		} {
+			if raw := c.Query(name); raw != "" {
+				if err := json.Unmarshal([]byte(raw), target); err != nil {
+					c.JSON(http.StatusBadRequest, gqlResult{Errors: []gqlError{{Message: name + " 不是有效的JSON"}}})
+					return
+				}
+			}
+		}
+	} else {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, gqlMaxBodyBytes)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gqlResult{Errors: []gqlError{{Message: fmt.Sprintf("请求体超过 %d 字节", gqlMaxBodyBytes)}}})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gqlResult{Errors: []gqlError{{Message: "请求体不是有效的JSON"}}})
+			return
+		}
+	}
+	if len(req.Query) > gqlMaxQueryBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gqlResult{Errors: []gqlError{{Message: fmt.Sprintf("查询超过 %d 字节", gqlMaxQueryBytes)}}})
+		return
+	}
+
+	query, errMessage := h.resolveQuery(&req)
+	if errMessage != "" {
+		c.JSON(http.StatusOK, gqlResult{Errors: []gqlError{{Message: errMessage}}})
+		return
+	}
+
+	doc, err := parseGraphQL(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gqlResult{Errors: []gqlError{{Message: err.Error()}}})
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		if op, err := selectOperation(doc, req.OperationName); err == nil && op.kind != "query" {
+			c.JSON(http.StatusMethodNotAllowed, gqlResult{Errors: []gqlError{{Message: "GET请求只能执行查询操作"}}})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, executeGraphQL(c.Request.Context(), h.schema, doc, req.OperationName, req.Variables))
+}
+
+// resolveQuery 处理持久化查询，返回要执行的查询文本或错误信息
+func (h *GraphQLHandler) resolveQuery(req *graphQLRequest) (string, string) {
+	persisted := req.Extensions.PersistedQuery
+	if persisted == nil {
+		if req.Query == "" {
+			return "", "缺少 query"
+		}
+		return req.Query, ""
+	}
+
+	if req.Query == "" {
+		query, ok := h.persisted.load(persisted.Sha256Hash)
+		if !ok {
+			// 与Apollo客户端约定的错误信息，客户端收到后会重发完整查询
+			return "", "PersistedQueryNotFound"
+		}
+		return query, ""
+	}
+
+	sum := sha256.Sum256([]byte(req.Query))
+	if hex.EncodeToString(sum[:]) != persisted.Sha256Hash {
+		return "", "provided sha does not match query"
+	}
+	h.persisted.store(persisted.Sha256Hash, req.Query)
+	return req.Query, ""
+}
+
+// GraphQLSchema 返回Schema定义，方便客户端生成代码
+func GraphQLSchema(c *gin.Context) {
+	c.String(http.StatusOK, GraphQLSchemaSDL)
+}
+
+// openGraphQLDemoDB 打开内存SQLite数据库并写入示例用户和文章
+func openGraphQLDemoDB() (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 内存数据库每个连接都是独立的库，限制为单连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&database.GormUser{}, &database.Post{}, &tenantUser{}); err != nil {
+		return nil, err
+	}
+
+	users := []database.GormUser{
+		{Username: "gopher", Email: "gopher@example.com", Age: 25, Posts: []database.Post{
+			{Title: "Go并发入门", Content: "goroutine与channel"},
+			{Title: "Go错误处理", Content: "errors.Is与errors.As"},
+		}},
+		{Username: "rustacean", Email: "rust@example.com", Age: 30},
+	}
+	if err := db.Create(&users).Error; err != nil {
+		return nil, err
+	}
+	// 示例用户属于 default 租户
+	for _, user := range users {
+		if err := db.Create(&tenantUser{TenantID: "default", UserID: user.ID}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
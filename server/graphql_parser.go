@@ -0,0 +1,674 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 这里实现了 GraphQL 查询语言的一个常用子集：
+// 查询/变更操作、变量、别名、参数、片段、内联片段以及 @include/@skip 指令
+
+// gqlTokenKind 词法单元类型
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+// gqlToken 词法单元
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	pos   int
+}
+
+// gqlLexer 词法分析器
+type gqlLexer struct {
+	src string
+	pos int
+}
+
+// next 读取下一个词法单元，忽略空白、逗号和注释
+func (l *gqlLexer) next() (gqlToken, error) {
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			l.pos++
+		case ch == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case ch == 0xEF && strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return l.readToken()
+		}
+	}
+	return gqlToken{kind: gqlEOF, pos: l.pos}, nil
+}
+
+func (l *gqlLexer) readToken() (gqlToken, error) {
+	start := l.pos
+	ch := l.src[l.pos]
+
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{kind: gqlPunct, value: "...", pos: start}, nil
+	case strings.ContainsRune("!$():=@[]{}|", rune(ch)):
+		l.pos++
+		return gqlToken{kind: gqlPunct, value: string(ch), pos: start}, nil
+	case ch == '_' || isASCIILetter(ch):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isASCIILetter(l.src[l.pos]) || isASCIIDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return gqlToken{kind: gqlName, value: l.src[start:l.pos], pos: start}, nil
+	case ch == '-' || isASCIIDigit(ch):
+		return l.readNumber()
+	case ch == '"':
+		return l.readString()
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return gqlToken{}, fmt.Errorf("位置%d: 无法识别的字符 %q", start, r)
+}
+
+func (l *gqlLexer) readNumber() (gqlToken, error) {
+	start := l.pos
+	kind := gqlInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() {
+		for l.pos < len(l.src) && isASCIIDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = gqlFloat
+		l.pos++
+		digits()
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = gqlFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		digits()
+	}
+	return gqlToken{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *gqlLexer) readString() (gqlToken, error) {
+	start := l.pos
+
+	// 块字符串 """..."""
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return gqlToken{}, fmt.Errorf("位置%d: 块字符串未结束", start)
+		}
+		value := l.src[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return gqlToken{kind: gqlString, value: strings.TrimSpace(value), pos: start}, nil
+	}
+
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch ch {
+		case '"':
+			l.pos++
+			return gqlToken{kind: gqlString, value: sb.String(), pos: start}, nil
+		case '\n':
+			return gqlToken{}, fmt.Errorf("位置%d: 字符串未结束", start)
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return gqlToken{}, fmt.Errorf("位置%d: 字符串未结束", start)
+			}
+			escape := l.src[l.pos+1]
+			l.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				sb.WriteByte(escape)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return gqlToken{}, fmt.Errorf("位置%d: 无效的unicode转义", l.pos)
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return gqlToken{}, fmt.Errorf("位置%d: 无效的unicode转义", l.pos)
+				}
+				sb.WriteRune(rune(code))
+				l.pos += 4
+			default:
+				return gqlToken{}, fmt.Errorf("位置%d: 无效的转义字符 \\%c", l.pos-1, escape)
+			}
+		default:
+			sb.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return gqlToken{}, fmt.Errorf("位置%d: 字符串未结束", start)
+}
+
+func isASCIILetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isASCIIDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// gqlDocument 解析后的文档
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// gqlOperation 查询或变更操作
+type gqlOperation struct {
+	kind       string // query 或 mutation
+	name       string
+	variables  []gqlVariableDef
+	selections []gqlSelection
+}
+
+// gqlVariableDef 变量定义
+type gqlVariableDef struct {
+	name         string
+	nonNull      bool
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+// gqlSelection 选择集中的一项：*gqlField、*gqlFragmentSpread 或 *gqlInlineFragment
+type gqlSelection interface{}
+
+// gqlField 字段选择
+type gqlField struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []gqlDirective
+	selections []gqlSelection
+}
+
+// responseKey 返回结果中使用的键名
+func (f *gqlField) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlFragmentSpread 片段展开 ...Name
+type gqlFragmentSpread struct {
+	name       string
+	directives []gqlDirective
+}
+
+// gqlInlineFragment 内联片段 ... on Type { }
+type gqlInlineFragment struct {
+	typeCondition string
+	directives    []gqlDirective
+	selections    []gqlSelection
+}
+
+// gqlFragment 片段定义
+type gqlFragment struct {
+	name          string
+	typeCondition string
+	selections    []gqlSelection
+}
+
+// gqlDirective 指令
+type gqlDirective struct {
+	name string
+	args map[string]interface{}
+}
+
+// gqlVariable 对变量的引用
+type gqlVariable string
+
+// gqlEnum 枚举值字面量
+type gqlEnum string
+
+// gqlParser 语法分析器
+type gqlParser struct {
+	lexer *gqlLexer
+	token gqlToken
+	// 选择集(内联片段也计入)和列表、对象值各自的嵌套层数，超过 gqlMaxDepth 时停止解析，
+	// 否则深层嵌套的查询在递归下降时会耗尽栈，整个进程崩溃
+	depth      int
+	valueDepth int
+}
+
+// nest 进入一层嵌套，调用方在返回时将 *depth 减1
+func (p *gqlParser) nest(depth *int) error {
+	if *depth++; *depth > gqlMaxDepth {
+		return fmt.Errorf("位置%d: 查询嵌套超过 %d 层", p.token.pos, gqlMaxDepth)
+	}
+	return nil
+}
+
+// parseGraphQL 解析查询文档
+func parseGraphQL(source string) (*gqlDocument, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: source}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.token.kind != gqlEOF {
+		switch {
+		case p.peek(gqlPunct, "{"):
+			selections, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections})
+		case p.peek(gqlName, "query"), p.peek(gqlName, "mutation"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(gqlName, "fragment"):
+			fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[fragment.name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("文档中没有可执行的操作")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *gqlParser) peek(kind gqlTokenKind, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.token.kind == gqlEOF {
+		return fmt.Errorf("位置%d: 意外的文档结尾", p.token.pos)
+	}
+	return fmt.Errorf("位置%d: 意外的 %q", p.token.pos, p.token.value)
+}
+
+// expect 要求当前为指定标点并前进
+func (p *gqlParser) expect(value string) error {
+	if !p.peek(gqlPunct, value) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// skip 当前为指定标点时前进并返回true
+func (p *gqlParser) skip(value string) (bool, error) {
+	if !p.peek(gqlPunct, value) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *gqlParser) parseName() (string, error) {
+	if p.token.kind != gqlName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.advance()
+}
+
+func (p *gqlParser) parseOperation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: p.token.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == gqlName {
+		op.name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(gqlPunct, ")") {
+			def, err := p.parseVariableDef()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *gqlParser) parseVariableDef() (gqlVariableDef, error) {
+	var def gqlVariableDef
+	if err := p.expect("$"); err != nil {
+		return def, err
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return def, err
+	}
+	def.name = name
+	if err := p.expect(":"); err != nil {
+		return def, err
+	}
+	if def.nonNull, err = p.parseType(); err != nil {
+		return def, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return def, err
+	} else if ok {
+		if def.defaultValue, err = p.parseValue(true); err != nil {
+			return def, err
+		}
+		def.hasDefault = true
+	}
+	return def, nil
+}
+
+// parseType 解析类型引用，只返回最外层是否非空
+func (p *gqlParser) parseType() (bool, error) {
+	if ok, err := p.skip("["); err != nil {
+		return false, err
+	} else if ok {
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.parseName(); err != nil {
+		return false, err
+	}
+	return p.skip("!")
+}
+
+func (p *gqlParser) parseFragment() (*gqlFragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek(gqlName, "on") {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &gqlFragment{name: name, typeCondition: typeCondition, selections: selections}, nil
+}
+
+func (p *gqlParser) parseSelectionSet() ([]gqlSelection, error) {
+	err := p.nest(&p.depth)
+	defer func() { p.depth-- }()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for !p.peek(gqlPunct, "}") {
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	return selections, p.advance()
+}
+
+func (p *gqlParser) parseSelection() (gqlSelection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.token.kind == gqlName && p.token.value != "on" {
+			spread := &gqlFragmentSpread{name: p.token.value}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			directives, err := p.parseDirectives()
+			spread.directives = directives
+			return spread, err
+		}
+
+		fragment := &gqlInlineFragment{}
+		if p.peek(gqlName, "on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			fragment.typeCondition = name
+		}
+		directives, err := p.parseDirectives()
+		if err != nil {
+			return nil, err
+		}
+		fragment.directives = directives
+		fragment.selections, err = p.parseSelectionSet()
+		return fragment, err
+	}
+
+	field := &gqlField{}
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.alias = name
+		if name, err = p.parseName(); err != nil {
+			return nil, err
+		}
+	}
+	field.name = name
+
+	if field.args, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	if field.directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peek(gqlPunct, "{") {
+		if field.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *gqlParser) parseArguments() (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	ok, err := p.skip("(")
+	if err != nil || !ok {
+		return args, err
+	}
+	for !p.peek(gqlPunct, ")") {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.advance()
+}
+
+func (p *gqlParser) parseDirectives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+	for p.peek(gqlPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, gqlDirective{name: name, args: args})
+	}
+	return directives, nil
+}
+
+// parseValue 解析值字面量，constant 为 true 时不允许出现变量
+func (p *gqlParser) parseValue(constant bool) (interface{}, error) {
+	token := p.token
+	switch token.kind {
+	case gqlInt:
+		value, err := strconv.Atoi(token.value)
+		if err != nil {
+			return nil, fmt.Errorf("位置%d: 无效的整数 %s", token.pos, token.value)
+		}
+		return value, p.advance()
+	case gqlFloat:
+		value, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置%d: 无效的浮点数 %s", token.pos, token.value)
+		}
+		return value, p.advance()
+	case gqlString:
+		return token.value, p.advance()
+	case gqlName:
+		var value interface{}
+		switch token.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = gqlEnum(token.value)
+		}
+		return value, p.advance()
+	}
+
+	switch {
+	case p.peek(gqlPunct, "$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseName()
+		return gqlVariable(name), err
+	case p.peek(gqlPunct, "["):
+		err := p.nest(&p.valueDepth)
+		defer func() { p.valueDepth-- }()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek(gqlPunct, "]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+	case p.peek(gqlPunct, "{"):
+		err := p.nest(&p.valueDepth)
+		defer func() { p.valueDepth-- }()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{})
+		for !p.peek(gqlPunct, "}") {
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.advance()
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,593 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-basics/database"
+
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// GraphQLSchemaSDL 对外暴露的Schema定义，与 newGraphQLSchema 中的解析函数保持一致
+const GraphQLSchemaSDL = `type Query {
+  product(id: ID!): Product
+  products(first: Int = 10, after: String): ProductConnection!
+  user(id: ID!): User
+  users(first: Int = 10, after: String): UserConnection!
+  post(id: ID!): Post
+}
+
+type Mutation {
+  createProduct(input: ProductInput!): Product!
+  updateProduct(id: ID!, input: ProductInput!, expectedVersion: Int): Product!
+  deleteProduct(id: ID!, expectedVersion: Int): Boolean!
+  createUser(input: UserInput!): User!
+  createPost(input: PostInput!): Post!
+}
+
+type Product {
+  id: ID!
+  name: String!
+  description: String!
+  price: Float!
+  version: Int!
+  createdAt: String!
+  updatedAt: String!
+  owner: User
+}
+
+type User {
+  id: ID!
+  username: String!
+  email: String!
+  age: Int!
+  active: Boolean!
+  createdAt: String!
+  updatedAt: String!
+  posts: [Post!]!
+}
+
+type Post {
+  id: ID!
+  title: String!
+  content: String!
+  createdAt: String!
+  updatedAt: String!
+  author: User
+}
+
+type PageInfo {
+  hasNextPage: Boolean!
+  endCursor: String
+}
+
+type ProductEdge { cursor: String! node: Product! }
+type ProductConnection { edges: [ProductEdge!]! pageInfo: PageInfo! totalCount: Int! }
+type UserEdge { cursor: String! node: User! }
+type UserConnection { edges: [UserEdge!]! pageInfo: PageInfo! totalCount: Int! }
+
+input ProductInput { name: String description: String price: Float ownerId: ID }
+input UserInput { username: String! email: String! age: Int }
+input PostInput { title: String! content: String userId: ID! }
+`
+
+// 分页参数上限
+const (
+	gqlDefaultPageSize = 10
+	gqlMaxPageSize     = 100
+)
+
+// gqlConnection Relay风格的游标分页结果
+type gqlConnection struct {
+	Edges      []gqlEdge
+	PageInfo   gqlPageInfo
+	TotalCount int
+}
+
+// gqlEdge 分页中的一项
+type gqlEdge struct {
+	Cursor string
+	Node   interface{}
+}
+
+// gqlPageInfo 分页信息
+type gqlPageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+// encodeCursor 游标为 "类型:ID" 的base64编码，按ID递增分页
+func encodeCursor(kind string, id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(kind + ":" + strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeCursor 解析游标，空游标表示从头开始
+func decodeCursor(kind, cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, ok := strings.CutPrefix(string(data), kind+":"); ok {
+			if value, err := strconv.ParseUint(id, 10, 64); err == nil {
+				return uint(value), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("无效的游标: %q", cursor)
+}
+
+// pageArgs 读取 first/after 分页参数
+func pageArgs(kind string, args map[string]interface{}) (int, uint, error) {
+	first, err := gqlArgInt(args, "first", gqlDefaultPageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if first < 0 || first > gqlMaxPageSize {
+		return 0, 0, fmt.Errorf("first 必须在 0 到 %d 之间", gqlMaxPageSize)
+	}
+	after, err := gqlArgString(args, "after")
+	if err != nil {
+		return 0, 0, err
+	}
+	afterID, err := decodeCursor(kind, after)
+	return first, afterID, err
+}
+
+// newConnection 由多取一条的结果构造分页连接
+func newConnection(kind string, ids []uint, nodes []interface{}, first, total int) *gqlConnection {
+	conn := &gqlConnection{Edges: []gqlEdge{}, TotalCount: total}
+	if len(nodes) > first {
+		nodes, ids = nodes[:first], ids[:first]
+		conn.PageInfo.HasNextPage = true
+	}
+	for i, node := range nodes {
+		conn.Edges = append(conn.Edges, gqlEdge{Cursor: encodeCursor(kind, ids[i]), Node: node})
+	}
+	if len(conn.Edges) > 0 {
+		endCursor := conn.Edges[len(conn.Edges)-1].Cursor
+		conn.PageInfo.EndCursor = &endCursor
+	}
+	return conn
+}
+
+// gqlProp 根据源对象的类型生成简单字段的解析函数
+func gqlProp[T any](get func(T) interface{}) gqlResolver {
+	return func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(source.(T)), nil
+	}
+}
+
+// gqlTime 时间统一输出为RFC3339格式
+func gqlTime(t time.Time) interface{} {
+	return t.Format(time.RFC3339)
+}
+
+// gqlID ID统一输出为字符串
+func gqlID(id uint) interface{} {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// newGraphQLSchema 构建Schema，产品来自租户的内存数据，用户和文章来自GORM，按 tenant_users 限定在当前租户内
+func newGraphQLSchema(db *gorm.DB) *gqlSchema {
+	productType := &gqlObjectType{name: "Product"}
+	userType := &gqlObjectType{name: "User"}
+	postType := &gqlObjectType{name: "Post"}
+	pageInfoType := &gqlObjectType{name: "PageInfo"}
+
+	// 按ID批量加载用户，避免 products { owner } 产生N+1查询
+	loadUser := func(ctx *gqlContext, id uint) gqlThunk {
+		return ctx.loader("userByID", func(ids []uint) (map[uint]interface{}, error) {
+			scope, err := tenantUsers(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			var users []database.GormUser
+			if err := db.WithContext(ctx).Where("id IN ? AND id IN (?)", ids, scope).Find(&users).Error; err != nil {
+				return nil, err
+			}
+			result := make(map[uint]interface{}, len(users))
+			for _, user := range users {
+				result[user.ID] = user
+			}
+			return result, nil
+		}).Load(id)
+	}
+
+	// 按用户ID批量加载文章
+	loadPosts := func(ctx *gqlContext, userID uint) gqlThunk {
+		return ctx.loader("postsByUserID", func(ids []uint) (map[uint]interface{}, error) {
+			scope, err := tenantUsers(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			var posts []database.Post
+			if err := db.WithContext(ctx).Where("user_id IN ? AND user_id IN (?)", ids, scope).Order("id").Find(&posts).Error; err != nil {
+				return nil, err
+			}
+			grouped := make(map[uint][]database.Post, len(ids))
+			for _, id := range ids {
+				grouped[id] = []database.Post{}
+			}
+			for _, post := range posts {
+				grouped[post.UserID] = append(grouped[post.UserID], post)
+			}
+			result := make(map[uint]interface{}, len(grouped))
+			for id, list := range grouped {
+				result[id] = list
+			}
+			return result, nil
+		}).Load(userID)
+	}
+
+	productType.fields = map[string]*gqlFieldDef{
+		"id":          {resolve: gqlProp(func(p Product) interface{} { return gqlID(p.ID) })},
+		"name":        {resolve: gqlProp(func(p Product) interface{} { return p.Name })},
+		"description": {resolve: gqlProp(func(p Product) interface{} { return p.Description })},
+		"price":       {resolve: gqlProp(func(p Product) interface{} { return p.Price })},
+		"version":     {resolve: gqlProp(func(p Product) interface{} { return p.Version })},
+		"createdAt":   {resolve: gqlProp(func(p Product) interface{} { return gqlTime(p.CreatedAt) })},
+		"updatedAt":   {resolve: gqlProp(func(p Product) interface{} { return gqlTime(p.UpdatedAt) })},
+		"owner": {typ: userType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			product := source.(Product)
+			if product.OwnerID == 0 {
+				return nil, nil
+			}
+			return loadUser(ctx, product.OwnerID), nil
+		}},
+	}
+
+	userType.fields = map[string]*gqlFieldDef{
+		"id":        {resolve: gqlProp(func(u database.GormUser) interface{} { return gqlID(u.ID) })},
+		"username":  {resolve: gqlProp(func(u database.GormUser) interface{} { return u.Username })},
+		"email":     {resolve: gqlProp(func(u database.GormUser) interface{} { return u.Email })},
+		"age":       {resolve: gqlProp(func(u database.GormUser) interface{} { return u.Age })},
+		"active":    {resolve: gqlProp(func(u database.GormUser) interface{} { return u.Active })},
+		"createdAt": {resolve: gqlProp(func(u database.GormUser) interface{} { return gqlTime(u.CreatedAt) })},
+		"updatedAt": {resolve: gqlProp(func(u database.GormUser) interface{} { return gqlTime(u.UpdatedAt) })},
+		"posts": {typ: postType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return loadPosts(ctx, source.(database.GormUser).ID), nil
+		}},
+	}
+
+	postType.fields = map[string]*gqlFieldDef{
+		"id":        {resolve: gqlProp(func(p database.Post) interface{} { return gqlID(p.ID) })},
+		"title":     {resolve: gqlProp(func(p database.Post) interface{} { return p.Title })},
+		"content":   {resolve: gqlProp(func(p database.Post) interface{} { return p.Content })},
+		"createdAt": {resolve: gqlProp(func(p database.Post) interface{} { return gqlTime(p.CreatedAt) })},
+		"updatedAt": {resolve: gqlProp(func(p database.Post) interface{} { return gqlTime(p.UpdatedAt) })},
+		"author": {typ: userType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return loadUser(ctx, source.(database.Post).UserID), nil
+		}},
+	}
+
+	pageInfoType.fields = map[string]*gqlFieldDef{
+		"hasNextPage": {resolve: gqlProp(func(p gqlPageInfo) interface{} { return p.HasNextPage })},
+		"endCursor":   {resolve: gqlProp(func(p gqlPageInfo) interface{} { return p.EndCursor })},
+	}
+
+	connectionType := func(name string, nodeType *gqlObjectType) *gqlObjectType {
+		edgeType := &gqlObjectType{name: name + "Edge", fields: map[string]*gqlFieldDef{
+			"cursor": {resolve: gqlProp(func(e gqlEdge) interface{} { return e.Cursor })},
+			"node":   {typ: nodeType, resolve: gqlProp(func(e gqlEdge) interface{} { return e.Node })},
+		}}
+		return &gqlObjectType{name: name + "Connection", fields: map[string]*gqlFieldDef{
+			"edges":      {typ: edgeType, resolve: gqlProp(func(c *gqlConnection) interface{} { return c.Edges })},
+			"pageInfo":   {typ: pageInfoType, resolve: gqlProp(func(c *gqlConnection) interface{} { return c.PageInfo })},
+			"totalCount": {resolve: gqlProp(func(c *gqlConnection) interface{} { return c.TotalCount })},
+		}}
+	}
+
+	queryType := &gqlObjectType{name: "Query", fields: map[string]*gqlFieldDef{
+		"product": {typ: productType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			id, err := gqlArgID(args, "id")
+			if err != nil {
+				return nil, err
+			}
+			store, err := gqlProducts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if product, ok := store.Get(id); ok {
+				return product, nil
+			}
+			return nil, nil
+		}},
+		"products": {typ: connectionType("Product", productType), resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			first, afterID, err := pageArgs("Product", args)
+			if err != nil {
+				return nil, err
+			}
+			store, err := gqlProducts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			sorted := store.List()
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+			var ids []uint
+			var nodes []interface{}
+			for _, product := range sorted {
+				if product.ID > afterID && len(nodes) <= first {
+					ids = append(ids, product.ID)
+					nodes = append(nodes, product)
+				}
+			}
+			return newConnection("Product", ids, nodes, first, len(sorted)), nil
+		}},
+		"user": {typ: userType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			id, err := gqlArgID(args, "id")
+			if err != nil {
+				return nil, err
+			}
+			return loadUser(ctx, id), nil
+		}},
+		"users": {typ: connectionType("User", userType), resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			first, afterID, err := pageArgs("User", args)
+			if err != nil {
+				return nil, err
+			}
+			scope, err := tenantUsers(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			var total int64
+			if err := db.WithContext(ctx).Model(&database.GormUser{}).Where("id IN (?)", scope).Count(&total).Error; err != nil {
+				return nil, err
+			}
+			var users []database.GormUser
+			if err := db.WithContext(ctx).Where("id > ? AND id IN (?)", afterID, scope).Order("id").Limit(first + 1).Find(&users).Error; err != nil {
+				return nil, err
+			}
+			ids := make([]uint, len(users))
+			nodes := make([]interface{}, len(users))
+			for i, user := range users {
+				ids[i], nodes[i] = user.ID, user
+			}
+			return newConnection("User", ids, nodes, first, int(total)), nil
+		}},
+		"post": {typ: postType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			id, err := gqlArgID(args, "id")
+			if err != nil {
+				return nil, err
+			}
+			scope, err := tenantUsers(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			var post database.Post
+			err = db.WithContext(ctx).Where("user_id IN (?)", scope).First(&post, id).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return post, err
+		}},
+	}}
+
+	mutationType := &gqlObjectType{name: "Mutation", fields: map[string]*gqlFieldDef{
+		"createProduct": {typ: productType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			store, err := gqlProducts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			product, err := applyProductInput(ctx, Product{}, args["input"])
+			if err != nil {
+				return nil, err
+			}
+			return store.Add(product)
+		}},
+		"updateProduct": {typ: productType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			store, current, err := productForMutation(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			product, err := applyProductInput(ctx, current, args["input"])
+			if err != nil {
+				return nil, err
+			}
+			// 输入基于读取时的版本合并，写入时在存储的锁内确认期间没有被修改
+			check := sameVersion(current.Version)
+			product, err = store.Update(current.ID, func(latest Product) (Product, error) {
+				return product, check(latest)
+			})
+			if err != nil {
+				return nil, err
+			}
+			return product, nil
+		}},
+		"deleteProduct": {resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			store, current, err := productForMutation(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			var check func(Product) error
+			if args["expectedVersion"] != nil {
+				check = sameVersion(current.Version)
+			}
+			if _, err := store.Delete(current.ID, check); err != nil {
+				return nil, err
+			}
+			return true, nil
+		}},
+		"createUser": {typ: userType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			var input struct {
+				Username string `json:"username" binding:"required"`
+				Email    string `json:"email" binding:"required,email"`
+				Age      int    `json:"age"`
+			}
+			if err := decodeAndValidate(args["input"], &input); err != nil {
+				return nil, err
+			}
+			tenant, ok := TenantFromContext(ctx)
+			if !ok {
+				return nil, errors.New("无法确定租户")
+			}
+			user := database.GormUser{Username: input.Username, Email: input.Email, Age: input.Age}
+			err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				return tx.Create(&tenantUser{TenantID: tenant.ID, UserID: user.ID}).Error
+			})
+			if err != nil {
+				return nil, err
+			}
+			return user, nil
+		}},
+		"createPost": {typ: postType, resolve: func(ctx *gqlContext, source interface{}, args map[string]interface{}) (interface{}, error) {
+			var input struct {
+				Title   string      `json:"title" binding:"required"`
+				Content string      `json:"content"`
+				UserID  interface{} `json:"userId" binding:"required"`
+			}
+			if err := decodeAndValidate(args["input"], &input); err != nil {
+				return nil, err
+			}
+			userID, err := gqlArgID(map[string]interface{}{"userId": input.UserID}, "userId")
+			if err != nil {
+				return nil, err
+			}
+			// 只能给本租户的用户发文章
+			scope, err := tenantUsers(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			var count int64
+			if err := db.WithContext(ctx).Model(&database.GormUser{}).Where("id = ? AND id IN (?)", userID, scope).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, fmt.Errorf("用户 %d 不存在", userID)
+			}
+			post := database.Post{Title: input.Title, Content: input.Content, UserID: userID}
+			if err := db.WithContext(ctx).Create(&post).Error; err != nil {
+				return nil, err
+			}
+			return post, nil
+		}},
+	}}
+
+	return &gqlSchema{query: queryType, mutation: mutationType}
+}
+
+// tenantUser 用户所属的租户，GraphQL 中的用户和文章只对所属租户可见
+type tenantUser struct {
+	TenantID string `gorm:"primaryKey;size:32"`
+	UserID   uint   `gorm:"primaryKey"`
+}
+
+// TableName 指定表名
+func (tenantUser) TableName() string {
+	return "tenant_users"
+}
+
+// tenantUsers 返回当前租户的用户ID子查询，所有用户和文章的查询都要用它过滤
+func tenantUsers(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, errors.New("无法确定租户")
+	}
+	return db.WithContext(ctx).Model(&tenantUser{}).Select("user_id").Where("tenant_id = ?", tenant.ID), nil
+}
+
+// gqlProducts 返回当前请求所属租户的产品存储
+func gqlProducts(ctx context.Context) (*ProductStore, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, errors.New("无法确定租户")
+	}
+	return tenant.Products(), nil
+}
+
+// productForMutation 查找要修改的产品，并按 expectedVersion 做乐观锁检查；
+// 写入时还要在存储的锁内用 sameVersion 再次确认
+func productForMutation(ctx context.Context, args map[string]interface{}) (*ProductStore, Product, error) {
+	store, err := gqlProducts(ctx)
+	if err != nil {
+		return nil, Product{}, err
+	}
+	id, err := gqlArgID(args, "id")
+	if err != nil {
+		return nil, Product{}, err
+	}
+	product, ok := store.Get(id)
+	if !ok {
+		return nil, Product{}, fmt.Errorf("产品 %d 不存在", id)
+	}
+	if _, ok := args["expectedVersion"]; ok && args["expectedVersion"] != nil {
+		expected, err := gqlArgInt(args, "expectedVersion", 0)
+		if err != nil {
+			return nil, Product{}, err
+		}
+		if err := sameVersion(uint64(expected))(product); err != nil {
+			return nil, Product{}, err
+		}
+	}
+	return store, product, nil
+}
+
+// sameVersion 返回校验产品版本未变的函数，传给 ProductStore 在锁内执行
+func sameVersion(version uint64) func(current Product) error {
+	return func(current Product) error {
+		if current.Version != version {
+			return fmt.Errorf("%w，当前版本为 %d", ErrVersionConflict, current.Version)
+		}
+		return nil
+	}
+}
+
+// applyProductInput 将输入中出现的字段覆盖到产品上，并按 Product 的绑定规则校验
+func applyProductInput(ctx context.Context, product Product, input interface{}) (Product, error) {
+	fields, ok := input.(map[string]interface{})
+	if !ok {
+		return product, fmt.Errorf("input 必须是对象")
+	}
+	if v, ok := fields["name"]; ok {
+		if product.Name, ok = v.(string); !ok {
+			return product, fmt.Errorf("name 必须是字符串")
+		}
+	}
+	if v, ok := fields["description"]; ok {
+		if product.Description, ok = v.(string); !ok {
+			return product, fmt.Errorf("description 必须是字符串")
+		}
+	}
+	if _, ok := fields["price"]; ok {
+		switch v := fields["price"].(type) {
+		case float64:
+			product.Price = v
+		case int:
+			product.Price = float64(v)
+		default:
+			return product, fmt.Errorf("price 必须是数字")
+		}
+	}
+	if v, ok := fields["ownerId"]; ok {
+		if v == nil {
+			product.OwnerID = 0
+		} else {
+			ownerID, err := gqlArgID(fields, "ownerId")
+			if err != nil {
+				return product, err
+			}
+			product.OwnerID = ownerID
+		}
+	}
+	return product, validateProduct(ctx, &product)
+}
+
+// decodeAndValidate 解码输入对象并执行 binding 标签校验
+func decodeAndValidate(input interface{}, dst interface{}) error {
+	if err := gqlDecodeInput(input, dst); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(dst)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGraphQLParserDepthLimit(t *testing.T) {
+	const n = 1 << 20
+	tests := map[string]string{
+		"选择集":  strings.Repeat("{a", n) + strings.Repeat("}", n),
+		"内联片段": "{" + strings.Repeat("... on Query {", n) + strings.Repeat("}", n+1),
+		"列表值":  "{ products(ids: " + strings.Repeat("[", n) + strings.Repeat("]", n) + ") { id } }",
+		"对象值":  "{ products(filter: " + strings.Repeat("{a: ", n) + "1" + strings.Repeat("}", n) + ") { id } }",
+	}
+	for name, query := range tests {
+		if _, err := parseGraphQL(query); err == nil || !strings.Contains(err.Error(), "嵌套超过") {
+			t.Errorf("%s: 期望嵌套层数错误，实际 %v", name, err)
+		}
+	}
+
+	// 不超过上限的嵌套正常解析
+	query := "{ a(v: " + strings.Repeat("[", gqlMaxDepth) + strings.Repeat("]", gqlMaxDepth) + ")" +
+		strings.Repeat("{a", gqlMaxDepth-1) + strings.Repeat("}", gqlMaxDepth)
+	if _, err := parseGraphQL(query); err != nil {
+		t.Errorf("%d 层嵌套应能解析: %v", gqlMaxDepth, err)
+	}
+}
+
+func TestGraphQLRequestSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := openGraphQLDemoDB()
+	if err != nil {
+		t.Fatalf("初始化GraphQL数据库失败: %v", err)
+	}
+	r := gin.New()
+	handler := NewGraphQLHandler(db)
+	r.GET("/graphql", handler.Handle)
+	r.POST("/graphql", handler.Handle)
+
+	body := `{"query":"{ __typename }","variables":{"padding":"` + strings.Repeat("x", gqlMaxBodyBytes) + `"}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超大的请求体: 期望状态码 413，实际 %d: %.200s", w.Code, w.Body.String())
+	}
+
+	query := "{" + strings.Repeat(" id", gqlMaxQueryBytes/3+1) + " }"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超长的GET查询: 期望状态码 413，实际 %d: %.200s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-basics/cache_persist"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyHeader 幂等键请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyTTL 幂等记录默认保留时间
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyStore 幂等记录的存储后端，多实例部署时必须共享(如Redis)
+type IdempotencyStore interface {
+	Get(key string) (string, bool, error)
+	Set(key string, value string, ttl time.Duration) error
+	// SetNX 键不存在时原子地写入，返回是否写入成功，用于占用幂等键
+	SetNX(key string, value string, ttl time.Duration) (bool, error)
+	Delete(key string) error
+}
+
+// memoryIdempotencyStore 基于内存缓存的存储
+type memoryIdempotencyStore struct {
+	cache *cache_persist.MemoryCache
+}
+
+// NewMemoryIdempotencyStore 使用内存缓存保存幂等记录，只适合单实例部署
+func NewMemoryIdempotencyStore(cache *cache_persist.MemoryCache) IdempotencyStore {
+	return &memoryIdempotencyStore{cache: cache}
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (string, bool, error) {
+	value, found := s.cache.Get(key)
+	return value, found, nil
+}
+
+func (s *memoryIdempotencyStore) Set(key string, value string, ttl time.Duration) error {
+	s.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	return s.cache.SetNX(key, value, ttl), nil
+}
+
+func (s *memoryIdempotencyStore) Delete(key string) error {
+	s.cache.Delete(key)
+	return nil
+}
+
+// redisIdempotencyStore 基于Redis的存储，多实例部署时共享幂等记录
+type redisIdempotencyStore struct {
+	cache *cache_persist.RedisCache
+}
+
+// NewRedisIdempotencyStore 使用Redis保存幂等记录
+func NewRedisIdempotencyStore(cache *cache_persist.RedisCache) IdempotencyStore {
+	return &redisIdempotencyStore{cache: cache}
+}
+
+func (s *redisIdempotencyStore) Get(key string) (string, bool, error) {
+	value, err := s.cache.Get(key)
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisIdempotencyStore) Set(key string, value string, ttl time.Duration) error {
+	return s.cache.Set(key, value, ttl)
+}
+
+func (s *redisIdempotencyStore) SetNX(key string, value string, ttl time.Duration) (bool, error) {
+	return s.cache.SetNX(key, value, ttl)
+}
+
+func (s *redisIdempotencyStore) Delete(key string) error {
+	return s.cache.Delete(key)
+}
+
+// 幂等请求的限制
+const (
+	// MaxIdempotentBodyBytes 携带幂等键的请求体上限，计算指纹需要读入整个请求体
+	MaxIdempotentBodyBytes = 10 << 20
+	// idempotencyLockTTL 处理中标记的有效期，处理请求的实例崩溃后幂等键最多被占用这么久
+	idempotencyLockTTL = time.Minute
+)
+
+// idempotentResponse 保存的首次响应，Status 为0表示请求仍在处理中
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// captureWriter 在写出响应的同时记录响应体
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyClientID 确定客户端身份：租户中间件验证令牌后设置的租户和调用方，
+// 不同租户、不同调用方的相同键互不影响。没有经过认证时返回空字符串
+func idempotencyClientID(c *gin.Context) string {
+	tenant, subject := c.GetString("tenant"), c.GetString("subject")
+	if tenant == "" || subject == "" {
+		return ""
+	}
+	return "tenant:" + tenant + ":subject:" + subject
+}
+
+// IdempotencyMiddleware POST请求幂等中间件
+// 需注册在 TenantMiddleware 之后、ResponseMiddleware 之前，才能确定调用方并记录到完整的响应体。
+// 首次请求先用 SetNX 占用幂等键再执行，相同键的请求在处理完成前响应409，
+// 共享存储时多个实例之间也只会执行一次
+func IdempotencyMiddleware(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		clientID := idempotencyClientID(c)
+		if clientID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "使用 Idempotency-Key 需要认证"})
+			return
+		}
+
+		// 读取请求体计算指纹，并还原给后续处理函数
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": fmt.Sprintf("携带 Idempotency-Key 的请求体不能超过 %d 字节", MaxIdempotentBodyBytes),
+				})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+		storeKey := "idempotency:" + clientID + ":" + key
+
+		// 占用幂等键，已被占用时按已有的记录处理
+		marker, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		reserved, err := store.SetNX(storeKey, string(marker), idempotencyLockTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "幂等存储不可用"})
+			return
+		}
+		if !reserved {
+			replayIdempotent(c, store, storeKey, fingerprint)
+			return
+		}
+
+		// 没有正常保存响应(服务端错误或 panic)时释放幂等键，允许客户端重试
+		saved := false
+		defer func() {
+			if !saved {
+				store.Delete(storeKey)
+			}
+		}()
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range []string{"Content-Type", "Location", "ETag"} {
+			if value := writer.Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		record, err := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      header,
+			Body:        writer.body.Bytes(),
+		})
+		if err == nil && store.Set(storeKey, string(record), ttl) == nil {
+			saved = true
+		}
+	}
+}
+
+// replayIdempotent 处理幂等键已被占用的请求：重放首次响应，首次请求仍在处理时响应409
+func replayIdempotent(c *gin.Context, store IdempotencyStore, storeKey, fingerprint string) {
+	cached, found, err := store.Get(storeKey)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "幂等存储不可用"})
+		return
+	}
+	if !found {
+		// 首次请求刚好失败并释放了幂等键，由客户端重试
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "相同 Idempotency-Key 的请求刚刚失败，请重试"})
+		return
+	}
+	var saved idempotentResponse
+	if err := json.Unmarshal([]byte(cached), &saved); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "幂等记录已损坏"})
+		return
+	}
+	if saved.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key 已被用于不同的请求",
+		})
+		return
+	}
+	if saved.Status == 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "相同 Idempotency-Key 的请求正在处理中"})
+		return
+	}
+
+	// 重放首次响应
+	for name, values := range saved.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Writer.WriteHeader(saved.Status)
+	c.Writer.Write(saved.Body)
+	c.Abort()
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-basics/cache_persist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyTestRouter 模拟一个服务实例：租户中间件、幂等中间件和计数的处理函数
+// 多个实例共享同一个 store 时相当于多实例部署共享Redis
+func idempotencyTestRouter(t *testing.T, store IdempotencyStore, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	registry := NewTenantRegistry()
+	if _, err := registry.Create(Tenant{ID: "alpha", Name: "Alpha"}); err != nil {
+		t.Fatalf("创建租户失败: %v", err)
+	}
+	r := gin.New()
+	r.POST("/orders", TenantMiddleware(registry, TenantFromJWT(testJWTSecret, "tenant")), IdempotencyMiddleware(store, time.Hour), handler)
+	return r
+}
+
+func idempotentPost(t *testing.T, r *gin.Engine, subject, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if subject != "" {
+		token, err := SignJWT(map[string]interface{}{"tenant": "alpha", "sub": subject, "exp": time.Now().Add(time.Hour).Unix()}, testJWTSecret)
+		if err != nil {
+			t.Fatalf("签发令牌失败: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int64
+	r := idempotencyTestRouter(t, NewMemoryIdempotencyStore(cache_persist.NewMemoryCache()), func(c *gin.Context) {
+		c.String(http.StatusCreated, "order-%d", calls.Add(1))
+	})
+
+	first := idempotentPost(t, r, "alice", "k1", `{"item":1}`)
+	second := idempotentPost(t, r, "alice", "k1", `{"item":1}`)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("重放应返回首次响应: %d %q / %d %q", first.Code, first.Body, second.Code, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || calls.Load() != 1 {
+		t.Errorf("重放不应再次执行处理函数，执行了 %d 次", calls.Load())
+	}
+
+	if w := idempotentPost(t, r, "alice", "k1", `{"item":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("相同的键用于不同的请求: 期望 422，实际 %d", w.Code)
+	}
+	// 幂等键按令牌中的调用方区分，不同调用方的相同键互不影响
+	if w := idempotentPost(t, r, "bob", "k1", `{"item":1}`); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("其他调用方的相同键应正常执行: %d", w.Code)
+	}
+	if w := idempotentPost(t, r, "", "k1", `{"item":1}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("未认证的请求: 期望 401，实际 %d", w.Code)
+	}
+	if w := idempotentPost(t, r, "alice", "big", strings.Repeat("x", MaxIdempotentBodyBytes+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超大的请求体: 期望 413，实际 %d", w.Code)
+	}
+}
+
+func TestIdempotencyAcrossInstances(t *testing.T) {
+	store := NewMemoryIdempotencyStore(cache_persist.NewMemoryCache())
+	var calls atomic.Int64
+	release := make(chan struct{})
+	handler := func(c *gin.Context) {
+		calls.Add(1)
+		<-release
+		c.String(http.StatusCreated, "created")
+	}
+	instances := []*gin.Engine{idempotencyTestRouter(t, store, handler), idempotencyTestRouter(t, store, handler)}
+
+	// 第一个实例处理期间，两个实例收到的重复请求都响应409
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- idempotentPost(t, instances[0], "alice", "k1", `{}`) }()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var wg sync.WaitGroup
+	for _, r := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := idempotentPost(t, r, "alice", "k1", `{}`); w.Code != http.StatusConflict {
+				t.Errorf("处理中的重复请求: 期望 409，实际 %d", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("首次请求: 期望 201，实际 %d", w.Code)
+	}
+	if w := idempotentPost(t, instances[1], "alice", "k1", `{}`); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("完成后另一个实例应重放响应: %d", w.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("处理函数应只执行1次，实际 %d 次", calls.Load())
+	}
+}
+
+func TestIdempotencyServerErrorReleasesKey(t *testing.T) {
+	var calls atomic.Int64
+	r := idempotencyTestRouter(t, NewMemoryIdempotencyStore(cache_persist.NewMemoryCache()), func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+	if w := idempotentPost(t, r, "alice", "k1", `{}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("首次请求: 期望 503，实际 %d", w.Code)
+	}
+	if w := idempotentPost(t, r, "alice", "k1", `{}`); w.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("服务端错误后应允许重试: %d，执行了 %d 次", w.Code, calls.Load())
+	}
+}
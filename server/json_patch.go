@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PATCH 请求支持的媒体类型
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7386
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+// ErrPatchTestFailed JSON Patch 的 test 操作未通过
+var ErrPatchTestFailed = errors.New("json patch test 操作失败")
+
+// MergePatch 按 RFC 7386 将 patch 合并到 doc 中
+// patch 中值为 null 的字段会被删除，对象递归合并，其他类型直接替换
+func MergePatch(doc, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		docObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(docObj, key)
+			continue
+		}
+		docObj[key] = MergePatch(docObj[key], value)
+	}
+	return docObj
+}
+
+// PatchOperation JSON Patch 操作
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ApplyJSONPatch 按 RFC 6902 依次执行操作，任一操作失败则返回错误
+func ApplyJSONPatch(doc interface{}, ops []PatchOperation) (interface{}, error) {
+	var err error
+	for i, op := range ops {
+		switch op.Op {
+		case "add":
+			doc, err = addValue(doc, op.Path, op.Value)
+		case "remove":
+			doc, _, err = removeValue(doc, op.Path)
+		case "replace":
+			if doc, _, err = removeValue(doc, op.Path); err == nil {
+				doc, err = addValue(doc, op.Path, op.Value)
+			}
+		case "move":
+			var value interface{}
+			if doc, value, err = removeValue(doc, op.From); err == nil {
+				doc, err = addValue(doc, op.Path, value)
+			}
+		case "copy":
+			var value interface{}
+			if value, err = getValue(doc, op.From); err == nil {
+				doc, err = addValue(doc, op.Path, deepCopy(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = getValue(doc, op.Path); err == nil && !reflect.DeepEqual(value, op.Value) {
+				err = ErrPatchTestFailed
+			}
+		default:
+			err = fmt.Errorf("不支持的操作: %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d个操作(%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer 解析 JSON Pointer (RFC 6901)
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("无效的路径: %q", path)
+	}
+	parts := strings.Split(path[1:], "/")
+	for i, part := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+	}
+	return parts, nil
+}
+
+// arrayIndex 解析数组下标，allowEnd 为 true 时允许 "-" 和 len 表示末尾
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("数组下标越界: %q", token)
+	}
+	return index, nil
+}
+
+// getValue 读取路径上的值
+func getValue(doc interface{}, path string) (interface{}, error) {
+	parts, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	current := doc
+	for _, part := range parts {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[part]
+			if !ok {
+				return nil, fmt.Errorf("路径不存在: %q", path)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(part, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("路径不存在: %q", path)
+		}
+	}
+	return current, nil
+}
+
+// addValue 在路径上添加值，数组中为插入，对象中为设置
+func addValue(doc interface{}, path string, value interface{}) (interface{}, error) {
+	parts, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return value, nil
+	}
+
+	parent, err := getParent(doc, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	last := parts[len(parts)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node[:index], append([]interface{}{value}, node[index:]...)...)
+		return setValue(doc, parts[:len(parts)-1], node)
+	default:
+		return nil, fmt.Errorf("路径不存在: %q", path)
+	}
+}
+
+// removeValue 删除路径上的值并返回被删除的值
+func removeValue(doc interface{}, path string) (interface{}, interface{}, error) {
+	parts, err := parsePointer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parts) == 0 {
+		return nil, nil, errors.New("不能删除整个文档")
+	}
+
+	parent, err := getParent(doc, parts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := parts[len(parts)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("路径不存在: %q", path)
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		doc, err = setValue(doc, parts[:len(parts)-1], node)
+		return doc, value, err
+	default:
+		return nil, nil, fmt.Errorf("路径不存在: %q", path)
+	}
+}
+
+// setValue 用新值替换路径上的节点，用于数组长度变化后写回
+func setValue(doc interface{}, parts []string, value interface{}) (interface{}, error) {
+	if len(parts) == 0 {
+		return value, nil
+	}
+	parent, err := getParent(doc, parts)
+	if err != nil {
+		return nil, err
+	}
+	last := parts[len(parts)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	}
+	return doc, nil
+}
+
+// getParent 读取路径最后一段所在的父节点
+func getParent(doc interface{}, parts []string) (interface{}, error) {
+	if len(parts) == 1 {
+		return doc, nil
+	}
+	return getValue(doc, "/"+strings.Join(escapeTokens(parts[:len(parts)-1]), "/"))
+}
+
+// escapeTokens 将路径片段重新转义为 JSON Pointer 格式
+func escapeTokens(parts []string) []string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(part)
+	}
+	return escaped
+}
+
+// deepCopy 通过JSON编解码复制值
+func deepCopy(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
@@ -1,14 +1,28 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
+
+	"go-basics/config"
 )
 
 // DemonstrateMiddleware 展示中间件的使用
 func DemonstrateMiddleware() {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if err := RunMux(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunMux 使用标准库 ServeMux 和中间件链启动服务器，直到 ctx 取消
+func RunMux(ctx context.Context, cfg *config.Config) error {
 	// 创建路由
 	mux := http.NewServeMux()
 
@@ -21,15 +35,17 @@ func DemonstrateMiddleware() {
 	))
 
 	// 启动服务器
-	fmt.Println("服务器启动在 http://localhost:8080")
+	opts, err := ServerOptionsFromConfig(cfg.Server)
+	if err != nil {
+		return fmt.Errorf("读取服务器配置失败: %w", err)
+	}
+	fmt.Println("服务器启动在 " + opts.URL())
 	fmt.Println("可以尝试访问以下URL：")
-	fmt.Println("1. http://localhost:8080/ (无token)")
-	fmt.Println("2. http://localhost:8080/?token=valid (带有效token)")
+	fmt.Println("1. " + opts.URL() + "/ (无token)")
+	fmt.Println("2. " + opts.URL() + "/?token=valid (带有效token)")
 	fmt.Println("按 Ctrl+C 停止服务器")
 
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatal(err)
-	}
+	return Serve(ctx, opts, mux)
 }
 
 // 处理函数类型
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// negotiatedFormats 可协商的响应格式，Accept 为空或 */* 时使用第一个
+var negotiatedFormats = []string{
+	binding.MIMEJSON,
+	binding.MIMEXML,
+	binding.MIMEXML2,
+	binding.MIMEMSGPACK,
+	binding.MIMEMSGPACK2,
+}
+
+// Negotiate 按 Accept 头以JSON、XML或MessagePack输出，无法匹配时使用JSON
+func Negotiate(c *gin.Context, status int, obj interface{}) {
+	c.Header("Vary", "Accept")
+	switch format := c.NegotiateFormat(negotiatedFormats...); format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		// 显式设置，避免沿用版本路由预设的 application/vnd.app.vN+json
+		c.Header("Content-Type", format+"; charset=utf-8")
+		c.XML(status, obj)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		c.Header("Content-Type", format)
+		c.Render(status, render.MsgPack{Data: obj})
+	default:
+		c.JSON(status, obj)
+	}
+}
+
+// MarshalXML 统一响应的XML形式，与 stdlib.Library 一样以小写元素名输出
+func (r ProductResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "response"}
+	return e.EncodeElement(struct {
+		Code    int      `xml:"code"`
+		Message string   `xml:"message"`
+		Data    xmlValue `xml:"data"`
+	}{r.Code, r.Message, xmlValue{r.Data}}, start)
+}
+
+// MarshalXML 搜索结果的XML形式，高亮片段按字段名输出为子元素
+func (r SearchResult) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "result"}
+	return e.EncodeElement(struct {
+		Score      float64  `xml:"score,attr"`
+		Product    Product  `xml:"product"`
+		Highlights xmlValue `xml:"highlights,omitempty"`
+	}{r.Score, r.Product, xmlValue{r.Highlights}}, start)
+}
+
+// xmlValue 让 gin.H 等 encoding/xml 不支持的值也能输出为XML
+// map 的键输出为子元素，切片的元素输出为 <item> 或其 XMLName 指定的元素
+type xmlValue struct {
+	value interface{}
+}
+
+// MarshalXML 实现 xml.Marshaler
+func (v xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if v.value == nil {
+		return e.EncodeElement("", start)
+	}
+
+	rv := reflect.ValueOf(v.value)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("xml: 不支持键类型为 %s 的map", rv.Type().Key())
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).Interface()
+			if err := e.EncodeElement(xmlValue{value}, xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return e.EncodeElement(v.value, start)
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXMLItem(e, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	default:
+		return e.EncodeElement(v.value, start)
+	}
+}
+
+// encodeXMLItem 输出切片中的一个元素，带 XMLName 的结构体使用其自身的元素名
+func encodeXMLItem(e *xml.Encoder, item interface{}) error {
+	t := reflect.TypeOf(item)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		if _, ok := t.FieldByName("XMLName"); ok {
+			return e.Encode(item)
+		}
+	}
+	return e.EncodeElement(xmlValue{item}, xml.StartElement{Name: xml.Name{Local: "item"}})
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 批量导入导出支持的格式
+const (
+	BulkFormatCSV   = "csv"
+	BulkFormatJSONL = "jsonl"
+)
+
+const (
+	maxImportErrors   = 100     // 响应中最多返回的行错误数
+	maxJSONLLineBytes = 1 << 20 // JSON Lines 单行最大长度
+	exportFlushEvery  = 100     // 导出时每写出多少行刷新一次
+)
+
+// csvExportHeader 导出CSV的列
+var csvExportHeader = []string{"id", "name", "description", "price", "version", "created_at", "updated_at"}
+
+// ImportRowError 导入时某一行的错误
+type ImportRowError struct {
+	Line  int    `xml:"line,attr" json:"line"`
+	Error string `xml:",chardata" json:"error"`
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	DryRun   bool             `xml:"dry_run" json:"dry_run"`
+	Total    int              `xml:"total" json:"total"`
+	Imported int              `xml:"imported" json:"imported"`
+	Failed   int              `xml:"failed" json:"failed"`
+	Errors   []ImportRowError `xml:"errors>error" json:"errors"`
+}
+
+// addError 记录行错误，超过上限后只计数
+func (r *ImportResult) addError(line int, message string) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, ImportRowError{Line: line, Error: message})
+	}
+}
+
+// ProductActionPOST 分发 /products:<action> 的POST请求
+func ProductActionPOST(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("action"), ":") {
+	case "import":
+		ImportProducts(c)
+	default:
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// ProductActionGET 分发 /products:<action> 的GET请求
+func ProductActionGET(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("action"), ":") {
+	case "export":
+		ExportProducts(c)
+	default:
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// importFormat 根据 format 参数或 Content-Type 确定导入格式
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return BulkFormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/json-lines":
+		return BulkFormatJSONL
+	}
+	return ""
+}
+
+// ImportProducts 流式导入产品，每行按 Product 的绑定规则校验
+// dry_run=true 时只校验不保存
+func ImportProducts(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	result := &ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+	printer := requestPrinter(c)
+	store := tenantProducts(c)
+
+	handleRow := func(line int, product Product, err error) {
+		result.Total++
+		if err == nil {
+			err = validateProduct(c.Request.Context(), &product)
+		}
+		if err != nil {
+			result.addError(line, validationMessage(printer, err))
+			return
+		}
+		if !dryRun {
+			if _, err := store.Add(product); errors.Is(err, ErrDuplicateProductName) {
+				result.addError(line, duplicateNameMessage(printer, product.Name))
+				return
+			} else if err != nil {
+				result.addError(line, err.Error())
+				return
+			}
+		}
+		result.Imported++
+	}
+
+	var err error
+	switch importFormat(c) {
+	case BulkFormatCSV:
+		err = readProductsCSV(c.Request.Body, handleRow)
+	case BulkFormatJSONL:
+		err = readProductsJSONL(c.Request.Body, handleRow)
+	default:
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "仅支持 csv(text/csv) 和 jsonl(application/x-ndjson) 格式",
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	c.Set("data", result)
+}
+
+// escapeCSVCell 以 = + - @ 制表符或回车开头的文本在 Excel、表格软件中会被当作公式执行，
+// 导出时在前面加单引号，作为文本显示
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVCell 去掉 escapeCSVCell 加的单引号，导出的文件可以原样导入
+func unescapeCSVCell(value string) string {
+	if rest, ok := strings.CutPrefix(value, "'"); ok && escapeCSVCell(rest) != rest {
+		return rest
+	}
+	return value
+}
+
+// readProductsCSV 逐行读取CSV，首行为表头，至少包含 name 和 price 列
+func readProductsCSV(r io.Reader, handle func(line int, product Product, err error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("读取表头失败: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("表头缺少 %s 列", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			handle(parseErr.Line, Product{}, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+
+		product := Product{
+			Name:        unescapeCSVCell(field(record, "name")),
+			Description: unescapeCSVCell(field(record, "description")),
+		}
+		if price := field(record, "price"); price != "" {
+			if product.Price, err = strconv.ParseFloat(price, 64); err != nil {
+				handle(line, product, fmt.Errorf("price 不是有效的数字: %q", price))
+				continue
+			}
+		}
+		handle(line, product, nil)
+	}
+}
+
+// readProductsJSONL 逐行读取 JSON Lines，空行会被跳过
+func readProductsJSONL(r io.Reader, handle func(line int, product Product, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var product Product
+		err := json.Unmarshal([]byte(text), &product)
+		// ID、版本号和时间戳由服务端分配
+		handle(line, Product{
+			Name:        product.Name,
+			Description: product.Description,
+			Price:       product.Price,
+		}, err)
+	}
+	return scanner.Err()
+}
+
+// ExportProducts 流式导出产品，逐行写出并定期刷新
+func ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", BulkFormatCSV)
+
+	var writeRow func(Product) error
+	var flush func() error
+	switch format {
+	case BulkFormatCSV:
+		writer := csv.NewWriter(c.Writer)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="products.csv"`)
+		if err := writer.Write(csvExportHeader); err != nil {
+			return
+		}
+		writeRow = func(p Product) error {
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(p.ID), 10),
+				escapeCSVCell(p.Name),
+				escapeCSVCell(p.Description),
+				strconv.FormatFloat(p.Price, 'f', -1, 64),
+				strconv.FormatUint(p.Version, 10),
+				p.CreatedAt.Format(time.RFC3339),
+				p.UpdatedAt.Format(time.RFC3339),
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case BulkFormatJSONL:
+		encoder := json.NewEncoder(c.Writer)
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="products.jsonl"`)
+		writeRow = func(p Product) error {
+			return encoder.Encode(p)
+		}
+		flush = func() error { return nil }
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format 仅支持 csv 或 jsonl"})
+		return
+	}
+
+	// 立即写出响应头，避免无数据时被统一响应中间件改写
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	for i, product := range tenantProducts(c).List() {
+		if err := writeRow(product); err != nil {
+			return
+		}
+		if (i+1)%exportFlushEvery == 0 {
+			if flush() != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+	if flush() == nil {
+		c.Writer.Flush()
+	}
+}
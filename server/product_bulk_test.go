@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	s := newTenantTestServer(t)
+	names := []string{"=HYPERLINK(\"http://evil.example\",\"点我\")", "+1+2", "-3", "@SUM(A1)", "\tTab", "普通产品"}
+	for _, name := range names {
+		body, _ := json.Marshal(map[string]interface{}{"name": name, "description": "=1+1", "price": 1})
+		s.expect("创建产品 "+name, s.do(http.MethodPost, "/api/products", "alpha", string(body)), http.StatusCreated)
+	}
+
+	w := s.do(http.MethodGet, "/api/products:export?format=csv", "alpha", "")
+	s.expect("导出CSV", w, http.StatusOK)
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil || len(records) != len(names)+1 {
+		t.Fatalf("解析导出的CSV失败: %v: %s", err, w.Body.String())
+	}
+	for i, record := range records[1:] {
+		name, description := record[1], record[2]
+		want := names[i]
+		if names[i] != "普通产品" {
+			want = "'" + names[i]
+		}
+		if name != want || description != "'=1+1" {
+			t.Errorf("第%d行: 期望名称 %q、描述 %q，实际 %q、%q", i+1, want, "'=1+1", name, description)
+		}
+	}
+
+	// JSON Lines 不会被当作公式，保持原样
+	if w := s.do(http.MethodGet, "/api/products:export?format=jsonl", "alpha", ""); strings.Contains(w.Body.String(), `"'=`) {
+		t.Errorf("JSONL 导出不应转义: %s", w.Body.String())
+	}
+
+	// 导出的CSV再导入时去掉转义用的单引号
+	var imported []Product
+	err = readProductsCSV(strings.NewReader(w.Body.String()), func(line int, product Product, err error) {
+		if err != nil {
+			t.Errorf("第%d行导入失败: %v", line, err)
+		}
+		imported = append(imported, product)
+	})
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	for i, product := range imported {
+		if product.Name != names[i] || product.Description != "=1+1" {
+			t.Errorf("第%d行导入后为 %q、%q", i+1, product.Name, product.Description)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// 产品变更事件类型
+const (
+	ProductCreated = "product.created"
+	ProductUpdated = "product.updated"
+	ProductDeleted = "product.deleted"
+)
+
+// ProductEvent 产品变更事件
+type ProductEvent struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Product   Product   `json:"product"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscriber 事件订阅者，每个客户端拥有独立的缓冲区
+type Subscriber struct {
+	Events <-chan ProductEvent
+	events chan ProductEvent
+	bus    *EventBus
+}
+
+// Close 取消订阅
+func (s *Subscriber) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// EventBus 产品变更事件总线
+// 保留最近的事件用于 Last-Event-ID 断线续传
+type EventBus struct {
+	mutex       sync.Mutex
+	nextID      uint64
+	history     []ProductEvent
+	historySize int
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewEventBus 创建事件总线，historySize 为可回放的历史事件数
+func NewEventBus(historySize int) *EventBus {
+	return &EventBus{
+		historySize: historySize,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish 发布事件
+// 缓冲区已满的慢客户端会被断开，由客户端携带 Last-Event-ID 重连续传
+func (b *EventBus) Publish(eventType string, product Product) ProductEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.nextID++
+	event := ProductEvent{
+		ID:        b.nextID,
+		Type:      eventType,
+		Product:   product,
+		CreatedAt: time.Now(),
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+	return event
+}
+
+// Subscribe 订阅事件，lastEventID 之后的历史事件会先被回放
+func (b *EventBus) Subscribe(lastEventID uint64, bufferSize int) *Subscriber {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var missed []ProductEvent
+	for _, event := range b.history {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	// 保证缓冲区能容纳待回放的历史事件
+	if bufferSize < len(missed) {
+		bufferSize = len(missed)
+	}
+	events := make(chan ProductEvent, bufferSize)
+	for _, event := range missed {
+		events <- event
+	}
+
+	sub := &Subscriber{Events: events, events: events, bus: b}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe 移除订阅者并关闭其通道
+func (b *EventBus) unsubscribe(sub *Subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+const (
+	eventBufferSize   = 64
+	heartbeatInterval = 15 * time.Second
+)
+
+// parseLastEventID 解析 Last-Event-ID，无效值视为从头开始
+func parseLastEventID(value string) uint64 {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// ProductEventsSSE 通过 Server-Sent Events 推送产品变更
+func ProductEventsSSE(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	sub := tenantProducts(c).Subscribe(parseLastEventID(lastEventID), eventBufferSize)
+	defer sub.Close()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				return false
+			}
+			return writeSSEEvent(w, event) == nil
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// writeSSEEvent 按 SSE 格式写出一个事件
+func writeSSEEvent(w io.Writer, event ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// ProductEventsWebSocket 通过 WebSocket 推送产品变更
+// 浏览器 WebSocket 无法设置请求头，断线续传使用 last_event_id 查询参数
+func ProductEventsWebSocket(c *gin.Context) {
+	lastEventID := parseLastEventID(c.Query("last_event_id"))
+	store := tenantProducts(c)
+
+	handler := websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		sub := store.Subscribe(lastEventID, eventBufferSize)
+		defer sub.Close()
+
+		// 读取协程：客户端断开时结束推送
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard string
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := websocket.JSON.Send(ws, gin.H{"type": "heartbeat"}); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	})
+	handler.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	nameFieldWeight = 2 // 名称中的词频按2倍计算
+
+	prefixMatchFactor = 0.8 // 前缀匹配的得分折扣
+	fuzzyMatchFactor  = 0.5 // 拼写纠错匹配的得分折扣
+	minFuzzyTermLen   = 4   // 长度不足的词不做拼写纠错
+
+	snippetContext = 20 // 高亮片段在命中词前后保留的字符数
+)
+
+// token 分词结果，start/end 为原文中的字节偏移
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenize 分词：拉丁字母和数字按单词切分并转小写，中日韩文字按二元组(bigram)切分
+func tokenize(text string) []token {
+	var tokens []token
+
+	// 当前正在累积的连续片段
+	type run struct {
+		start int
+		runes []rune
+		offs  []int
+	}
+	var word, cjk run
+
+	flushWord := func(end int) {
+		if len(word.runes) > 0 {
+			tokens = append(tokens, token{
+				term:  strings.ToLower(string(word.runes)),
+				start: word.start,
+				end:   end,
+			})
+		}
+		word = run{}
+	}
+	flushCJK := func(end int) {
+		n := len(cjk.runes)
+		switch {
+		case n == 1:
+			tokens = append(tokens, token{term: string(cjk.runes), start: cjk.start, end: end})
+		case n > 1:
+			for i := 0; i+1 < n; i++ {
+				tokenEnd := end
+				if i+2 < n {
+					tokenEnd = cjk.offs[i+2]
+				}
+				tokens = append(tokens, token{
+					term:  string(cjk.runes[i : i+2]),
+					start: cjk.offs[i],
+					end:   tokenEnd,
+				})
+			}
+		}
+		cjk = run{}
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			if len(cjk.runes) == 0 {
+				cjk.start = i
+			}
+			cjk.runes = append(cjk.runes, r)
+			cjk.offs = append(cjk.offs, i)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK(i)
+			if len(word.runes) == 0 {
+				word.start = i
+			}
+			word.runes = append(word.runes, r)
+		default:
+			flushWord(i)
+			flushCJK(i)
+		}
+	}
+	flushWord(len(text))
+	flushCJK(len(text))
+
+	return tokens
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Product    Product           `json:"product"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchIndex 产品倒排索引
+type SearchIndex struct {
+	mutex       sync.RWMutex
+	postings    map[string]map[uint]int // 词 -> 产品ID -> 加权词频
+	docLengths  map[uint]int
+	docs        map[uint]Product
+	totalLength int
+}
+
+// NewSearchIndex 创建倒排索引
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings:   make(map[string]map[uint]int),
+		docLengths: make(map[uint]int),
+		docs:       make(map[uint]Product),
+	}
+}
+
+// Index 添加或更新产品
+func (idx *SearchIndex) Index(product Product) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.remove(product.ID)
+
+	freqs := make(map[string]int)
+	length := 0
+	for _, t := range tokenize(product.Name) {
+		freqs[t.term] += nameFieldWeight
+		length += nameFieldWeight
+	}
+	for _, t := range tokenize(product.Description) {
+		freqs[t.term]++
+		length++
+	}
+
+	for term, freq := range freqs {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[uint]int)
+		}
+		idx.postings[term][product.ID] = freq
+	}
+	idx.docLengths[product.ID] = length
+	idx.docs[product.ID] = product
+	idx.totalLength += length
+}
+
+// Remove 从索引中删除产品
+func (idx *SearchIndex) Remove(id uint) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.remove(id)
+}
+
+// remove 删除产品，调用方需持有写锁
+func (idx *SearchIndex) remove(id uint) {
+	if _, ok := idx.docs[id]; !ok {
+		return
+	}
+	for term, docs := range idx.postings {
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLength -= idx.docLengths[id]
+	delete(idx.docLengths, id)
+	delete(idx.docs, id)
+}
+
+// expandTerm 扩展查询词：精确匹配优先，否则尝试前缀匹配和拼写纠错
+// 返回 词 -> 得分折扣
+func (idx *SearchIndex) expandTerm(term string) map[string]float64 {
+	expanded := make(map[string]float64)
+	if _, ok := idx.postings[term]; ok {
+		expanded[term] = 1
+	}
+
+	for candidate := range idx.postings {
+		if candidate != term && strings.HasPrefix(candidate, term) {
+			expanded[candidate] = prefixMatchFactor
+		}
+	}
+	if len(expanded) > 0 || utf8.RuneCountInString(term) < minFuzzyTermLen {
+		return expanded
+	}
+
+	// 没有精确或前缀匹配时，允许一个字符的拼写错误
+	for candidate := range idx.postings {
+		if levenshtein(term, candidate) <= 1 {
+			expanded[candidate] = fuzzyMatchFactor
+		}
+	}
+	return expanded
+}
+
+// Search 按 BM25 相关度返回匹配的产品，limit<=0 表示不限制
+func (idx *SearchIndex) Search(query string, limit int) []SearchResult {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	n := len(idx.docs)
+	if n == 0 {
+		return nil
+	}
+	avgLength := float64(idx.totalLength) / float64(n)
+
+	scores := make(map[uint]float64)
+	matched := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		if seen[t.term] {
+			continue
+		}
+		seen[t.term] = true
+
+		for term, factor := range idx.expandTerm(t.term) {
+			docs := idx.postings[term]
+			matched[term] = true
+			idf := math.Log(1 + (float64(n)-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for id, freq := range docs {
+				tf := float64(freq)
+				norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.docLengths[id])/avgLength)
+				scores[id] += factor * idf * tf * (bm25K1 + 1) / (tf + norm)
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		product := idx.docs[id]
+		highlights := make(map[string]string)
+		if snippet, ok := highlight(product.Name, matched); ok {
+			highlights["name"] = snippet
+		}
+		if snippet, ok := highlight(product.Description, matched); ok {
+			highlights["description"] = snippet
+		}
+		results = append(results, SearchResult{
+			Product:    product,
+			Score:      score,
+			Highlights: highlights,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Product.ID < results[j].Product.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// highlight 用 <em> 标记命中的词，并截取命中位置附近的片段；
+// 原文中的每一段都先做 HTML 转义，片段中只有 <em> 是标签
+func highlight(text string, matched map[string]bool) (string, bool) {
+	// 合并相邻或重叠的命中区间
+	var spans [][2]int
+	for _, t := range tokenize(text) {
+		if !matched[t.term] {
+			continue
+		}
+		if last := len(spans) - 1; last >= 0 && t.start <= spans[last][1] {
+			spans[last][1] = max(spans[last][1], t.end)
+			continue
+		}
+		spans = append(spans, [2]int{t.start, t.end})
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	from := backRunes(text, spans[0][0], snippetContext)
+	to := forwardRunes(text, spans[len(spans)-1][1], snippetContext)
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, span := range spans {
+		if span[0] >= to {
+			break
+		}
+		sb.WriteString(html.EscapeString(text[pos:span[0]]))
+		sb.WriteString("<em>")
+		sb.WriteString(html.EscapeString(text[span[0]:span[1]]))
+		sb.WriteString("</em>")
+		pos = span[1]
+	}
+	if pos < to {
+		sb.WriteString(html.EscapeString(text[pos:to]))
+	}
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
+
+// backRunes 从 pos 向前移动 n 个字符，返回字节偏移
+func backRunes(text string, pos, n int) int {
+	for ; n > 0 && pos > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[:pos])
+		pos -= size
+	}
+	return pos
+}
+
+// forwardRunes 从 pos 向后移动 n 个字符，返回字节偏移
+func forwardRunes(text string, pos, n int) int {
+	for ; n > 0 && pos < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrProductQuotaExceeded = errors.New("已达到租户的产品数量上限")
+	ErrProductNotFound      = errors.New("产品不存在")
+	ErrVersionConflict      = errors.New("产品已被修改")
+	ErrDuplicateProductName = errors.New("产品名称已存在")
+)
+
+// ProductStore 单个租户的产品数据，连同全文索引和变更事件
+// 每个租户持有独立的 ProductStore，查询只能看到本租户的产品；
+// 可被多个请求并发使用，读取返回副本，修改按ID在锁内查找和写入，名称唯一性也在锁内检查
+type ProductStore struct {
+	mutex    sync.RWMutex
+	products []Product
+	names    map[string]uint // 小写的产品名称到产品ID，名称在租户内不区分大小写地唯一
+	nextID   uint
+	quota    int // 产品数量上限，0表示不限制
+	index    *SearchIndex
+	events   *EventBus
+}
+
+// NewProductStore 创建产品存储，quota 为产品数量上限，0表示不限制
+func NewProductStore(quota int) *ProductStore {
+	return &ProductStore{
+		names:  make(map[string]uint),
+		nextID: 1,
+		quota:  quota,
+		index:  NewSearchIndex(),
+		events: NewEventBus(1000),
+	}
+}
+
+// List 返回全部产品的副本
+func (s *ProductStore) List() []Product {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return slices.Clone(s.products)
+}
+
+// Get 按ID返回产品
+func (s *ProductStore) Get(id uint) (Product, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if i := s.find(id); i >= 0 {
+		return s.products[i], true
+	}
+	return Product{}, false
+}
+
+// HasName 判断名称是否已被 exceptID 以外的产品使用，不区分大小写
+func (s *ProductStore) HasName(name string, exceptID uint) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.nameTaken(name, exceptID)
+}
+
+// nameTaken 与 HasName 相同，调用方需持有锁
+func (s *ProductStore) nameTaken(name string, exceptID uint) bool {
+	id, ok := s.names[strings.ToLower(name)]
+	return ok && id != exceptID
+}
+
+// find 返回产品在列表中的下标，不存在时返回-1，调用方需持有锁
+func (s *ProductStore) find(id uint) int {
+	return slices.IndexFunc(s.products, func(p Product) bool { return p.ID == id })
+}
+
+// Add 保存新产品，由服务端分配ID、版本号和时间戳
+func (s *ProductStore) Add(product Product) (Product, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.quota > 0 && len(s.products) >= s.quota {
+		return product, ErrProductQuotaExceeded
+	}
+	if s.nameTaken(product.Name, 0) {
+		return product, ErrDuplicateProductName
+	}
+
+	// 设置创建时间和更新时间
+	product.ID = s.nextID
+	product.Version = 1
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = time.Now()
+	s.nextID++
+
+	s.products = append(s.products, product)
+	s.names[strings.ToLower(product.Name)] = product.ID
+	s.index.Index(product)
+	s.events.Publish(ProductCreated, product)
+	return product, nil
+}
+
+// Update 在锁内读取产品，用 fn 的返回值替换它。ID和创建时间以服务端为准，版本号递增。
+// fn 返回错误(如 ErrVersionConflict)或新名称已被其他产品使用(ErrDuplicateProductName)时不做修改，同时返回当前的产品；
+// 版本检查放在 fn 中即为按ID和版本的比较并交换。fn 在锁内执行，不能再调用本存储的方法
+func (s *ProductStore) Update(id uint, fn func(current Product) (Product, error)) (Product, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return Product{}, ErrProductNotFound
+	}
+	current := s.products[i]
+	product, err := fn(current)
+	if err != nil {
+		return current, err
+	}
+	if s.nameTaken(product.Name, current.ID) {
+		return current, ErrDuplicateProductName
+	}
+	product.ID = current.ID
+	product.CreatedAt = current.CreatedAt
+	product.Version = current.Version + 1
+	product.UpdatedAt = time.Now()
+	s.products[i] = product
+	delete(s.names, strings.ToLower(current.Name))
+	s.names[strings.ToLower(product.Name)] = product.ID
+	s.index.Index(product)
+	s.events.Publish(ProductUpdated, product)
+	return product, nil
+}
+
+// Delete 删除产品并返回被删除的产品。check 不为 nil 时在锁内检查当前的产品，
+// 返回错误时不删除，同时返回当前的产品
+func (s *ProductStore) Delete(id uint, check func(current Product) error) (Product, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return Product{}, ErrProductNotFound
+	}
+	product := s.products[i]
+	if check != nil {
+		if err := check(product); err != nil {
+			return product, err
+		}
+	}
+	s.products = slices.Delete(s.products, i, i+1)
+	delete(s.names, strings.ToLower(product.Name))
+	s.index.Remove(product.ID)
+	s.events.Publish(ProductDeleted, product)
+	return product, nil
+}
+
+// Search 在本租户的产品中检索
+func (s *ProductStore) Search(query string, limit int) []SearchResult {
+	return s.index.Search(query, limit)
+}
+
+// Subscribe 订阅本租户的产品变更
+func (s *ProductStore) Subscribe(lastEventID uint64, bufferSize int) *Subscriber {
+	return s.events.Subscribe(lastEventID, bufferSize)
+}
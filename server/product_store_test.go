@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProductStoreUniqueName(t *testing.T) {
+	store := NewProductStore(0)
+
+	// 并发创建同名(不区分大小写)的产品，只有一个成功
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		name := "Gopher"
+		if i%2 == 1 {
+			name = strings.ToUpper(name)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Add(Product{Name: name, Price: 1})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrDuplicateProductName):
+			t.Fatalf("期望 ErrDuplicateProductName，实际 %v", err)
+		}
+	}
+	if created != 1 || len(store.List()) != 1 {
+		t.Fatalf("并发创建同名产品: 期望成功1个，实际 %d 个，存储中有 %d 个", created, len(store.List()))
+	}
+
+	other, err := store.Add(Product{Name: "Rust", Price: 1})
+	if err != nil {
+		t.Fatalf("创建产品失败: %v", err)
+	}
+	rename := func(name string) func(Product) (Product, error) {
+		return func(current Product) (Product, error) {
+			current.Name = name
+			return current, nil
+		}
+	}
+	if _, err := store.Update(other.ID, rename("gopher")); !errors.Is(err, ErrDuplicateProductName) {
+		t.Errorf("改为已存在的名称: 期望 ErrDuplicateProductName，实际 %v", err)
+	}
+	if _, err := store.Update(other.ID, rename("RUST")); err != nil {
+		t.Errorf("只修改自身名称的大小写: %v", err)
+	}
+
+	// 改名和删除后原名称可以再次使用
+	if _, err := store.Update(other.ID, rename("Zig")); err != nil {
+		t.Fatalf("改名失败: %v", err)
+	}
+	if _, err := store.Add(Product{Name: "Rust", Price: 1}); err != nil {
+		t.Errorf("改名后原名称应可用: %v", err)
+	}
+	if _, err := store.Delete(other.ID, nil); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if store.HasName("zig", 0) {
+		t.Error("删除后名称应可用")
+	}
+}
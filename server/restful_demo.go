@@ -1,22 +1,35 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"go-basics/cache_persist"
+	"go-basics/config"
+	"go-basics/database"
+
 	"github.com/gin-gonic/gin"
 )
 
 // Product 产品结构体
 type Product struct {
-	ID          uint      `json:"id"`
-	Name        string    `json:"name" binding:"required"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price" binding:"required,gt=0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	XMLName     xml.Name  `xml:"product" json:"-"`
+	ID          uint      `xml:"id,attr" json:"id"`
+	Name        string    `xml:"name" json:"name" binding:"required,max=100"`
+	Description string    `xml:"description" json:"description" binding:"max=1000"`
+	Price       float64   `xml:"price" json:"price" binding:"required,gt=0,price_precision=2"`
+	OwnerID     uint      `xml:"owner_id,omitempty" json:"owner_id,omitempty"` // 所属用户(database.GormUser)的ID
+	Version     uint64    `xml:"version" json:"version"`                       // 乐观锁版本号，每次修改递增
+	CreatedAt   time.Time `xml:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `xml:"updated_at" json:"updated_at"`
 }
 
 // ProductResponse 产品响应结构体
@@ -28,57 +41,202 @@ type ProductResponse struct {
 
 // DemoRESTful 展示RESTful API的设计与实现
 func DemoRESTful() {
+	// 加载配置：默认值 < config.yaml/config.toml < .env < 环境变量
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	// 没有配置管理令牌和JWT密钥时，为本次运行随机生成，进程退出后失效
+	if cfg.Server.AdminToken == "" {
+		cfg.Server.AdminToken = rand.Text()
+		fmt.Println("未配置 server.admin_token，本次运行的管理令牌: " + cfg.Server.AdminToken)
+	}
+	if cfg.Server.JWTSecret == "" {
+		cfg.Server.JWTSecret = rand.Text()
+		token, err := SignJWT(map[string]interface{}{"tenant": "default", "exp": time.Now().Add(24 * time.Hour).Unix()}, []byte(cfg.Server.JWTSecret))
+		if err != nil {
+			log.Fatalf("签发示例令牌失败: %v", err)
+		}
+		fmt.Println("未配置 server.jwt_secret，已随机生成密钥，default 租户的令牌(24小时有效): " + token)
+	}
+	if err := RunRESTful(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunRESTful 启动RESTful API服务器，直到 ctx 取消
+func RunRESTful(ctx context.Context, cfg *config.Config) error {
+	if err := cfg.Server.RequireSecrets(); err != nil {
+		return err
+	}
+
 	// 创建路由引擎
 	r := gin.Default()
 
-	// 添加自定义响应中间件
-	r.Use(ResponseMiddleware())
+	// 响应压缩：zstd 或 gzip，小于阈值的响应不压缩
+	r.Use(CompressionMiddleware(CompressionOptions{MinSize: cfg.Server.CompressionMinSize}))
 
-	// API版本控制
-	v1 := r.Group("/api/v1")
-	{
-		// 产品相关路由
-		products := v1.Group("/products")
-		{
-			products.GET("", ListProducts)          // 获取产品列表
-			products.GET("/:id", GetProduct)        // 获取单个产品
-			products.POST("", CreateProduct)        // 创建产品
-			products.PUT("/:id", UpdateProduct)     // 更新产品
-			products.DELETE("/:id", DeleteProduct)  // 删除产品
-			products.GET("/search", SearchProducts) // 搜索产品
+	// 租户：每个店铺的产品数据相互隔离
+	tenants, err := newDemoTenants()
+	if err != nil {
+		return fmt.Errorf("初始化租户失败: %w", err)
+	}
+	// 租户来自JWT的 tenant 声明，子域名和请求头必须与之一致
+	tenantMiddleware := TenantMiddleware(tenants,
+		TenantFromJWT([]byte(cfg.Server.JWTSecret), "tenant"),
+		TenantFromSubdomain("shop.localhost"),
+		TenantFromHeader(TenantHeader),
+	)
+
+	// 幂等中间件：相同 Idempotency-Key 的POST请求重放首次响应
+	// 注册在租户中间件之后以区分租户，在响应中间件之前才能记录完整的响应体
+	idempotencyCache := cache_persist.NewMemoryCache()
+	idempotencyCache.StartCleaner(time.Minute)
+	defer idempotencyCache.StopCleaner()
+	idempotency := IdempotencyMiddleware(NewMemoryIdempotencyStore(idempotencyCache), DefaultIdempotencyTTL)
+
+	// API版本控制：/api/v1、/api/v2 或 Accept: application/vnd.app.v2+json
+	// 未指定版本的 /api/... 请求默认路由到 v1，保持对老客户端的兼容
+	// 配置了 server.v1_sunset 时 v1 标记为弃用，响应带 Deprecation/Sunset 头
+	versions := NewVersionRouter(r, "v1")
+	v1Version := APIVersion{Name: "v1"}
+	if cfg.Server.V1Sunset != "" {
+		sunset, err := time.Parse(time.DateOnly, cfg.Server.V1Sunset)
+		if err != nil {
+			return fmt.Errorf("server.v1_sunset 无效: %w", err)
 		}
+		v1Version.Deprecated, v1Version.Sunset, v1Version.Successor = true, sunset, "v2"
+	}
+	v1 := versions.Group(v1Version)
+	v1.Use(tenantMiddleware, idempotency, ResponseMiddleware())
+	registerProductRoutes(v1, ListProducts)
+
+	// v2 的产品列表实现了真正的分页，其余接口与 v1 相同
+	v2 := versions.Group(APIVersion{Name: "v2"})
+	v2.Use(tenantMiddleware, idempotency, ResponseMiddleware())
+	registerProductRoutes(v2, ListProductsV2)
+
+	// 监听选项：证书、mTLS和h2c
+	serverOptions, err := ServerOptionsFromConfig(cfg.Server)
+	if err != nil {
+		return fmt.Errorf("读取服务器配置失败: %w", err)
 	}
 
+	// 租户管理接口，配置了客户端CA时还要求客户端证书
+	admin := r.Group("/admin", AdminTokenMiddleware(cfg.Server.AdminToken), ResponseMiddleware())
+	if serverOptions.ClientCAFile != "" {
+		admin.Use(RequireClientCert())
+	}
+	RegisterTenantAdminRoutes(admin, tenants)
+
+	// 各版本调用统计
+	r.GET("/versions", versions.UsageHandler)
+	stopUsageLogger := make(chan struct{})
+	defer close(stopUsageLogger)
+	versions.StartUsageLogger(time.Hour, stopUsageLogger)
+
+	// GraphQL端点：产品及其所属用户、用户的文章可在一次请求中获取
+	graphqlDB, err := openGraphQLDemoDB()
+	if err != nil {
+		return fmt.Errorf("初始化GraphQL数据库失败: %w", err)
+	}
+	graphql := NewGraphQLHandler(graphqlDB)
+
+	// GraphQL 示例用户的回收站管理，超过保留时间的软删除记录由后台任务清理
+	trashUsers := database.NewGormUserRepository(graphqlDB)
+	RegisterTrashAdminRoutes(admin, trashUsers)
+	go database.NewPurgeJob(trashUsers, database.PurgeJobOptions{}).Run(ctx)
+	r.GET("/graphql", tenantMiddleware, graphql.Handle)
+	r.POST("/graphql", tenantMiddleware, graphql.Handle)
+	r.GET("/graphql/schema", GraphQLSchema)
+
 	// API文档路由
 	r.GET("/docs/*any", gin.WrapH(swaggerHandler()))
 
 	fmt.Println("=== RESTful API 示例 ===")
-	fmt.Println("服务器运行在 http://localhost:8080")
+	fmt.Println("服务器运行在 " + serverOptions.URL())
 	fmt.Println("\n可用的API端点：")
 	fmt.Println("1. GET    /api/v1/products     - 获取产品列表")
 	fmt.Println("2. GET    /api/v1/products/:id - 获取单个产品")
 	fmt.Println("3. POST   /api/v1/products     - 创建产品")
 	fmt.Println("4. PUT    /api/v1/products/:id - 更新产品")
 	fmt.Println("5. DELETE /api/v1/products/:id - 删除产品")
-	fmt.Println("6. GET    /api/v1/products/search?q=关键词 - 搜索产品")
-	fmt.Println("\n文档地址：http://localhost:8080/docs/")
+	fmt.Println("   PATCH  /api/v1/products/:id - 部分更新产品(JSON Merge Patch / JSON Patch)")
+	fmt.Println("6. GET    /api/v1/products/search?q=关键词&limit=10 - 搜索产品")
+	fmt.Println("7. GET    /api/v1/products/events  - 订阅产品变更(SSE)")
+	fmt.Println("8. GET    /api/v1/products/ws      - 订阅产品变更(WebSocket)")
+	fmt.Println("9. POST   /api/v1/products:import?format=csv|jsonl&dry_run=true - 批量导入产品")
+	fmt.Println("10. GET   /api/v1/products:export?format=csv|jsonl - 批量导出产品")
+	fmt.Println("11. POST  /graphql             - GraphQL查询(Schema见 GET /graphql/schema)")
+	fmt.Println("12. GET   /versions            - API版本及调用统计")
+	fmt.Println("\nv2 使用 /api/v2 或 Accept: application/vnd.app.v2+json")
+	if v1Version.Deprecated {
+		fmt.Println("v1 已弃用，计划于 " + cfg.Server.V1Sunset + " 下线(响应带 Deprecation/Sunset 头)")
+	}
+	fmt.Println("\n响应格式由 Accept 决定：application/json、application/xml 或 application/msgpack")
+	fmt.Println("响应按 Accept-Encoding 使用 zstd 或 gzip 压缩")
+	fmt.Println("\nPUT/PATCH/DELETE 必须携带 If-Match 请求头(取自 GET 返回的 ETag)")
+	fmt.Println("POST请求可携带 Idempotency-Key 请求头，重试时不会重复创建")
+	fmt.Println("\n产品接口按租户隔离：租户由 Authorization: Bearer <JWT> 中的 tenant 声明决定")
+	fmt.Println("使用 acme.shop.localhost 子域名或 X-Tenant-ID 请求头时必须与令牌中的租户一致")
+	fmt.Println("租户管理：GET/POST /admin/tenants，POST /admin/tenants/:tenant/suspend|resume")
+	fmt.Println("回收站管理：GET /admin/trash/users，POST /admin/trash/users/:id/restore，DELETE /admin/trash/users/:id")
+	fmt.Println("         (Authorization: Bearer <server.admin_token>)")
+	fmt.Println("\n文档地址：" + serverOptions.URL() + "/docs/")
+
+	return Serve(ctx, serverOptions, versions)
+}
+
+// registerProductRoutes 注册某个API版本下的产品路由
+func registerProductRoutes(v *gin.RouterGroup, list gin.HandlerFunc) {
+	// 产品相关路由
+	products := v.Group("/products")
+	{
+		products.GET("", list)                      // 获取产品列表
+		products.GET("/:id", GetProduct)            // 获取单个产品
+		products.POST("", CreateProduct)            // 创建产品
+		products.PUT("/:id", UpdateProduct)         // 更新产品
+		products.PATCH("/:id", PatchProduct)        // 部分更新产品
+		products.DELETE("/:id", DeleteProduct)      // 删除产品
+		products.GET("/search", SearchProducts)     // 搜索产品
+		products.GET("/events", ProductEventsSSE)   // 产品变更事件流(SSE)
+		products.GET("/ws", ProductEventsWebSocket) // 产品变更事件流(WebSocket)
+	}
 
-	r.Run(":8080")
+	// 批量导入导出：/products:import 和 /products:export
+	v.POST("/products:action", ProductActionPOST)
+	v.GET("/products:action", ProductActionGET)
+}
+
+// newDemoTenants 创建示例租户 default 和 acme，default 带有示例产品
+func newDemoTenants() (*TenantRegistry, error) {
+	registry := NewTenantRegistry()
+	defaultTenant, err := registry.Create(Tenant{ID: "default", Name: "默认店铺", RateLimit: 50})
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range demoProducts {
+		if _, err := defaultTenant.Products().Add(product); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := registry.Create(Tenant{ID: "acme", Name: "Acme", RateLimit: 10, MaxProducts: 100}); err != nil {
+		return nil, err
+	}
+	return registry, nil
 }
 
-// 模拟数据库
-var products = []Product{
+// demoProducts 默认租户的示例数据
+var demoProducts = []Product{
 	{
-		ID:          1,
 		Name:        "Go编程实战",
 		Description: "深入学习Go语言的实践指南",
 		Price:       99.00,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		OwnerID:     1,
 	},
 }
 
-// ResponseMiddleware 统一响应格式中间件
+// ResponseMiddleware 统一响应格式中间件，按 Accept 头输出JSON、XML或MessagePack
 func ResponseMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
@@ -101,7 +259,7 @@ func ResponseMiddleware() gin.HandlerFunc {
 			Data:    data,
 		}
 
-		c.JSON(c.Writer.Status(), response)
+		Negotiate(c, c.Writer.Status(), response)
 	}
 }
 
@@ -113,6 +271,7 @@ func ListProducts(c *gin.Context) {
 
 	// TODO: 实现分页逻辑
 
+	products := tenantProducts(c).List()
 	c.Set("data", gin.H{
 		"products": products,
 		"pagination": gin.H{
@@ -123,89 +282,211 @@ func ListProducts(c *gin.Context) {
 	})
 }
 
+// ListProductsV2 获取产品列表(v2)，按 page/limit 分页
+func ListProductsV2(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "page参数无效"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit参数无效，范围为1-100"})
+		return
+	}
+
+	products := tenantProducts(c).List()
+	start := min((page-1)*limit, len(products))
+	end := min(start+limit, len(products))
+	c.Set("data", gin.H{
+		"items": products[start:end],
+		"page":  page,
+		"limit": limit,
+		"total": len(products),
+	})
+}
+
 // GetProduct 获取单个产品
 func GetProduct(c *gin.Context) {
-	id := c.Param("id")
-	for _, product := range products {
-		if fmt.Sprint(product.ID) == id {
-			c.Set("data", product)
-			return
+	product, ok := productParam(c)
+	if !ok {
+		return
+	}
+	etag := productETag(product)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Set("data", product)
+}
+
+// productParam 按路径中的ID读取当前租户的产品，不存在时响应404
+func productParam(c *gin.Context) (Product, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err == nil {
+		if product, ok := tenantProducts(c).Get(uint(id)); ok {
+			return product, true
 		}
 	}
 	c.Status(http.StatusNotFound)
+	return Product{}, false
 }
 
 // CreateProduct 创建产品
 func CreateProduct(c *gin.Context) {
-	var product Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	product, ok := bindProduct(c, 0)
+	if !ok {
 		return
 	}
 
-	// 设置创建时间和更新时间
-	product.ID = uint(len(products) + 1)
-	product.CreatedAt = time.Now()
-	product.UpdatedAt = time.Now()
-
-	products = append(products, product)
+	product, err := tenantProducts(c).Add(product)
+	switch {
+	case errors.Is(err, ErrDuplicateProductName):
+		abortDuplicateName(c, product.Name)
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", productETag(product))
 	c.Status(http.StatusCreated)
 	c.Set("data", product)
 }
 
 // UpdateProduct 更新产品
 func UpdateProduct(c *gin.Context) {
-	id := c.Param("id")
+	p, ok := productParam(c)
+	if !ok || !checkIfMatch(c, p) {
+		return
+	}
+	product, ok := bindProduct(c, p.ID)
+	if !ok {
+		return
+	}
+	check := ifMatchPrecondition(c)
+	saveProduct(c, p.ID, func(current Product) (Product, error) {
+		return product, check(current)
+	})
+}
+
+// PatchProduct 部分更新产品
+// Content-Type 为 application/json-patch+json 时按 JSON Patch 处理，否则按 JSON Merge Patch 处理
+func PatchProduct(c *gin.Context) {
+	p, ok := productParam(c)
+	if !ok || !checkIfMatch(c, p) {
+		return
+	}
+
+	// 将当前产品转为通用JSON文档后应用补丁
+	var doc interface{}
+	data, _ := json.Marshal(p)
+	json.Unmarshal(data, &doc)
+
+	var err error
+	switch c.ContentType() {
+	case JSONPatchContentType:
+		var ops []PatchOperation
+		if err = c.ShouldBindJSON(&ops); err == nil {
+			doc, err = ApplyJSONPatch(doc, ops)
+		}
+	case MergePatchContentType, "application/json":
+		var patch interface{}
+		if err = c.ShouldBindJSON(&patch); err == nil {
+			doc = MergePatch(doc, patch)
+		}
+	default:
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "仅支持 " + MergePatchContentType + " 和 " + JSONPatchContentType,
+		})
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrPatchTestFailed) {
+			status = http.StatusConflict
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 补丁结果需满足与创建时相同的校验规则
 	var product Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if data, err = json.Marshal(doc); err == nil {
+		err = json.Unmarshal(data, &product)
+	}
+	if err == nil {
+		err = validateProduct(c.Request.Context(), &product)
+	}
+	if err != nil {
+		abortWithValidationError(c, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	for i, p := range products {
-		if fmt.Sprint(p.ID) == id {
-			product.ID = p.ID
-			product.CreatedAt = p.CreatedAt
-			product.UpdatedAt = time.Now()
-			products[i] = product
-			c.Set("data", product)
-			return
+	// 补丁基于读取时的版本计算，期间产品被修改过就不能再写入
+	saveProduct(c, p.ID, func(current Product) (Product, error) {
+		if current.Version != p.Version {
+			return current, ErrVersionConflict
 		}
+		return product, nil
+	})
+}
+
+// saveProduct 在存储的锁内用 update 修改产品并返回新的ETag
+// update 返回 ErrVersionConflict 时响应412，新名称已被使用时响应422
+func saveProduct(c *gin.Context, id uint, update func(current Product) (Product, error)) {
+	var name string
+	product, err := tenantProducts(c).Update(id, func(current Product) (Product, error) {
+		product, err := update(current)
+		name = product.Name
+		return product, err
+	})
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		abortPreconditionFailed(c, product)
+	case errors.Is(err, ErrDuplicateProductName):
+		abortDuplicateName(c, name)
+	case err != nil:
+		c.Status(http.StatusNotFound)
+	default:
+		c.Header("ETag", productETag(product))
+		c.Set("data", product)
 	}
-	c.Status(http.StatusNotFound)
 }
 
 // DeleteProduct 删除产品
 func DeleteProduct(c *gin.Context) {
-	id := c.Param("id")
-	for i, product := range products {
-		if fmt.Sprint(product.ID) == id {
-			products = append(products[:i], products[i+1:]...)
-			c.Status(http.StatusOK)
-			return
-		}
+	product, ok := productParam(c)
+	if !ok || !checkIfMatch(c, product) {
+		return
+	}
+	current, err := tenantProducts(c).Delete(product.ID, ifMatchPrecondition(c))
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		abortPreconditionFailed(c, current)
+	case err != nil:
+		c.Status(http.StatusNotFound)
+	default:
+		c.Status(http.StatusOK)
 	}
-	c.Status(http.StatusNotFound)
 }
 
 // SearchProducts 搜索产品
 func SearchProducts(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
-		c.Set("data", products)
+		c.Set("data", tenantProducts(c).List())
 		return
 	}
 
-	// 简单的搜索实现
-	var results []Product
-	for _, product := range products {
-		if strings.Contains(strings.ToLower(product.Name), strings.ToLower(query)) ||
-			strings.Contains(strings.ToLower(product.Description), strings.ToLower(query)) {
-			results = append(results, product)
-		}
+	// 倒排索引检索，按BM25相关度排序并返回高亮片段
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit参数无效"})
+		return
 	}
 
-	c.Set("data", results)
+	c.Set("data", tenantProducts(c).Search(query, limit))
 }
 
 // swaggerHandler 返回Swagger UI处理器
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantStatus 租户状态
+type TenantStatus string
+
+const (
+	TenantActive    TenantStatus = "active"    // 正常
+	TenantSuspended TenantStatus = "suspended" // 已停用，所有请求返回403
+)
+
+var (
+	ErrTenantNotFound  = errors.New("租户不存在")
+	ErrTenantExists    = errors.New("租户已存在")
+	ErrInvalidTenantID = errors.New("租户ID只能包含小写字母、数字和中划线，长度为2-32")
+)
+
+// tenantIDPattern 租户ID同时用作子域名，按DNS标签的规则限制
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}[a-z0-9]$`)
+
+// Tenant 租户，即部署中托管的一个店铺
+type Tenant struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Status      TenantStatus `json:"status"`
+	RateLimit   float64      `json:"rate_limit"`   // 每秒请求数，0表示不限制
+	Burst       int          `json:"burst"`        // 允许的突发请求数
+	MaxProducts int          `json:"max_products"` // 产品数量上限，0表示不限制
+	CreatedAt   time.Time    `json:"created_at"`
+
+	store   *ProductStore
+	limiter *tokenBucket
+}
+
+// Products 返回租户的产品存储
+func (t Tenant) Products() *ProductStore {
+	return t.store
+}
+
+// TenantRegistry 租户注册表，保存租户配置及其数据
+type TenantRegistry struct {
+	mutex   sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewTenantRegistry 创建空的租户注册表
+func NewTenantRegistry() *TenantRegistry {
+	return &TenantRegistry{tenants: make(map[string]*Tenant)}
+}
+
+// Create 创建租户，同时为其分配独立的产品存储和限流器
+func (r *TenantRegistry) Create(tenant Tenant) (Tenant, error) {
+	if !tenantIDPattern.MatchString(tenant.ID) {
+		return Tenant{}, ErrInvalidTenantID
+	}
+	if tenant.Burst <= 0 {
+		tenant.Burst = int(math.Ceil(tenant.RateLimit))
+	}
+	tenant.Status = TenantActive
+	tenant.CreatedAt = time.Now()
+	tenant.store = NewProductStore(tenant.MaxProducts)
+	tenant.limiter = newTokenBucket(tenant.RateLimit, tenant.Burst)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.tenants[tenant.ID]; exists {
+		return Tenant{}, ErrTenantExists
+	}
+	r.tenants[tenant.ID] = &tenant
+	return tenant, nil
+}
+
+// Get 返回租户的快照
+func (r *TenantRegistry) Get(id string) (Tenant, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrTenantNotFound
+	}
+	return *tenant, nil
+}
+
+// List 按ID排序返回所有租户
+func (r *TenantRegistry) List() []Tenant {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, *tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// SetStatus 停用或恢复租户，数据会保留
+func (r *TenantRegistry) SetStatus(id string, status TenantStatus) (Tenant, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrTenantNotFound
+	}
+	tenant.Status = status
+	return *tenant, nil
+}
+
+// tokenBucket 令牌桶限流器
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // 每秒补充的令牌数，0表示不限制
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Allow 尝试取一个令牌，失败时返回需要等待的时间
+func (b *tokenBucket) Allow() (bool, time.Duration) {
+	if b.rate <= 0 {
+		return true, 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// tenantContextKey 租户在 context 中的键
+type tenantContextKey struct{}
+
+// WithTenant 将租户放入 context，供GraphQL等不直接使用gin.Context的代码读取
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext 读取当前请求的租户
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(Tenant)
+	return tenant, ok
+}
+
+// tenantProducts 返回当前请求所属租户的产品存储
+// 产品接口都注册在 TenantMiddleware 之后，缺少租户说明路由配置有误
+func tenantProducts(c *gin.Context) *ProductStore {
+	tenant, ok := TenantFromContext(c.Request.Context())
+	if !ok {
+		panic("server: 产品接口缺少 TenantMiddleware")
+	}
+	return tenant.store
+}
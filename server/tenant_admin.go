@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenMiddleware 校验管理接口的 Authorization: Bearer <token>
+func AdminTokenMiddleware(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RegisterTenantAdminRoutes 注册租户管理接口
+func RegisterTenantAdminRoutes(group *gin.RouterGroup, registry *TenantRegistry) {
+	tenants := group.Group("/tenants")
+	{
+		tenants.GET("", func(c *gin.Context) {
+			c.Set("data", registry.List())
+		})
+		tenants.POST("", func(c *gin.Context) {
+			var req struct {
+				ID          string  `json:"id" binding:"required"`
+				Name        string  `json:"name" binding:"required"`
+				RateLimit   float64 `json:"rate_limit" binding:"gte=0"`
+				Burst       int     `json:"burst" binding:"gte=0"`
+				MaxProducts int     `json:"max_products" binding:"gte=0"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				abortWithValidationError(c, http.StatusBadRequest, err)
+				return
+			}
+			tenant, err := registry.Create(Tenant{
+				ID:          req.ID,
+				Name:        req.Name,
+				RateLimit:   req.RateLimit,
+				Burst:       req.Burst,
+				MaxProducts: req.MaxProducts,
+			})
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, ErrTenantExists) {
+					status = http.StatusConflict
+				}
+				c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			c.Status(http.StatusCreated)
+			c.Set("data", tenant)
+		})
+		tenants.GET("/:tenant", func(c *gin.Context) {
+			tenant, err := registry.Get(c.Param("tenant"))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.Set("data", tenant)
+		})
+		tenants.POST("/:tenant/suspend", setTenantStatus(registry, TenantSuspended))
+		tenants.POST("/:tenant/resume", setTenantStatus(registry, TenantActive))
+	}
+}
+
+// setTenantStatus 停用或恢复租户
+func setTenantStatus(registry *TenantRegistry, status TenantStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := registry.SetStatus(c.Param("tenant"), status)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("data", tenant)
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader 通过请求头指定租户，只能用于选择令牌所属的租户，不能单独决定租户
+const TenantHeader = "X-Tenant-ID"
+
+// TenantResolver 从请求中解析租户ID，请求未携带该来源的租户信息时返回空字符串
+type TenantResolver func(c *gin.Context) (string, error)
+
+// TenantFromHeader 从请求头解析租户
+func TenantFromHeader(name string) TenantResolver {
+	return func(c *gin.Context) (string, error) {
+		return strings.TrimSpace(c.GetHeader(name)), nil
+	}
+}
+
+// TenantFromSubdomain 从子域名解析租户，如 baseDomain 为 shop.example.com 时
+// acme.shop.example.com 解析为 acme
+func TenantFromSubdomain(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(baseDomain)
+	return func(c *gin.Context) (string, error) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		sub, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || strings.Contains(sub, ".") {
+			return "", nil
+		}
+		return sub, nil
+	}
+}
+
+// TenantFromJWT 从 Authorization: Bearer <token> 的JWT声明中解析租户
+// 仅支持HS256签名；携带了令牌但签名或有效期不通过时返回错误。
+// 验证通过后把调用方身份放入 gin 上下文的 "subject"：sub 声明，没有时为令牌的摘要
+func TenantFromJWT(secret []byte, claim string) TenantResolver {
+	return func(c *gin.Context) (string, error) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			return "", nil
+		}
+		claims, err := ParseJWT(token, secret)
+		if err != nil {
+			return "", err
+		}
+		id, _ := claims[claim].(string)
+		if id == "" {
+			return "", fmt.Errorf("令牌缺少 %s 声明", claim)
+		}
+		subject, _ := claims["sub"].(string)
+		if subject == "" {
+			sum := sha256.Sum256([]byte(token))
+			subject = "token:" + hex.EncodeToString(sum[:16])
+		}
+		c.Set("subject", subject)
+		return id, nil
+	}
+}
+
+var jwtHeaderHS256 = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignJWT 使用HS256签发JWT
+func SignJWT(claims map[string]interface{}, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeaderHS256 + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + jwtSignature(unsigned, secret), nil
+}
+
+// ParseJWT 校验HS256签名及 exp/nbf 后返回声明
+func ParseJWT(token string, secret []byte) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("令牌格式错误")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	// 固定算法，防止 alg=none 之类的降级攻击
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("不支持的签名算法 %q", header.Alg)
+	}
+	expected := jwtSignature(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, errors.New("令牌签名无效")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return nil, errors.New("令牌已过期")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("令牌尚未生效")
+	}
+	return claims, nil
+}
+
+func jwtSignature(unsigned string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("令牌格式错误")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("令牌格式错误")
+	}
+	return nil
+}
+
+// TenantMiddleware 解析租户并放入请求上下文，同时执行租户的限流
+// 租户只由 authenticate 从已验证的凭据(如JWT声明)中确定；子域名、请求头等 hints
+// 客户端可以任意设置，只用于校验：与凭据中的租户不一致时拒绝请求
+func TenantMiddleware(registry *TenantRegistry, authenticate TenantResolver, hints ...TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := authenticate(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if id == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "缺少认证令牌，无法确定租户"})
+			return
+		}
+		for _, resolve := range hints {
+			hint, err := resolve(c)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if hint != "" && hint != id {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "请求中的租户与令牌不一致"})
+				return
+			}
+		}
+
+		tenant, err := registry.Get(id)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if tenant.Status == TenantSuspended {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "租户已停用"})
+			return
+		}
+		if ok, wait := tenant.limiter.Allow(); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁"})
+			return
+		}
+
+		c.Set("tenant", tenant.ID)
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
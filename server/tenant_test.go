@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alphaSecret alpha 租户的产品名，任何其他租户的响应中都不应出现
+const alphaSecret = "Alpha机密产品"
+
+// tenantTestServer 注册了 alpha、beta、gamma 三个租户的产品和GraphQL接口
+type tenantTestServer struct {
+	t        *testing.T
+	router   *gin.Engine
+	registry *TenantRegistry
+}
+
+func newTenantTestServer(t *testing.T) *tenantTestServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	registry := NewTenantRegistry()
+	for _, tenant := range []Tenant{
+		{ID: "alpha", Name: "Alpha"},
+		{ID: "beta", Name: "Beta", MaxProducts: 1},
+		{ID: "gamma", Name: "Gamma", RateLimit: 1, Burst: 2},
+	} {
+		if _, err := registry.Create(tenant); err != nil {
+			t.Fatalf("创建租户失败: %v", err)
+		}
+	}
+	db, err := openGraphQLDemoDB()
+	if err != nil {
+		t.Fatalf("初始化GraphQL数据库失败: %v", err)
+	}
+
+	tenantMiddleware := TenantMiddleware(registry,
+		TenantFromJWT(testJWTSecret, "tenant"),
+		TenantFromSubdomain("shop.localhost"),
+		TenantFromHeader(TenantHeader),
+	)
+	r := gin.New()
+	registerProductRoutes(r.Group("/api", tenantMiddleware, ResponseMiddleware()), ListProductsV2)
+	r.POST("/graphql", tenantMiddleware, NewGraphQLHandler(db).Handle)
+	return &tenantTestServer{t: t, router: r, registry: registry}
+}
+
+var testJWTSecret = []byte("tenant-test-secret")
+
+// tenantToken 签发 tenant 的令牌
+func (s *tenantTestServer) tenantToken(tenant string, secret []byte) string {
+	s.t.Helper()
+	token, err := SignJWT(map[string]interface{}{"tenant": tenant, "exp": time.Now().Add(time.Hour).Unix()}, secret)
+	if err != nil {
+		s.t.Fatalf("签发令牌失败: %v", err)
+	}
+	return "Bearer " + token
+}
+
+// do 以 tenant 的令牌发送请求，header 为成对的请求头名和值，可以覆盖令牌
+func (s *tenantTestServer) do(method, target, tenant, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if tenant != "" {
+		req.Header.Set("Authorization", s.tenantToken(tenant, testJWTSecret))
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect 检查响应状态码
+func (s *tenantTestServer) expect(name string, w *httptest.ResponseRecorder, status int) {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Errorf("%s: 期望状态码 %d，实际 %d: %s", name, status, w.Code, w.Body.String())
+	}
+}
+
+// graphql 以 tenant 的身份执行GraphQL请求
+func (s *tenantTestServer) graphql(tenant, query string) string {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	return s.do(http.MethodPost, "/graphql", tenant, string(body)).Body.String()
+}
+
+// createAlphaProduct alpha 创建名称以 alphaSecret 开头的产品，返回产品ID
+func (s *tenantTestServer) createAlphaProduct(suffix string) uint {
+	s.t.Helper()
+	w := s.do(http.MethodPost, "/api/products", "alpha", `{"name":"`+alphaSecret+suffix+`","description":"仅alpha可见","price":10}`)
+	var resp struct{ Data Product }
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		s.t.Fatalf("alpha 创建产品失败: %d %s", w.Code, w.Body.String())
+	}
+	return resp.Data.ID
+}
+
+func TestTenantIsolationREST(t *testing.T) {
+	s := newTenantTestServer(t)
+	s.createAlphaProduct("")
+
+	s.expect("beta 读取同ID产品", s.do(http.MethodGet, "/api/products/1", "beta", ""), http.StatusNotFound)
+	s.expect("beta 修改同ID产品", s.do(http.MethodPut, "/api/products/1", "beta", `{"name":"篡改","price":1}`, "If-Match", "*"), http.StatusNotFound)
+	s.expect("beta 部分修改同ID产品", s.do(http.MethodPatch, "/api/products/1", "beta", `{"name":"篡改"}`, "If-Match", "*"), http.StatusNotFound)
+	s.expect("beta 删除同ID产品", s.do(http.MethodDelete, "/api/products/1", "beta", "", "If-Match", "*"), http.StatusNotFound)
+
+	for name, target := range map[string]string{
+		"列表":    "/api/products",
+		"搜索":    "/api/products/search?q=机密",
+		"空搜索":   "/api/products/search",
+		"导出":    "/api/products:export?format=jsonl",
+		"CSV导出": "/api/products:export?format=csv",
+	} {
+		if body := s.do(http.MethodGet, target, "beta", "").Body.String(); strings.Contains(body, alphaSecret) {
+			t.Errorf("beta 通过%s读到了 alpha 的数据: %s", name, body)
+		}
+	}
+
+	// beta 的写操作没有影响 alpha 的产品
+	w := s.do(http.MethodGet, "/api/products/1", "alpha", "")
+	s.expect("alpha 读取自己的产品", w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), alphaSecret) {
+		t.Errorf("alpha 的产品被修改: %s", w.Body.String())
+	}
+
+	// 名称唯一性只在租户内检查
+	product := `{"name":"` + alphaSecret + `","price":10}`
+	s.expect("beta 创建同名产品", s.do(http.MethodPost, "/api/products", "beta", product), http.StatusCreated)
+	s.expect("alpha 再次创建同名产品", s.do(http.MethodPost, "/api/products", "alpha", product), http.StatusUnprocessableEntity)
+}
+
+func TestTenantIsolationGraphQL(t *testing.T) {
+	s := newTenantTestServer(t)
+	s.createAlphaProduct("")
+
+	var resp struct {
+		Data struct {
+			Products struct{ TotalCount int }
+			Product  *struct{ Name string }
+		}
+	}
+	body := s.graphql("beta", `{ products { totalCount } product(id: 1) { name } }`)
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("解析GraphQL响应失败: %v: %s", err, body)
+	}
+	if resp.Data.Products.TotalCount != 0 || resp.Data.Product != nil {
+		t.Errorf("beta 通过GraphQL读到了 alpha 的产品: %s", body)
+	}
+
+	for _, mutation := range []string{
+		`mutation { updateProduct(id: 1, input: {name: "篡改"}) { id } }`,
+		`mutation { deleteProduct(id: 1) }`,
+	} {
+		if body := s.graphql("beta", mutation); !strings.Contains(body, "不存在") {
+			t.Errorf("beta 修改了 alpha 的产品: %s", body)
+		}
+	}
+	if body := s.graphql("alpha", `{ product(id: 1) { name } }`); !strings.Contains(body, alphaSecret) {
+		t.Errorf("alpha 的产品被修改: %s", body)
+	}
+
+	// 用户和文章同样只对所属租户可见
+	body = s.graphql("alpha", `mutation { createUser(input: {username: "`+alphaSecret+`", email: "alpha@example.com"}) { id } }`)
+	var created struct {
+		Data struct{ CreateUser struct{ ID string } }
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil || created.Data.CreateUser.ID == "" {
+		t.Fatalf("alpha 创建用户失败: %s", body)
+	}
+	userID := created.Data.CreateUser.ID
+	if body := s.graphql("alpha", `mutation { createPost(input: {title: "`+alphaSecret+`", userId: `+userID+`}) { id } }`); strings.Contains(body, "errors") {
+		t.Fatalf("alpha 创建文章失败: %s", body)
+	}
+
+	for name, query := range map[string]string{
+		"用户列表":   `{ users { totalCount edges { node { username } } } }`,
+		"按ID查用户": `{ user(id: ` + userID + `) { username posts { title } } }`,
+		"按ID查文章": `{ post(id: 3) { title } }`,
+		"默认租户用户": `{ user(id: 1) { username } }`,
+	} {
+		body := s.graphql("beta", query)
+		if strings.Contains(body, alphaSecret) || strings.Contains(body, "gopher") {
+			t.Errorf("beta 通过%s读到了其他租户的数据: %s", name, body)
+		}
+	}
+	if body := s.graphql("beta", `{ users { totalCount } }`); !strings.Contains(body, `"totalCount":0`) {
+		t.Errorf("beta 的用户数应为0: %s", body)
+	}
+	if body := s.graphql("beta", `mutation { createPost(input: {title: "篡改", userId: `+userID+`}) { id } }`); !strings.Contains(body, "不存在") {
+		t.Errorf("beta 给 alpha 的用户发了文章: %s", body)
+	}
+	body = s.graphql("alpha", `{ users { totalCount } user(id: `+userID+`) { posts { title } } }`)
+	if !strings.Contains(body, `"totalCount":1`) || !strings.Contains(body, alphaSecret) {
+		t.Errorf("alpha 应只看到自己的用户和文章: %s", body)
+	}
+}
+
+func TestTenantIsolationSSE(t *testing.T) {
+	s := newTenantTestServer(t)
+	s.createAlphaProduct("")
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	s.createAlphaProduct("2")
+	s.expect("beta 创建产品", s.do(http.MethodPost, "/api/products", "beta", `{"name":"Beta产品","price":1}`), http.StatusCreated)
+
+	// 从头回放 beta 的事件
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/products/events", nil)
+	req.Header.Set("Authorization", s.tenantToken("beta", testJWTSecret))
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("订阅事件失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("订阅事件失败: %d", resp.StatusCode)
+	}
+
+	// beta 收到的第一个事件就是自己的产品
+	done := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				done <- data
+				return
+			}
+		}
+		done <- ""
+	}()
+	select {
+	case data := <-done:
+		if strings.Contains(data, alphaSecret) || !strings.Contains(data, "Beta产品") {
+			t.Errorf("beta 收到了不属于自己的事件: %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待事件超时")
+	}
+}
+
+func TestTenantResolution(t *testing.T) {
+	s := newTenantTestServer(t)
+	s.createAlphaProduct("")
+	alphaToken := s.tenantToken("alpha", testJWTSecret)
+
+	tests := []struct {
+		name   string
+		host   string
+		header []string
+		status int
+	}{
+		{"通过JWT访问 alpha", "", []string{"Authorization", alphaToken}, http.StatusOK},
+		{"JWT与请求头一致", "", []string{"Authorization", alphaToken, TenantHeader, "alpha"}, http.StatusOK},
+		{"JWT与子域名一致", "alpha.shop.localhost:8080", []string{"Authorization", alphaToken}, http.StatusOK},
+		{"JWT为alpha但请求头为beta", "", []string{"Authorization", alphaToken, TenantHeader, "beta"}, http.StatusForbidden},
+		{"JWT为alpha但子域名为beta", "beta.shop.localhost", []string{"Authorization", alphaToken}, http.StatusForbidden},
+		{"只有请求头", "", []string{TenantHeader, "alpha"}, http.StatusUnauthorized},
+		{"只有子域名", "alpha.shop.localhost", nil, http.StatusUnauthorized},
+		{"伪造的JWT", "", []string{"Authorization", s.tenantToken("alpha", []byte("wrong-secret")), TenantHeader, "alpha"}, http.StatusUnauthorized},
+		{"未指定租户", "", nil, http.StatusUnauthorized},
+		{"不存在的租户", "", []string{"Authorization", s.tenantToken("nobody", testJWTSecret)}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/products/1", nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		for i := 0; i+1 < len(tt.header); i += 2 {
+			req.Header.Set(tt.header[i], tt.header[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.expect(tt.name, w, tt.status)
+	}
+}
+
+func TestTenantLimits(t *testing.T) {
+	s := newTenantTestServer(t)
+
+	s.expect("beta 创建产品", s.do(http.MethodPost, "/api/products", "beta", `{"name":"第一个","price":1}`), http.StatusCreated)
+	s.expect("beta 超出产品配额", s.do(http.MethodPost, "/api/products", "beta", `{"name":"第二个","price":1}`), http.StatusForbidden)
+
+	s.expect("gamma 第1个请求", s.do(http.MethodGet, "/api/products", "gamma", ""), http.StatusOK)
+	s.expect("gamma 第2个请求", s.do(http.MethodGet, "/api/products", "gamma", ""), http.StatusOK)
+	s.expect("gamma 超出限流", s.do(http.MethodGet, "/api/products", "gamma", ""), http.StatusTooManyRequests)
+
+	if _, err := s.registry.SetStatus("alpha", TenantSuspended); err != nil {
+		t.Fatalf("停用租户失败: %v", err)
+	}
+	s.expect("停用后访问 alpha", s.do(http.MethodGet, "/api/products", "alpha", ""), http.StatusForbidden)
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-basics/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServerOptions 服务器监听选项
+// 未设置证书时使用明文HTTP；设置证书后启用TLS，HTTP/2 通过ALPN自动协商
+type ServerOptions struct {
+	Addr           string        // 监听地址，如 :8080
+	CertFile       string        // 证书文件(PEM)
+	KeyFile        string        // 私钥文件(PEM)
+	ClientCAFile   string        // 客户端证书的CA(PEM)，设置后启用mTLS校验
+	H2C            bool          // 明文时是否允许 HTTP/2 cleartext，用于内部流量
+	ReloadInterval time.Duration // 检查证书文件变化的间隔，默认10秒
+}
+
+// TLSEnabled 是否配置了证书
+func (o ServerOptions) TLSEnabled() bool {
+	return o.CertFile != "" && o.KeyFile != ""
+}
+
+// ServerOptionsFromConfig 根据配置生成监听选项
+// 开启 tls_self_signed 且未指定证书时，生成自签名证书到临时目录
+func ServerOptionsFromConfig(cfg config.ServerConfig) (ServerOptions, error) {
+	opts := ServerOptions{
+		Addr:         cfg.Addr,
+		CertFile:     cfg.TLSCert,
+		KeyFile:      cfg.TLSKey,
+		ClientCAFile: cfg.ClientCA,
+		H2C:          cfg.H2C,
+	}
+
+	if cfg.TLSSelfSigned && !opts.TLSEnabled() {
+		dir, err := os.MkdirTemp("", "go-basics-tls")
+		if err != nil {
+			return opts, err
+		}
+		opts.CertFile = filepath.Join(dir, "cert.pem")
+		opts.KeyFile = filepath.Join(dir, "key.pem")
+		if err := WriteSelfSignedCert(opts.CertFile, opts.KeyFile, []string{"localhost", "127.0.0.1", "::1"}, 365*24*time.Hour); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// URL 返回用于提示的访问地址
+func (o ServerOptions) URL() string {
+	scheme := "http"
+	if o.TLSEnabled() {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(o.Addr)
+	if err != nil {
+		return scheme + "://" + o.Addr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// NewServer 按选项创建 http.Server，返回的 stop 用于停止证书监视
+func NewServer(opts ServerOptions, handler http.Handler) (*http.Server, func(), error) {
+	server := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := func() {}
+
+	if !opts.TLSEnabled() {
+		if opts.ClientCAFile != "" {
+			return nil, stop, errors.New("启用客户端证书校验必须同时配置服务器证书")
+		}
+		if opts.H2C {
+			server.Protocols = new(http.Protocols)
+			server.Protocols.SetHTTP1(true)
+			server.Protocols.SetUnencryptedHTTP2(true)
+		}
+		return server, stop, nil
+	}
+
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, stop, err
+	}
+	interval := opts.ReloadInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	stopCh := make(chan struct{})
+	reloader.Watch(interval, stopCh)
+	stop = sync.OnceFunc(func() { close(stopCh) })
+
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if opts.ClientCAFile != "" {
+		pool, err := loadCertPool(opts.ClientCAFile)
+		if err != nil {
+			stop()
+			return nil, func() {}, err
+		}
+		// 只校验客户端提供的证书，是否必须提供由 RequireClientCert 按路由决定
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return server, stop, nil
+}
+
+// ListenAndServe 按选项启动服务器，证书变更后自动加载，无需重启
+func ListenAndServe(opts ServerOptions, handler http.Handler) error {
+	return Serve(context.Background(), opts, handler)
+}
+
+// Serve 与 ListenAndServe 相同，ctx 取消时优雅关闭服务器并返回 nil
+func Serve(ctx context.Context, opts ServerOptions, handler http.Handler) error {
+	server, stop, err := NewServer(opts, handler)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			errCh <- server.ListenAndServeTLS("", "")
+		} else {
+			errCh <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// loadCertPool 读取PEM格式的CA证书
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s 中没有有效的证书", file)
+	}
+	return pool, nil
+}
+
+// RequireClientCert 要求请求携带已通过校验的客户端证书，用于内部路由
+func RequireClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要有效的客户端证书"})
+			return
+		}
+		c.Set("client_cert", c.Request.TLS.VerifiedChains[0][0].Subject.CommonName)
+		c.Next()
+	}
+}
+
+// CertReloader 在证书或私钥文件变化时重新加载，握手时总是使用最新证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewCertReloader 加载证书，文件无效时返回错误
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取证书和私钥
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime 返回证书和私钥中较晚的修改时间
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Watch 定期检查文件修改时间，变化时重新加载
+// 证书和私钥可能不是同时写入的，加载失败时保留旧证书，下次检查再重试
+func (r *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				modTime, err := r.latestModTime()
+				if err != nil {
+					log.Printf("[TLS] 检查证书失败: %v", err)
+					continue
+				}
+				r.mutex.RLock()
+				changed := !modTime.Equal(r.modTime)
+				r.mutex.RUnlock()
+				if !changed {
+					continue
+				}
+				if err := r.Reload(); err != nil {
+					log.Printf("[TLS] 重新加载证书失败，继续使用旧证书: %v", err)
+					continue
+				}
+				log.Printf("[TLS] 已重新加载证书 %s", r.certFile)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// GetCertificate 实现 tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// GenerateSelfSignedCert 生成自签名证书和私钥(PEM)，hosts 可以是域名或IP
+// 证书同时可用于服务端和客户端认证，并可作为CA，便于本地测试mTLS
+func GenerateSelfSignedCert(hosts []string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commonName := "go-basics"
+	if len(hosts) > 0 {
+		commonName = hosts[0]
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"go-basics"}},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// WriteSelfSignedCert 生成自签名证书并写入文件，私钥文件权限为0600
+func WriteSelfSignedCert(certFile, keyFile string, hosts []string, validFor time.Duration) error {
+	certPEM, keyPEM, err := GenerateSelfSignedCert(hosts, validFor)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, certPEM, 0o644)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-basics/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterTrashAdminRoutes 注册回收站管理接口：列出、恢复和彻底删除软删除的用户
+func RegisterTrashAdminRoutes(group *gin.RouterGroup, users *database.GormUserRepository) {
+	trash := group.Group("/trash/users")
+	{
+		trash.GET("", func(c *gin.Context) {
+			page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+			if err != nil || page < 1 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "page参数无效"})
+				return
+			}
+			limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+			if err != nil || limit < 1 || limit > database.MaxPageSize {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit参数无效，范围为1-100"})
+				return
+			}
+			trashed, err := users.Trashed(c.Request.Context(), page, limit)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Set("data", gin.H{
+				"items": trashed.Items,
+				"total": trashed.Total,
+				"page":  trashed.Page,
+				"pages": trashed.TotalPages(),
+			})
+		})
+		trash.POST("/:id/restore", func(c *gin.Context) {
+			id, ok := trashUserID(c)
+			if !ok {
+				return
+			}
+			user, err := users.Restore(c.Request.Context(), id)
+			if err != nil {
+				abortWithTrashError(c, err)
+				return
+			}
+			c.Set("data", gin.H{"id": user.ID, "username": user.Username, "email": user.Email})
+		})
+		trash.DELETE("/:id", func(c *gin.Context) {
+			id, ok := trashUserID(c)
+			if !ok {
+				return
+			}
+			result, err := users.Purge(c.Request.Context(), id)
+			if err != nil {
+				abortWithTrashError(c, err)
+				return
+			}
+			c.Set("data", gin.H{"users": result.Users, "posts": result.Posts})
+		})
+	}
+}
+
+// trashUserID 解析路径中的用户ID，无效时返回400
+func trashUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// abortWithTrashError 用户不存在返回404，不在回收站返回409
+func abortWithTrashError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, database.ErrConflict):
+		status = http.StatusConflict
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// 支持的语言，第一个为默认语言
+var supportedLanguages = []language.Tag{
+	language.MustParse("zh-CN"),
+	language.English,
+}
+
+var languageMatcher = language.NewMatcher(supportedLanguages)
+
+// validationCatalog 校验提示的翻译，键为英文原文
+var validationCatalog = newValidationCatalog()
+
+func newValidationCatalog() *catalog.Builder {
+	messages := map[string][2]string{ // 键 -> {中文, 英文}
+		// 字段名
+		"name":        {"名称", "name"},
+		"description": {"描述", "description"},
+		"price":       {"价格", "price"},
+		// 校验规则
+		"%s is required":                    {"%s为必填字段", "%s is required"},
+		"%s must be greater than %s":        {"%s必须大于%s", "%s must be greater than %s"},
+		"%s must be at least %s characters": {"%s长度不能少于%s个字符", "%s must be at least %s characters"},
+		"%s must be at most %s characters":  {"%s长度不能超过%s个字符", "%s must be at most %s characters"},
+		"%s must be a valid email address":  {"%s必须是有效的邮箱地址", "%s must be a valid email address"},
+		"%s allows at most %s decimal places": {
+			"%s最多只能有%s位小数", "%s allows at most %s decimal places",
+		},
+		"%s %q is already in use": {"%s“%s”已被使用", "%s %q is already in use"},
+		"%s is invalid (%s)":      {"%s不符合规则(%s)", "%s is invalid (%s)"},
+	}
+
+	builder := catalog.NewBuilder(catalog.Fallback(supportedLanguages[0]))
+	for key, texts := range messages {
+		for i, tag := range supportedLanguages {
+			builder.SetString(tag, key, texts[i])
+		}
+	}
+	return builder
+}
+
+func init() {
+	registerValidators()
+}
+
+// registerValidators 向gin的校验引擎注册自定义规则
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// 错误中使用json字段名，便于客户端定位
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// price_precision=N：小数位数不超过N位
+	v.RegisterValidation("price_precision", func(fl validator.FieldLevel) bool {
+		places, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			return false
+		}
+		value := fl.Field().Float()
+		scaled := value * math.Pow10(places)
+		return math.Abs(scaled-math.Round(scaled)) < 1e-6
+	})
+
+	// 产品名称在租户内唯一，更新时排除自身。这里只是提前给出字段错误，
+	// 并发写入时以 ProductStore 在锁内的检查(ErrDuplicateProductName)为准
+	v.RegisterStructValidationCtx(func(ctx context.Context, sl validator.StructLevel) {
+		tenant, ok := TenantFromContext(ctx)
+		if !ok {
+			return
+		}
+		product := sl.Current().Interface().(Product)
+		if tenant.Products().HasName(product.Name, product.ID) {
+			sl.ReportError(product.Name, "name", "Name", "unique_product_name", "")
+		}
+	}, Product{})
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// requestPrinter 根据 Accept-Language 选择语言
+func requestPrinter(c *gin.Context) *message.Printer {
+	tags, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	_, index, _ := languageMatcher.Match(tags...)
+	return message.NewPrinter(supportedLanguages[index], message.Catalog(validationCatalog))
+}
+
+// translateFieldError 将单个校验错误翻译为提示信息
+func translateFieldError(p *message.Printer, fe validator.FieldError) string {
+	field := p.Sprintf(fe.Field())
+	switch fe.Tag() {
+	case "required":
+		return p.Sprintf("%s is required", field)
+	case "gt":
+		return p.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "min":
+		return p.Sprintf("%s must be at least %s characters", field, fe.Param())
+	case "max":
+		return p.Sprintf("%s must be at most %s characters", field, fe.Param())
+	case "email":
+		return p.Sprintf("%s must be a valid email address", field)
+	case "price_precision":
+		return p.Sprintf("%s allows at most %s decimal places", field, fe.Param())
+	case "unique_product_name":
+		return p.Sprintf("%s %q is already in use", field, fe.Value())
+	default:
+		return p.Sprintf("%s is invalid (%s)", field, fe.Tag())
+	}
+}
+
+// TranslateValidationErrors 翻译校验错误，非校验错误返回nil
+func TranslateValidationErrors(p *message.Printer, err error) []FieldError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: translateFieldError(p, fe),
+		})
+	}
+	return fields
+}
+
+// validationMessage 将错误转为一行提示，校验错误按语言翻译
+func validationMessage(p *message.Printer, err error) string {
+	fields := TranslateValidationErrors(p, err)
+	if fields == nil {
+		return err.Error()
+	}
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// abortWithValidationError 返回本地化的校验错误
+func abortWithValidationError(c *gin.Context, status int, err error) {
+	p := requestPrinter(c)
+	fields := TranslateValidationErrors(p, err)
+	if fields == nil {
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{
+		"error":  validationMessage(p, err),
+		"fields": fields,
+	})
+}
+
+// duplicateNameMessage 名称重复的本地化提示，与 unique_product_name 校验规则的提示相同
+func duplicateNameMessage(p *message.Printer, name string) string {
+	return p.Sprintf("%s %q is already in use", p.Sprintf("name"), name)
+}
+
+// abortDuplicateName 以校验错误的格式响应 ErrDuplicateProductName
+func abortDuplicateName(c *gin.Context, name string) {
+	message := duplicateNameMessage(requestPrinter(c), name)
+	c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  message,
+		"fields": []FieldError{{Field: "name", Rule: "unique_product_name", Message: message}},
+	})
+}
+
+// validationStatus 名称重复与已有数据冲突，请求本身没有问题，响应422；其余校验错误响应400
+func validationStatus(err error) int {
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) && slices.ContainsFunc(errs, func(fe validator.FieldError) bool {
+		return fe.Tag() == "unique_product_name"
+	}) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+// validateProduct 按 Product 的绑定规则校验，ctx 中的租户用于名称唯一性检查
+func validateProduct(ctx context.Context, product *Product) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return binding.Validator.ValidateStruct(product)
+	}
+	return v.StructCtx(ctx, product)
+}
+
+// bindProduct 解析请求体并按 Product 的规则校验
+// id 为被更新产品的ID，唯一性校验会排除该产品；创建时传0
+func bindProduct(c *gin.Context, id uint) (Product, bool) {
+	var product Product
+	if err := json.NewDecoder(c.Request.Body).Decode(&product); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return product, false
+	}
+	product.ID = id
+	if err := validateProduct(c.Request.Context(), &product); err != nil {
+		abortWithValidationError(c, validationStatus(err), err)
+		return product, false
+	}
+	return product, true
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIPrefix 所有版本化接口的路径前缀
+const APIPrefix = "/api"
+
+// vendorMediaType 匹配 Accept: application/vnd.app.v2+json 中的版本号
+var vendorMediaType = regexp.MustCompile(`application/vnd\.app\.(v\d+)\+json`)
+
+// APIVersion 描述一个API版本及其弃用计划
+type APIVersion struct {
+	Name         string    // 版本名，如 v1
+	Deprecated   bool      // 是否已弃用
+	DeprecatedAt time.Time // 弃用时间，输出到 Deprecation 响应头
+	Sunset       time.Time // 计划下线时间，输出到 Sunset 响应头
+	Successor    string    // 替代版本，输出到 Link 响应头
+}
+
+// versionUsage 版本调用计数
+type versionUsage struct {
+	total  atomic.Int64
+	routes sync.Map // "METHOD /path" -> *atomic.Int64
+}
+
+// VersionUsage 版本调用统计快照
+type VersionUsage struct {
+	Version    string           `json:"version"`
+	Deprecated bool             `json:"deprecated"`
+	Sunset     *time.Time       `json:"sunset,omitempty"`
+	Total      int64            `json:"total"`
+	Routes     map[string]int64 `json:"routes"`
+}
+
+// VersionRouter 在gin路由之前按路径前缀或 Accept 头选择API版本
+// 未带版本的 /api/... 请求会被改写到协商出的版本下
+type VersionRouter struct {
+	engine         *gin.Engine
+	defaultVersion string
+	mutex          sync.RWMutex
+	versions       map[string]APIVersion
+	usage          map[string]*versionUsage
+}
+
+// NewVersionRouter 创建版本路由，defaultVersion 用于既没有路径版本也没有 Accept 版本的请求
+func NewVersionRouter(engine *gin.Engine, defaultVersion string) *VersionRouter {
+	return &VersionRouter{
+		engine:         engine,
+		defaultVersion: defaultVersion,
+		versions:       make(map[string]APIVersion),
+		usage:          make(map[string]*versionUsage),
+	}
+}
+
+// Group 注册一个版本并返回其路由组 /api/<version>
+func (vr *VersionRouter) Group(version APIVersion) *gin.RouterGroup {
+	vr.mutex.Lock()
+	vr.versions[version.Name] = version
+	vr.usage[version.Name] = &versionUsage{}
+	vr.mutex.Unlock()
+
+	group := vr.engine.Group(APIPrefix + "/" + version.Name)
+	group.Use(vr.versionMiddleware(version))
+	return group
+}
+
+// versionMiddleware 输出版本响应头并记录调用次数
+func (vr *VersionRouter) versionMiddleware(version APIVersion) gin.HandlerFunc {
+	usage := vr.usage[version.Name]
+
+	return func(c *gin.Context) {
+		c.Header("API-Version", version.Name)
+		if version.Deprecated {
+			// RFC 9745 Deprecation 与 RFC 8594 Sunset
+			if !version.DeprecatedAt.IsZero() {
+				c.Header("Deprecation", "@"+strconv.FormatInt(version.DeprecatedAt.Unix(), 10))
+			} else {
+				c.Header("Deprecation", "true")
+			}
+			if !version.Sunset.IsZero() {
+				c.Header("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if version.Successor != "" {
+				c.Header("Link", `<`+APIPrefix+"/"+version.Successor+`>; rel="successor-version"`)
+			}
+		}
+
+		usage.total.Add(1)
+		route := c.Request.Method + " " + c.FullPath()
+		counter, _ := usage.routes.LoadOrStore(route, &atomic.Int64{})
+		counter.(*atomic.Int64).Add(1)
+
+		c.Next()
+	}
+}
+
+// negotiate 确定请求的版本：路径中的版本优先，其次是 Accept 头，最后使用默认版本
+// 返回改写后的路径，以及通过 Accept 头协商出的版本
+func (vr *VersionRouter) negotiate(r *http.Request) (string, string) {
+	path := r.URL.Path
+	rest, ok := strings.CutPrefix(path, APIPrefix+"/")
+	if !ok {
+		return path, ""
+	}
+
+	vr.mutex.RLock()
+	defer vr.mutex.RUnlock()
+
+	segment, _, _ := strings.Cut(rest, "/")
+	if _, known := vr.versions[segment]; known {
+		return path, ""
+	}
+
+	if match := vendorMediaType.FindStringSubmatch(r.Header.Get("Accept")); match != nil {
+		if _, known := vr.versions[match[1]]; known {
+			return APIPrefix + "/" + match[1] + "/" + rest, match[1]
+		}
+	}
+	return APIPrefix + "/" + vr.defaultVersion + "/" + rest, ""
+}
+
+// ServeHTTP 实现 http.Handler，改写路径后交给gin处理
+func (vr *VersionRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, accepted := vr.negotiate(r)
+	if path != r.URL.Path {
+		r.URL.Path = path
+		r.URL.RawPath = ""
+		w.Header().Add("Vary", "Accept")
+	}
+	// 按客户端请求的媒体类型响应，处理函数显式设置的 Content-Type 优先
+	if accepted != "" {
+		w.Header().Set("Content-Type", "application/vnd.app."+accepted+"+json")
+	}
+	vr.engine.ServeHTTP(w, r)
+}
+
+// Usage 返回各版本调用统计
+func (vr *VersionRouter) Usage() []VersionUsage {
+	vr.mutex.RLock()
+	defer vr.mutex.RUnlock()
+
+	stats := make([]VersionUsage, 0, len(vr.versions))
+	for name, version := range vr.versions {
+		usage := vr.usage[name]
+		stat := VersionUsage{
+			Version:    name,
+			Deprecated: version.Deprecated,
+			Total:      usage.total.Load(),
+			Routes:     make(map[string]int64),
+		}
+		if !version.Sunset.IsZero() {
+			sunset := version.Sunset
+			stat.Sunset = &sunset
+		}
+		usage.routes.Range(func(key, value any) bool {
+			stat.Routes[key.(string)] = value.(*atomic.Int64).Load()
+			return true
+		})
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Version < stats[j].Version })
+	return stats
+}
+
+// UsageHandler 以JSON返回版本调用统计
+func (vr *VersionRouter) UsageHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, vr.Usage())
+}
+
+// StartUsageLogger 定期打印各版本调用次数，用于判断旧版本何时可以下线
+func (vr *VersionRouter) StartUsageLogger(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, stat := range vr.Usage() {
+					if stat.Deprecated {
+						log.Printf("[API] 已弃用版本 %s 调用次数: %d, 路由: %v", stat.Version, stat.Total, stat.Routes)
+					} else {
+						log.Printf("[API] 版本 %s 调用次数: %d", stat.Version, stat.Total)
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}